@@ -0,0 +1,66 @@
+package archive
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sunnymotiani/PackTrack/server/models/archive"
+	"github.com/sunnymotiani/PackTrack/server/utils"
+)
+
+type ArchiveController struct {
+	AS *archive.ArchiveService
+}
+
+// ExportEvent downloads an event as a JSON bundle.
+func (ac *ArchiveController) ExportEvent(w http.ResponseWriter, r *http.Request) {
+	eventID := chi.URLParam(r, "eventID")
+	if eventID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing eventID in URL"})
+		return
+	}
+	bundle, err := ac.AS.ExportEvent(r.Context(), eventID)
+	if err != nil {
+		respondArchiveError(w, "err exporting event", err)
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"event-%s.json\"", eventID))
+	utils.RespondJSON(w, http.StatusOK, bundle)
+}
+
+// ImportEvent creates a new event from a JSON bundle for the user given by
+// the user_id query parameter.
+func (ac *ArchiveController) ImportEvent(w http.ResponseWriter, r *http.Request) {
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing user_id"})
+		return
+	}
+	var bundle archive.Bundle
+	if err := json.NewDecoder(r.Body).Decode(&bundle); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "invalid bundle"})
+		return
+	}
+	result, err := ac.AS.ImportEvent(r.Context(), &bundle, userID)
+	if err != nil {
+		respondArchiveError(w, "err importing event", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusCreated, result)
+}
+
+func respondArchiveError(w http.ResponseWriter, msg string, err error) {
+	switch {
+	case errors.Is(err, archive.ErrInvalidBundle), errors.Is(err, archive.ErrUnsupportedVersion),
+		errors.Is(err, archive.ErrUnknownImporter):
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: err.Error()})
+	case errors.Is(err, archive.ErrEventNotFound):
+		utils.ResponseError(w, http.StatusNotFound, utils.JSONError{Msg: err.Error()})
+	default:
+		utils.ResponseError(w, http.StatusInternalServerError,
+			utils.JSONError{Msg: fmt.Sprintf("%s %s", msg, err.Error())})
+	}
+}
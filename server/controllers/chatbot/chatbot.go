@@ -0,0 +1,108 @@
+package chatbot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sunnymotiani/PackTrack/server/models/chatbot"
+	"github.com/sunnymotiani/PackTrack/server/models/webhooks"
+	"github.com/sunnymotiani/PackTrack/server/utils"
+)
+
+// maxSkew is how old a signed command may be before it is refused as a
+// replay.
+const maxSkew = 5 * time.Minute
+
+type ChatbotController struct {
+	CS *chatbot.ChatbotService
+	// Secret is shared with the chat app, which signs every command with it.
+	Secret string
+}
+
+// HandleCommand is the endpoint the chat app posts slash commands to.
+func (cc *ChatbotController) HandleCommand(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<16))
+	if err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "invalid request"})
+		return
+	}
+	timestamp, err := strconv.ParseInt(r.Header.Get(webhooks.HeaderTimestamp), 10, 64)
+	if err != nil || time.Since(time.Unix(timestamp, 0)).Abs() > maxSkew ||
+		!webhooks.Verify(cc.Secret, timestamp, body, r.Header.Get(webhooks.HeaderSignature)) {
+		utils.ResponseError(w, http.StatusUnauthorized, utils.JSONError{Msg: "invalid signature"})
+		return
+	}
+	cmd, err := chatbot.DecodeCommand(body)
+	if err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "invalid request"})
+		return
+	}
+	text, err := cc.CS.Handle(r.Context(), cmd)
+	if err != nil {
+		utils.ResponseError(w, http.StatusInternalServerError,
+			utils.JSONError{Msg: fmt.Sprintf("err running command %s", err.Error())})
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, chatbot.Reply{ResponseType: "ephemeral", Text: text})
+}
+
+func (cc *ChatbotController) LinkChannel(w http.ResponseWriter, r *http.Request) {
+	eventID := chi.URLParam(r, "eventID")
+	if eventID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing eventID in URL"})
+		return
+	}
+	var input struct {
+		UserID    string `json:"user_id"`
+		ChannelID string `json:"channel_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "invalid request"})
+		return
+	}
+	if err := cc.CS.LinkChannel(r.Context(), input.UserID, eventID, input.ChannelID); err != nil {
+		respondChatbotError(w, "err linking chat channel", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, nil)
+}
+
+func (cc *ChatbotController) LinkUser(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "userID")
+	if userID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing userID in URL"})
+		return
+	}
+	var input struct {
+		ChatUserID string `json:"chat_user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "invalid request"})
+		return
+	}
+	if err := cc.CS.LinkUser(r.Context(), userID, input.ChatUserID); err != nil {
+		respondChatbotError(w, "err linking chat user", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, nil)
+}
+
+func respondChatbotError(w http.ResponseWriter, msg string, err error) {
+	switch {
+	case errors.Is(err, chatbot.ErrMissingChatIDs):
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: err.Error()})
+	case errors.Is(err, chatbot.ErrNotEventAdmin):
+		utils.ResponseError(w, http.StatusForbidden, utils.JSONError{Msg: err.Error()})
+	case errors.Is(err, chatbot.ErrChatUserTaken):
+		utils.ResponseError(w, http.StatusConflict, utils.JSONError{Msg: err.Error()})
+	default:
+		utils.ResponseError(w, http.StatusInternalServerError,
+			utils.JSONError{Msg: fmt.Sprintf("%s %s", msg, err.Error())})
+	}
+}
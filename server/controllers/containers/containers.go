@@ -0,0 +1,152 @@
+package containers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sunnymotiani/PackTrack/server/models/containers"
+	"github.com/sunnymotiani/PackTrack/server/utils"
+)
+
+type ContainersController struct {
+	CS *containers.ContainersService
+}
+
+func (cc *ContainersController) CreateContainer(w http.ResponseWriter, r *http.Request) {
+	var input containers.Container
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "invalid request"})
+		return
+	}
+	if err := cc.CS.CreateContainer(r.Context(), &input); err != nil {
+		respondContainerError(w, "err creating container", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, input)
+}
+
+func (cc *ContainersController) UpdateContainer(w http.ResponseWriter, r *http.Request) {
+	containerID := chi.URLParam(r, "containerID")
+	if containerID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing containerID in URL"})
+		return
+	}
+	var updates map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "invalid JSON body"})
+		return
+	}
+	if err := cc.CS.UpdateContainer(r.Context(), containerID, updates); err != nil {
+		respondContainerError(w, "err updating container", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"msg": "container updated successfully"})
+}
+
+func (cc *ContainersController) DeleteContainer(w http.ResponseWriter, r *http.Request) {
+	containerID := chi.URLParam(r, "containerID")
+	if containerID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing containerID in URL"})
+		return
+	}
+	if err := cc.CS.DeleteContainer(r.Context(), containerID); err != nil {
+		respondContainerError(w, "err deleting container", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"msg": "container deleted successfully"})
+}
+
+func (cc *ContainersController) GetContainerLoads(w http.ResponseWriter, r *http.Request) {
+	eventID := chi.URLParam(r, "eventID")
+	if eventID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing eventID in URL"})
+		return
+	}
+	loads, err := cc.CS.GetContainerLoads(r.Context(), eventID)
+	if err != nil {
+		respondContainerError(w, "err fetching containers", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, loads)
+}
+
+func (cc *ContainersController) GetOverCapacity(w http.ResponseWriter, r *http.Request) {
+	eventID := chi.URLParam(r, "eventID")
+	if eventID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing eventID in URL"})
+		return
+	}
+	over, err := cc.CS.GetOverCapacity(r.Context(), eventID)
+	if err != nil {
+		respondContainerError(w, "err fetching over capacity containers", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, over)
+}
+
+func (cc *ContainersController) PlaceItem(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		ItemID      string  `json:"item_id"`
+		ContainerID *string `json:"container_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "invalid request"})
+		return
+	}
+	if err := cc.CS.PlaceItem(r.Context(), input.ItemID, input.ContainerID); err != nil {
+		respondContainerError(w, "err placing item", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, nil)
+}
+
+func (cc *ContainersController) SuggestPacking(w http.ResponseWriter, r *http.Request) {
+	eventID := chi.URLParam(r, "eventID")
+	if eventID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing eventID in URL"})
+		return
+	}
+	repack := r.URL.Query().Get("repack") == "true"
+	suggestion, err := cc.CS.SuggestPacking(r.Context(), eventID, repack)
+	if err != nil {
+		respondContainerError(w, "err suggesting packing", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, suggestion)
+}
+
+func (cc *ContainersController) ApplyPlacements(w http.ResponseWriter, r *http.Request) {
+	eventID := chi.URLParam(r, "eventID")
+	if eventID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing eventID in URL"})
+		return
+	}
+	var input struct {
+		Placements []containers.Placement `json:"placements"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "invalid request"})
+		return
+	}
+	if err := cc.CS.ApplyPlacements(r.Context(), eventID, input.Placements); err != nil {
+		respondContainerError(w, "err applying placements", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, nil)
+}
+
+func respondContainerError(w http.ResponseWriter, msg string, err error) {
+	switch {
+	case errors.Is(err, containers.ErrInvalidType), errors.Is(err, containers.ErrEventMismatch),
+		errors.Is(err, containers.ErrInvalidOwner):
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: err.Error()})
+	case errors.Is(err, containers.ErrNoSuchResource):
+		utils.ResponseError(w, http.StatusNotFound, utils.JSONError{Msg: err.Error()})
+	default:
+		utils.ResponseError(w, http.StatusInternalServerError,
+			utils.JSONError{Msg: fmt.Sprintf("%s %s", msg, err.Error())})
+	}
+}
@@ -0,0 +1,81 @@
+package duplicates
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sunnymotiani/PackTrack/server/models/duplicates"
+	"github.com/sunnymotiani/PackTrack/server/models/items"
+	"github.com/sunnymotiani/PackTrack/server/utils"
+)
+
+type DuplicatesController struct {
+	DS *duplicates.DuplicatesService
+}
+
+// GetDuplicates lists likely duplicate items of an event. The optional
+// threshold query parameter sets the name similarity required, from 0 to 1.
+func (dc *DuplicatesController) GetDuplicates(w http.ResponseWriter, r *http.Request) {
+	eventID := chi.URLParam(r, "eventID")
+	if eventID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing eventID in URL"})
+		return
+	}
+	var threshold float64
+	if raw := r.URL.Query().Get("threshold"); raw != "" {
+		var err error
+		if threshold, err = strconv.ParseFloat(raw, 64); err != nil || threshold == 0 {
+			utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: duplicates.ErrInvalidThreshold.Error()})
+			return
+		}
+	}
+	pairs, err := dc.DS.FindDuplicates(r.Context(), eventID, threshold)
+	if err != nil {
+		respondDuplicatesError(w, "err finding duplicates", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, pairs)
+}
+
+// MergeItems folds the source item into the target for the member given by
+// user_id and returns the target's new progress.
+func (dc *DuplicatesController) MergeItems(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		SourceID string `json:"source_id"`
+		TargetID string `json:"target_id"`
+		UserID   string `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "err bad request"})
+		return
+	}
+	if input.SourceID == "" || input.TargetID == "" || input.UserID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "source_id, target_id and user_id are required"})
+		return
+	}
+	progress, err := dc.DS.MergeItems(r.Context(), input.SourceID, input.TargetID, input.UserID)
+	if err != nil {
+		respondDuplicatesError(w, "err merging items", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, progress)
+}
+
+func respondDuplicatesError(w http.ResponseWriter, msg string, err error) {
+	switch {
+	case errors.Is(err, duplicates.ErrInvalidThreshold), errors.Is(err, duplicates.ErrSameItem),
+		errors.Is(err, duplicates.ErrDifferentEvents):
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: err.Error()})
+	case errors.Is(err, duplicates.ErrItemNotFound):
+		utils.ResponseError(w, http.StatusNotFound, utils.JSONError{Msg: err.Error()})
+	case errors.Is(err, items.ErrCannotManage), errors.Is(err, items.ErrNotEventMember):
+		utils.ResponseError(w, http.StatusForbidden, utils.JSONError{Msg: err.Error()})
+	default:
+		utils.ResponseError(w, http.StatusInternalServerError,
+			utils.JSONError{Msg: fmt.Sprintf("%s %s", msg, err.Error())})
+	}
+}
@@ -2,8 +2,10 @@ package events
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/sunnymotiani/PackTrack/server/models/events"
@@ -121,3 +123,55 @@ func (ec *EventController) UpdateMemberRole(w http.ResponseWriter, r *http.Reque
 
 	utils.RespondJSON(w, http.StatusOK, nil)
 }
+
+func (ec *EventController) SetEventDates(w http.ResponseWriter, r *http.Request) {
+	eventID := chi.URLParam(r, "eventID")
+	if eventID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing eventID in URL"})
+		return
+	}
+	var input struct {
+		StartsAt *time.Time `json:"starts_at"`
+		EndsAt   *time.Time `json:"ends_at"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "invalid request"})
+		return
+	}
+
+	err := ec.ES.SetEventDates(r.Context(), eventID, input.StartsAt, input.EndsAt)
+	if errors.Is(err, events.ErrGearConflict) {
+		utils.ResponseError(w, http.StatusConflict, utils.JSONError{Msg: err.Error()})
+		return
+	}
+	if err != nil {
+		utils.ResponseError(w, http.StatusInternalServerError, utils.JSONError{Msg: fmt.Sprintf("err setting event dates %s", err.Error())})
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, nil)
+}
+
+func (ec *EventController) SetPackingDeadline(w http.ResponseWriter, r *http.Request) {
+	eventID := chi.URLParam(r, "eventID")
+	if eventID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing eventID in URL"})
+		return
+	}
+	var input struct {
+		PackingDeadline *time.Time `json:"packing_deadline"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "invalid request"})
+		return
+	}
+
+	if err := ec.ES.SetPackingDeadline(r.Context(), eventID, input.PackingDeadline); err != nil {
+		utils.ResponseError(w, http.StatusInternalServerError, utils.JSONError{Msg: fmt.Sprintf("err setting packing deadline %s", err.Error())})
+		return
+	}
+
+	utils.RespondJSON(w, http.StatusOK, nil)
+}
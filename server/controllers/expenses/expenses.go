@@ -0,0 +1,107 @@
+package expenses
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sunnymotiani/PackTrack/server/models/expenses"
+	"github.com/sunnymotiani/PackTrack/server/utils"
+)
+
+type ExpensesController struct {
+	ES *expenses.ExpensesService
+}
+
+func (ec *ExpensesController) CreateExpense(w http.ResponseWriter, r *http.Request) {
+	eventID := chi.URLParam(r, "eventID")
+	if eventID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing eventID in URL"})
+		return
+	}
+	var input expenses.Expense
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "invalid request"})
+		return
+	}
+	input.EventID = eventID
+	if err := ec.ES.CreateExpense(r.Context(), &input); err != nil {
+		respondExpenseError(w, "err creating expense", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, input)
+}
+
+func (ec *ExpensesController) SetItemCost(w http.ResponseWriter, r *http.Request) {
+	itemID := chi.URLParam(r, "itemID")
+	if itemID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing itemID in URL"})
+		return
+	}
+	var input expenses.Expense
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "invalid request"})
+		return
+	}
+	if err := ec.ES.SetItemCost(r.Context(), itemID, &input); err != nil {
+		respondExpenseError(w, "err setting item cost", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, input)
+}
+
+func (ec *ExpensesController) DeleteExpense(w http.ResponseWriter, r *http.Request) {
+	expenseID := chi.URLParam(r, "expenseID")
+	if expenseID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing expenseID in URL"})
+		return
+	}
+	if err := ec.ES.DeleteExpense(r.Context(), expenseID); err != nil {
+		respondExpenseError(w, "err deleting expense", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"msg": "expense deleted successfully"})
+}
+
+func (ec *ExpensesController) GetEventExpenses(w http.ResponseWriter, r *http.Request) {
+	eventID := chi.URLParam(r, "eventID")
+	if eventID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing eventID in URL"})
+		return
+	}
+	list, err := ec.ES.GetEventExpenses(r.Context(), eventID)
+	if err != nil {
+		respondExpenseError(w, "err fetching expenses", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, list)
+}
+
+func (ec *ExpensesController) SettleUp(w http.ResponseWriter, r *http.Request) {
+	eventID := chi.URLParam(r, "eventID")
+	if eventID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing eventID in URL"})
+		return
+	}
+	settlement, err := ec.ES.SettleUp(r.Context(), eventID)
+	if err != nil {
+		respondExpenseError(w, "err settling up", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, settlement)
+}
+
+func respondExpenseError(w http.ResponseWriter, msg string, err error) {
+	switch {
+	case errors.Is(err, expenses.ErrInvalidSplit), errors.Is(err, expenses.ErrInvalidAmount),
+		errors.Is(err, expenses.ErrDuplicateShare), errors.Is(err, expenses.ErrNotEventMember):
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: err.Error()})
+	case errors.Is(err, expenses.ErrExpenseNotFound), errors.Is(err, expenses.ErrItemNotFound):
+		utils.ResponseError(w, http.StatusNotFound, utils.JSONError{Msg: err.Error()})
+	default:
+		utils.ResponseError(w, http.StatusInternalServerError,
+			utils.JSONError{Msg: fmt.Sprintf("%s %s", msg, err.Error())})
+	}
+}
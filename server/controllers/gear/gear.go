@@ -0,0 +1,152 @@
+package gear
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sunnymotiani/PackTrack/server/models/gear"
+	"github.com/sunnymotiani/PackTrack/server/models/items"
+	"github.com/sunnymotiani/PackTrack/server/utils"
+)
+
+type GearController struct {
+	GS *gear.GearService
+}
+
+func (gc *GearController) CreateGear(w http.ResponseWriter, r *http.Request) {
+	var input gear.Gear
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "invalid request"})
+		return
+	}
+	if err := gc.GS.CreateGear(r.Context(), &input); err != nil {
+		respondGearError(w, "err creating gear", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, input)
+}
+
+func (gc *GearController) UpdateGear(w http.ResponseWriter, r *http.Request) {
+	gearID := chi.URLParam(r, "gearID")
+	if gearID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing gearID in URL"})
+		return
+	}
+	var updates map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "invalid JSON body"})
+		return
+	}
+	if err := gc.GS.UpdateGear(r.Context(), gearID, updates); err != nil {
+		respondGearError(w, "err updating gear", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"msg": "gear updated successfully"})
+}
+
+func (gc *GearController) DeleteGear(w http.ResponseWriter, r *http.Request) {
+	gearID := chi.URLParam(r, "gearID")
+	if gearID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing gearID in URL"})
+		return
+	}
+	if err := gc.GS.DeleteGear(r.Context(), gearID); err != nil {
+		respondGearError(w, "err deleting gear", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"msg": "gear deleted successfully"})
+}
+
+func (gc *GearController) GetUserGear(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "userID")
+	if userID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing userID in URL"})
+		return
+	}
+	inventory, err := gc.GS.GetUserGear(r.Context(), userID)
+	if err != nil {
+		respondGearError(w, "err fetching gear", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, inventory)
+}
+
+func (gc *GearController) AddGearPhoto(w http.ResponseWriter, r *http.Request) {
+	gearID := chi.URLParam(r, "gearID")
+	if gearID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing gearID in URL"})
+		return
+	}
+	var input gear.Photo
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.URL == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "invalid request"})
+		return
+	}
+	input.GearID = gearID
+	if err := gc.GS.AddGearPhoto(r.Context(), &input); err != nil {
+		respondGearError(w, "err adding gear photo", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, input)
+}
+
+func (gc *GearController) DeleteGearPhoto(w http.ResponseWriter, r *http.Request) {
+	photoID := chi.URLParam(r, "photoID")
+	if photoID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing photoID in URL"})
+		return
+	}
+	if err := gc.GS.DeleteGearPhoto(r.Context(), photoID); err != nil {
+		respondGearError(w, "err deleting gear photo", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, nil)
+}
+
+func (gc *GearController) LinkItem(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		ItemID string  `json:"item_id"`
+		GearID *string `json:"gear_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "invalid request"})
+		return
+	}
+	if err := gc.GS.LinkItem(r.Context(), input.ItemID, input.GearID); err != nil {
+		respondGearError(w, "err linking item to gear", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, nil)
+}
+
+func (gc *GearController) GetCoverage(w http.ResponseWriter, r *http.Request) {
+	eventID := chi.URLParam(r, "eventID")
+	if eventID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing eventID in URL"})
+		return
+	}
+	coverage, err := gc.GS.GetCoverage(r.Context(), eventID)
+	if err != nil {
+		respondGearError(w, "err fetching gear coverage", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, coverage)
+}
+
+func respondGearError(w http.ResponseWriter, msg string, err error) {
+	switch {
+	case errors.Is(err, gear.ErrInvalidCondition), errors.Is(err, gear.ErrGearRetired),
+		errors.Is(err, gear.ErrNotEventMember), errors.Is(err, items.ErrUnknownUnit):
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: err.Error()})
+	case errors.Is(err, gear.ErrGearNotFound), errors.Is(err, gear.ErrItemNotFound):
+		utils.ResponseError(w, http.StatusNotFound, utils.JSONError{Msg: err.Error()})
+	case errors.Is(err, gear.ErrGearConflict):
+		utils.ResponseError(w, http.StatusConflict, utils.JSONError{Msg: err.Error()})
+	default:
+		utils.ResponseError(w, http.StatusInternalServerError,
+			utils.JSONError{Msg: fmt.Sprintf("%s %s", msg, err.Error())})
+	}
+}
@@ -0,0 +1,76 @@
+package imports
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sunnymotiani/PackTrack/server/models/imports"
+	"github.com/sunnymotiani/PackTrack/server/models/items"
+	"github.com/sunnymotiani/PackTrack/server/utils"
+)
+
+// maxUploadSize bounds the size of an uploaded import file.
+const maxUploadSize = 10 << 20
+
+type ImportController struct {
+	IS *imports.ImportService
+}
+
+// ImportItems takes a CSV or XLSX file in the "file" field of a multipart
+// form. The format comes from the format query parameter or, failing that,
+// the file extension. Rejected files are answered with the per-row errors.
+// The user_id query parameter names the member importing.
+func (ic *ImportController) ImportItems(w http.ResponseWriter, r *http.Request) {
+	eventID, userID := chi.URLParam(r, "eventID"), r.URL.Query().Get("user_id")
+	if eventID == "" || userID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing eventID or user_id"})
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing file in form"})
+		return
+	}
+	defer file.Close()
+
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+	}
+	var rows [][]string
+	switch format {
+	case "csv":
+		rows, err = imports.ReadCSV(file)
+	case "xlsx":
+		rows, err = imports.ReadXLSX(file)
+	default:
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "format must be csv or xlsx"})
+		return
+	}
+	if err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: err.Error()})
+		return
+	}
+
+	result, err := ic.IS.ImportItems(r.Context(), eventID, userID, rows)
+	switch {
+	case errors.Is(err, imports.ErrInvalidRows):
+		utils.RespondJSON(w, http.StatusUnprocessableEntity, result)
+	case errors.Is(err, imports.ErrEmptyFile), errors.Is(err, imports.ErrMissingColumns),
+		errors.Is(err, imports.ErrTooManyRows):
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: err.Error()})
+	case errors.Is(err, items.ErrNotEventMember), errors.Is(err, items.ErrCannotEditEvent),
+		errors.Is(err, items.ErrCannotManage), errors.Is(err, items.ErrNotEventAdmin):
+		utils.ResponseError(w, http.StatusForbidden, utils.JSONError{Msg: err.Error()})
+	case err != nil:
+		utils.ResponseError(w, http.StatusInternalServerError,
+			utils.JSONError{Msg: fmt.Sprintf("err importing items %s", err.Error())})
+	default:
+		utils.RespondJSON(w, http.StatusOK, result)
+	}
+}
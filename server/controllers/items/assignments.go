@@ -0,0 +1,84 @@
+package items
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sunnymotiani/PackTrack/server/models/items"
+	"github.com/sunnymotiani/PackTrack/server/utils"
+)
+
+func (ic *ItemStatusController) GetItemAssignments(w http.ResponseWriter, r *http.Request) {
+	itemID := chi.URLParam(r, "itemID")
+	if itemID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing itemID in URL"})
+		return
+	}
+	assignments, err := ic.IS.GetItemAssignments(r.Context(), itemID)
+	if err != nil {
+		utils.ResponseError(w, http.StatusInternalServerError,
+			utils.JSONError{Msg: fmt.Sprintf("err fetching assignments %s", err.Error())})
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, assignments)
+}
+
+func (ic *ItemStatusController) SetItemAssignments(w http.ResponseWriter, r *http.Request) {
+	itemID := chi.URLParam(r, "itemID")
+	if itemID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing itemID in URL"})
+		return
+	}
+	var input struct {
+		Assignments []items.AssignmentInput `json:"assignments"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "err bad request"})
+		return
+	}
+	if !authorizeItem(w, r, ic.IS, r.URL.Query().Get("user_id"), itemID) {
+		return
+	}
+	assignments, err := ic.IS.SetItemAssignments(r.Context(), itemID, input.Assignments)
+	if errors.Is(err, items.ErrAssignmentQuantity) || errors.Is(err, items.ErrNotEventMember) {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: err.Error()})
+		return
+	}
+	if err != nil {
+		utils.ResponseError(w, http.StatusInternalServerError,
+			utils.JSONError{Msg: fmt.Sprintf("err setting assignments %s", err.Error())})
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, assignments)
+}
+
+func (ic *ItemStatusController) AdjustAssignmentProgress(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		ItemID         string `json:"item_id"`
+		UserID         string `json:"user_id"`
+		PackedDelta    int    `json:"packed_delta"`
+		DeliveredDelta int    `json:"delivered_delta"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "err bad request"})
+		return
+	}
+	if !authorizeItemWith(w, r, ic.IS.CanActOnItem, input.UserID, input.ItemID) {
+		return
+	}
+	assignment, err := ic.IS.AdjustAssignmentProgress(r.Context(), input.ItemID, input.UserID,
+		input.PackedDelta, input.DeliveredDelta)
+	if errors.Is(err, items.ErrInvalidProgress) {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: err.Error()})
+		return
+	}
+	if err != nil {
+		utils.ResponseError(w, http.StatusInternalServerError,
+			utils.JSONError{Msg: fmt.Sprintf("err updating assignment progress %s", err.Error())})
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, assignment)
+}
@@ -0,0 +1,74 @@
+package items
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sunnymotiani/PackTrack/server/models/items"
+	"github.com/sunnymotiani/PackTrack/server/utils"
+)
+
+func (ic *ItemStatusController) PreviewAutoAssign(w http.ResponseWriter, r *http.Request) {
+	eventID := chi.URLParam(r, "eventID")
+	if eventID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing eventID in URL"})
+		return
+	}
+	var input items.AutoAssignOptions
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "err bad request"})
+		return
+	}
+	plan, err := ic.IS.PlanAutoAssign(r.Context(), eventID, input)
+	if errors.Is(err, items.ErrUnknownBalance) || errors.Is(err, items.ErrNoMembers) || errors.Is(err, items.ErrNotEventMember) {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: err.Error()})
+		return
+	}
+	if err != nil {
+		utils.ResponseError(w, http.StatusInternalServerError,
+			utils.JSONError{Msg: fmt.Sprintf("err planning auto assign %s", err.Error())})
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, plan)
+}
+
+func (ic *ItemStatusController) CommitAutoAssign(w http.ResponseWriter, r *http.Request) {
+	eventID := chi.URLParam(r, "eventID")
+	if eventID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing eventID in URL"})
+		return
+	}
+	var input struct {
+		Options     items.AutoAssignOptions    `json:"options"`
+		Assignments []items.ProposedAssignment `json:"assignments"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "err bad request"})
+		return
+	}
+	itemIDs := make([]string, 0, len(input.Assignments))
+	for _, a := range input.Assignments {
+		itemIDs = append(itemIDs, a.ItemID)
+	}
+	if !authorizeItem(w, r, ic.IS, r.URL.Query().Get("user_id"), itemIDs...) {
+		return
+	}
+	err := ic.IS.ApplyAutoAssign(r.Context(), eventID, input.Options, input.Assignments)
+	if errors.Is(err, items.ErrPlanStale) {
+		utils.ResponseError(w, http.StatusConflict, utils.JSONError{Msg: err.Error()})
+		return
+	}
+	if errors.Is(err, items.ErrUnknownBalance) || errors.Is(err, items.ErrNoMembers) ||
+		errors.Is(err, items.ErrPlanInvalid) {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: err.Error()})
+		return
+	}
+	if err != nil {
+		respondClaimError(w, "err applying auto assign", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, nil)
+}
@@ -0,0 +1,48 @@
+package items
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sunnymotiani/PackTrack/server/models/items"
+	"github.com/sunnymotiani/PackTrack/server/utils"
+)
+
+// ApplyBulk runs a batch of item operations for the member given by user_id.
+// A batch with a failing operation changes nothing and answers 422 with the
+// result of every operation.
+func (ic *ItemStatusController) ApplyBulk(w http.ResponseWriter, r *http.Request) {
+	eventID := chi.URLParam(r, "eventID")
+	if eventID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing eventID in URL"})
+		return
+	}
+	var input struct {
+		UserID     string                `json:"user_id"`
+		Operations []items.BulkOperation `json:"operations"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "err bad request"})
+		return
+	}
+	results, err := ic.IS.ApplyBulk(r.Context(), eventID, input.UserID, input.Operations)
+	switch {
+	case errors.Is(err, items.ErrBulkFailed):
+		utils.RespondJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+			"msg":     err.Error(),
+			"results": results,
+		})
+	case errors.Is(err, items.ErrInvalidBulkOp), errors.Is(err, items.ErrTooManyBulkOps):
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: err.Error()})
+	case errors.Is(err, items.ErrNotEventMember), errors.Is(err, items.ErrCannotEditEvent):
+		utils.ResponseError(w, http.StatusForbidden, utils.JSONError{Msg: err.Error()})
+	case err != nil:
+		utils.ResponseError(w, http.StatusInternalServerError,
+			utils.JSONError{Msg: fmt.Sprintf("err applying bulk operations %s", err.Error())})
+	default:
+		utils.RespondJSON(w, http.StatusOK, results)
+	}
+}
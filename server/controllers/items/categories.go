@@ -1,7 +1,174 @@
 package items
 
-import "github.com/sunnymotiani/PackTrack/server/models/items"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sunnymotiani/PackTrack/server/models/items"
+	"github.com/sunnymotiani/PackTrack/server/utils"
+)
 
 type CategoriesController struct {
 	IS *items.ItemsService
 }
+
+// MoveCategory places a category before or after another category of its
+// event.
+func (cc *CategoriesController) MoveCategory(w http.ResponseWriter, r *http.Request) {
+	catID := chi.URLParam(r, "catID")
+	if catID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing categoryID in URL"})
+		return
+	}
+	var placement items.Placement
+	if err := json.NewDecoder(r.Body).Decode(&placement); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "invalid JSON body"})
+		return
+	}
+	if !authorizeCategory(w, r, cc.IS, r.URL.Query().Get("user_id"), catID) {
+		return
+	}
+	if err := cc.IS.MoveCategory(r.Context(), catID, placement); err != nil {
+		respondCategoryError(w, "failed to move category:", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"msg": "category moved successfully"})
+}
+
+// GetCategoryTree returns the nested categories of an event with their item
+// counts rolled up.
+func (cc *CategoriesController) GetCategoryTree(w http.ResponseWriter, r *http.Request) {
+	eventID := chi.URLParam(r, "eventID")
+	if eventID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing eventID in URL"})
+		return
+	}
+	tree, err := cc.IS.GetCategoryTree(r.Context(), eventID)
+	if err != nil {
+		respondCategoryError(w, "err fetching category tree", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, tree)
+}
+
+// SetCategoryParent nests a category under parent_id, or makes it top-level
+// when parent_id is null.
+func (cc *CategoriesController) SetCategoryParent(w http.ResponseWriter, r *http.Request) {
+	catID := chi.URLParam(r, "catID")
+	if catID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing categoryID in URL"})
+		return
+	}
+	var input struct {
+		ParentID *string `json:"parent_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "invalid JSON body"})
+		return
+	}
+	parentID := ""
+	if input.ParentID != nil {
+		parentID = *input.ParentID
+	}
+	if !authorizeCategory(w, r, cc.IS, r.URL.Query().Get("user_id"), catID, parentID) {
+		return
+	}
+	if err := cc.IS.SetCategoryParent(r.Context(), catID, input.ParentID); err != nil {
+		respondCategoryError(w, "failed to move category:", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"msg": "category moved successfully"})
+}
+
+// RenameCategory renames a category; the name must be free among its
+// siblings.
+func (cc *CategoriesController) RenameCategory(w http.ResponseWriter, r *http.Request) {
+	catID := chi.URLParam(r, "catID")
+	if catID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing categoryID in URL"})
+		return
+	}
+	var input struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "invalid JSON body"})
+		return
+	}
+	if !authorizeCategory(w, r, cc.IS, r.URL.Query().Get("user_id"), catID) {
+		return
+	}
+	if err := cc.IS.UpdateCategoryName(r.Context(), catID, input.Name); err != nil {
+		respondCategoryError(w, "failed to rename category:", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"msg": "category renamed successfully"})
+}
+
+// MergeCategory moves everything in a category into target_id and deletes
+// it.
+func (cc *CategoriesController) MergeCategory(w http.ResponseWriter, r *http.Request) {
+	catID := chi.URLParam(r, "catID")
+	if catID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing categoryID in URL"})
+		return
+	}
+	var input struct {
+		TargetID string `json:"target_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.TargetID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "target_id is required"})
+		return
+	}
+	if !authorizeCategory(w, r, cc.IS, r.URL.Query().Get("user_id"), catID, input.TargetID) {
+		return
+	}
+	moved, err := cc.IS.MergeCategories(r.Context(), catID, input.TargetID)
+	if err != nil {
+		respondCategoryError(w, "failed to merge category:", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, map[string]int{"items_moved": moved})
+}
+
+// DeleteCategory deletes an empty category. The cascade=true query parameter
+// deletes its contents too and move_to merges them into another category.
+func (cc *CategoriesController) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	catID := chi.URLParam(r, "catID")
+	if catID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing categoryID in URL"})
+		return
+	}
+	q := r.URL.Query()
+	opts := items.DeleteCategoryOptions{Cascade: q.Get("cascade") == "true", MoveTo: q.Get("move_to")}
+	if !authorizeCategory(w, r, cc.IS, r.URL.Query().Get("user_id"), catID, opts.MoveTo) {
+		return
+	}
+	if err := cc.IS.DeleteCategory(r.Context(), catID, opts); err != nil {
+		respondCategoryError(w, "failed to delete category:", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"msg": "category deleted successfully"})
+}
+
+func respondCategoryError(w http.ResponseWriter, msg string, err error) {
+	switch {
+	case errors.Is(err, items.ErrInvalidPlacement), errors.Is(err, items.ErrCategoryCycle),
+		errors.Is(err, items.ErrCategoryNotInEvent), errors.Is(err, items.ErrInvalidMerge),
+		errors.Is(err, items.ErrInvalidDeleteOption), errors.Is(err, items.ErrInvalidCategoryName):
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: err.Error()})
+	case errors.Is(err, items.ErrCategoryNotFound):
+		utils.ResponseError(w, http.StatusNotFound, utils.JSONError{Msg: err.Error()})
+	case errors.Is(err, items.ErrNotEventAdmin), errors.Is(err, items.ErrNotEventMember),
+		errors.Is(err, items.ErrCannotLead), errors.Is(err, items.ErrCannotManage):
+		utils.ResponseError(w, http.StatusForbidden, utils.JSONError{Msg: err.Error()})
+	case errors.Is(err, items.ErrCategoryExists), errors.Is(err, items.ErrCategoryNotEmpty):
+		utils.ResponseError(w, http.StatusConflict, utils.JSONError{Msg: err.Error()})
+	default:
+		utils.ResponseError(w, http.StatusInternalServerError,
+			utils.JSONError{Msg: fmt.Sprintf("%s %s", msg, err.Error())})
+	}
+}
@@ -0,0 +1,145 @@
+package items
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sunnymotiani/PackTrack/server/models/items"
+	"github.com/sunnymotiani/PackTrack/server/utils"
+)
+
+func (ic *ItemStatusController) ClaimItem(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		ItemID string `json:"item_id"`
+		UserID string `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "err bad request"})
+		return
+	}
+	if !authorizeItemWith(w, r, ic.IS.CanClaimItem, input.UserID, input.ItemID) {
+		return
+	}
+	err := ic.IS.ClaimItem(r.Context(), input.ItemID, input.UserID)
+	if err != nil {
+		respondClaimError(w, "err claiming item", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, nil)
+}
+
+func (ic *ItemStatusController) ReleaseItem(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		ItemID string `json:"item_id"`
+		UserID string `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "err bad request"})
+		return
+	}
+	if !authorizeItemWith(w, r, ic.IS.CanActOnItem, input.UserID, input.ItemID) {
+		return
+	}
+	err := ic.IS.ReleaseItem(r.Context(), input.ItemID, input.UserID)
+	if err != nil {
+		respondClaimError(w, "err releasing item", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, nil)
+}
+
+func (ic *ItemStatusController) RequestSwap(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		ItemID        string  `json:"item_id"`
+		RequesterID   string  `json:"requester_id"`
+		TargetID      string  `json:"target_id"`
+		CounterItemID *string `json:"counter_item_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "err bad request"})
+		return
+	}
+	swap, err := ic.IS.RequestSwap(r.Context(), input.ItemID, input.RequesterID, input.TargetID, input.CounterItemID)
+	if err != nil {
+		respondClaimError(w, "err requesting swap", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, swap)
+}
+
+func (ic *ItemStatusController) RespondToSwap(w http.ResponseWriter, r *http.Request) {
+	swapID := chi.URLParam(r, "swapID")
+	if swapID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing swapID in URL"})
+		return
+	}
+	var input struct {
+		UserID string `json:"user_id"`
+		Accept bool   `json:"accept"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "err bad request"})
+		return
+	}
+	swap, err := ic.IS.RespondToSwap(r.Context(), swapID, input.UserID, input.Accept)
+	if err != nil {
+		respondClaimError(w, "err responding to swap", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, swap)
+}
+
+func (ic *ItemStatusController) CancelSwap(w http.ResponseWriter, r *http.Request) {
+	swapID := chi.URLParam(r, "swapID")
+	if swapID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing swapID in URL"})
+		return
+	}
+	var input struct {
+		UserID string `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "err bad request"})
+		return
+	}
+	if err := ic.IS.CancelSwap(r.Context(), swapID, input.UserID); err != nil {
+		respondClaimError(w, "err cancelling swap", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, nil)
+}
+
+func (ic *ItemStatusController) GetPendingSwaps(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "userID")
+	if userID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing userID in URL"})
+		return
+	}
+	swaps, err := ic.IS.GetPendingSwapsForUser(r.Context(), userID)
+	if err != nil {
+		utils.ResponseError(w, http.StatusInternalServerError,
+			utils.JSONError{Msg: fmt.Sprintf("err fetching swaps %s", err.Error())})
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, swaps)
+}
+
+func respondClaimError(w http.ResponseWriter, msg string, err error) {
+	switch {
+	case errors.Is(err, items.ErrNothingToAssign):
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: err.Error()})
+	case errors.Is(err, items.ErrItemAlreadyClaimed), errors.Is(err, items.ErrSwapNotPending):
+		utils.ResponseError(w, http.StatusConflict, utils.JSONError{Msg: err.Error()})
+	case errors.Is(err, items.ErrNotEventMember), errors.Is(err, items.ErrCannotClaim),
+		errors.Is(err, items.ErrNotAssignee), errors.Is(err, items.ErrSwapForbidden):
+		utils.ResponseError(w, http.StatusForbidden, utils.JSONError{Msg: err.Error()})
+	case errors.Is(err, items.ErrSwapNotFound):
+		utils.ResponseError(w, http.StatusNotFound, utils.JSONError{Msg: err.Error()})
+	default:
+		utils.ResponseError(w, http.StatusInternalServerError,
+			utils.JSONError{Msg: fmt.Sprintf("%s %s", msg, err.Error())})
+	}
+}
@@ -1,7 +1,9 @@
 package items
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -20,6 +22,13 @@ func (ic *ItemStatusController) AddItem(w http.ResponseWriter, r *http.Request)
 		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "err bad request"})
 		return
 	}
+	if input.CategoryID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "category_id is required"})
+		return
+	}
+	if !authorizeCategory(w, r, ic.IS, r.URL.Query().Get("user_id"), input.CategoryID) {
+		return
+	}
 	err := ic.IS.AddItem(&input)
 	if err != nil {
 		utils.ResponseError(w, http.StatusInternalServerError,
@@ -53,6 +62,9 @@ func (ic *ItemStatusController) UpdateItemStatus(w http.ResponseWriter, r *http.
 		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "err bad request"})
 		return
 	}
+	if !authorizeItemWith(w, r, ic.IS.CanActOnItem, input.UserID, input.ItemID) {
+		return
+	}
 	err := ic.IS.UpdateItemStatus(input.ItemID, input.NewStatus, input.UserID)
 	if err != nil {
 		utils.ResponseError(w, http.StatusInternalServerError,
@@ -61,6 +73,40 @@ func (ic *ItemStatusController) UpdateItemStatus(w http.ResponseWriter, r *http.
 	}
 	utils.RespondJSON(w, http.StatusOK, nil)
 }
+func (ic *ItemStatusController) AdjustPackedQuantity(w http.ResponseWriter, r *http.Request) {
+	ic.adjustProgress(w, r, func(delta int) (int, int) { return delta, 0 })
+}
+
+func (ic *ItemStatusController) AdjustDeliveredQuantity(w http.ResponseWriter, r *http.Request) {
+	ic.adjustProgress(w, r, func(delta int) (int, int) { return 0, delta })
+}
+
+func (ic *ItemStatusController) adjustProgress(w http.ResponseWriter, r *http.Request, deltas func(int) (int, int)) {
+	var input struct {
+		ItemID string `json:"item_id"`
+		UserID string `json:"user_id"`
+		Delta  int    `json:"delta"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "err bad request"})
+		return
+	}
+	if !authorizeItemWith(w, r, ic.IS.CanActOnItem, input.UserID, input.ItemID) {
+		return
+	}
+	packedDelta, deliveredDelta := deltas(input.Delta)
+	progress, err := ic.IS.AdjustItemProgress(r.Context(), input.ItemID, input.UserID, packedDelta, deliveredDelta)
+	if errors.Is(err, items.ErrInvalidProgress) {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: err.Error()})
+		return
+	}
+	if err != nil {
+		utils.ResponseError(w, http.StatusInternalServerError,
+			utils.JSONError{Msg: fmt.Sprintf("err updating item quantities %s", err.Error())})
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, progress)
+}
 func (ic *ItemStatusController) AssignItem(w http.ResponseWriter, r *http.Request) {
 	var input struct {
 		ItemID string `json:"item_id"`
@@ -70,7 +116,14 @@ func (ic *ItemStatusController) AssignItem(w http.ResponseWriter, r *http.Reques
 		utils.ResponseError(w, http.StatusBadGateway, utils.JSONError{Msg: "err bad request"})
 		return
 	}
+	if !authorizeItem(w, r, ic.IS, r.URL.Query().Get("user_id"), input.ItemID) {
+		return
+	}
 	err := ic.IS.AssignItem(input.ItemID, input.UserID)
+	if errors.Is(err, items.ErrNothingToAssign) {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: err.Error()})
+		return
+	}
 	if err != nil {
 		utils.ResponseError(w, http.StatusInternalServerError,
 			utils.JSONError{Msg: fmt.Sprintf("err assigning item %s", err.Error())})
@@ -86,6 +139,9 @@ func (ic *ItemStatusController) UnAssignItem(w http.ResponseWriter, r *http.Requ
 		utils.ResponseError(w, http.StatusBadGateway, utils.JSONError{Msg: "err bad request"})
 		return
 	}
+	if !authorizeItem(w, r, ic.IS, r.URL.Query().Get("user_id"), input.ItemID) {
+		return
+	}
 	err := ic.IS.UnassignItem(input.ItemID)
 	if err != nil {
 		utils.ResponseError(w, http.StatusInternalServerError,
@@ -106,8 +162,19 @@ func (ic *ItemsController) EditItem(w http.ResponseWriter, r *http.Request) {
 		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "invalid JSON body"})
 		return
 	}
+	if !authorizeItem(w, r, ic.IS, r.URL.Query().Get("user_id"), itemID) {
+		return
+	}
 
 	err := ic.IS.EditItem(r.Context(), itemID, updates)
+	if errors.Is(err, items.ErrInvalidEdit) || errors.Is(err, items.ErrQuantityBelowProgress) {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: err.Error()})
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.ResponseError(w, http.StatusNotFound, utils.JSONError{Msg: "item not found"})
+		return
+	}
 	if err != nil {
 		utils.ResponseError(w, http.StatusInternalServerError,
 			utils.JSONError{Msg: fmt.Sprintf("failed to update item: %s", err.Error())})
@@ -124,6 +191,9 @@ func (ic *ItemsController) DeleteItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !authorizeItem(w, r, ic.IS, r.URL.Query().Get("user_id"), itemID) {
+		return
+	}
 	err := ic.IS.DeleteItem(itemID)
 	if err != nil {
 		utils.ResponseError(w, http.StatusInternalServerError,
@@ -133,3 +203,32 @@ func (ic *ItemsController) DeleteItem(w http.ResponseWriter, r *http.Request) {
 
 	utils.RespondJSON(w, http.StatusOK, map[string]string{"msg": "item deleted successfully"})
 }
+
+// MoveItem places an item before or after another item, optionally in
+// another category of the same event.
+func (ic *ItemsController) MoveItem(w http.ResponseWriter, r *http.Request) {
+	itemID := chi.URLParam(r, "itemID")
+	if itemID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing itemID in URL"})
+		return
+	}
+	var placement items.Placement
+	if err := json.NewDecoder(r.Body).Decode(&placement); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "invalid JSON body"})
+		return
+	}
+	if !authorizeItem(w, r, ic.IS, r.URL.Query().Get("user_id"), itemID) || !authorizeCategory(w, r, ic.IS, r.URL.Query().Get("user_id"), placement.CategoryID) {
+		return
+	}
+	err := ic.IS.MoveItem(r.Context(), itemID, placement)
+	if errors.Is(err, items.ErrInvalidPlacement) || errors.Is(err, items.ErrCategoryNotInEvent) {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: err.Error()})
+		return
+	}
+	if err != nil {
+		utils.ResponseError(w, http.StatusInternalServerError,
+			utils.JSONError{Msg: fmt.Sprintf("failed to move item: %s", err.Error())})
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"msg": "item moved successfully"})
+}
@@ -0,0 +1,132 @@
+package items
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sunnymotiani/PackTrack/server/models/items"
+	"github.com/sunnymotiani/PackTrack/server/utils"
+)
+
+// AddCategoryLead makes the member in the URL a lead of the category. The
+// user_id query parameter names the admin doing it.
+func (cc *CategoriesController) AddCategoryLead(w http.ResponseWriter, r *http.Request) {
+	catID, leadID := chi.URLParam(r, "catID"), chi.URLParam(r, "leadID")
+	if catID == "" || leadID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing categoryID or leadID in URL"})
+		return
+	}
+	err := cc.IS.AddCategoryLead(r.Context(), catID, r.URL.Query().Get("user_id"), leadID)
+	if err != nil {
+		respondCategoryError(w, "failed to add category lead:", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"msg": "category lead added successfully"})
+}
+
+// RemoveCategoryLead takes the member in the URL off the category's leads.
+func (cc *CategoriesController) RemoveCategoryLead(w http.ResponseWriter, r *http.Request) {
+	catID, leadID := chi.URLParam(r, "catID"), chi.URLParam(r, "leadID")
+	if catID == "" || leadID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing categoryID or leadID in URL"})
+		return
+	}
+	err := cc.IS.RemoveCategoryLead(r.Context(), catID, r.URL.Query().Get("user_id"), leadID)
+	if err != nil {
+		respondCategoryError(w, "failed to remove category lead:", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, map[string]string{"msg": "category lead removed successfully"})
+}
+
+// GetCategoryLeads lists the leads of every category of an event.
+func (cc *CategoriesController) GetCategoryLeads(w http.ResponseWriter, r *http.Request) {
+	eventID := chi.URLParam(r, "eventID")
+	if eventID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing eventID in URL"})
+		return
+	}
+	leads, err := cc.IS.GetCategoryLeads(r.Context(), eventID)
+	if err != nil {
+		respondCategoryError(w, "err fetching category leads", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, leads)
+}
+
+// GetMyCategories returns the categories of an event led by the user given
+// by the user_id query parameter.
+func (cc *CategoriesController) GetMyCategories(w http.ResponseWriter, r *http.Request) {
+	eventID, userID := chi.URLParam(r, "eventID"), r.URL.Query().Get("user_id")
+	if eventID == "" || userID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing eventID or user_id"})
+		return
+	}
+	mine, err := cc.IS.GetMyCategories(r.Context(), eventID, userID)
+	if err != nil {
+		respondCategoryError(w, "err fetching my categories", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, mine)
+}
+
+// authorizeItem checks that userID, the acting user, is given and may
+// manage the item. It answers the request and returns false otherwise.
+func authorizeItem(w http.ResponseWriter, r *http.Request, is *items.ItemsService, userID string, itemIDs ...string) bool {
+	return authorizeItemWith(w, r, is.CanManageItem, userID, itemIDs...)
+}
+
+// authorizeItemWith is authorizeItem with another check, such as
+// CanActOnItem for members working on the items they hold.
+func authorizeItemWith(w http.ResponseWriter, r *http.Request, check func(context.Context, string, string) error,
+	userID string, itemIDs ...string) bool {
+	if userID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "user_id is required"})
+		return false
+	}
+	for _, id := range itemIDs {
+		if !respondManageError(w, check(r.Context(), id, userID)) {
+			return false
+		}
+	}
+	return true
+}
+
+// authorizeCategory is authorizeItem for categories. Empty ids are skipped,
+// so optional targets can be passed as they are.
+func authorizeCategory(w http.ResponseWriter, r *http.Request, is *items.ItemsService, userID string, categoryIDs ...string) bool {
+	if userID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "user_id is required"})
+		return false
+	}
+	for _, id := range categoryIDs {
+		if id == "" {
+			continue
+		}
+		if !respondManageError(w, is.CanManageCategory(r.Context(), id, userID)) {
+			return false
+		}
+	}
+	return true
+}
+
+func respondManageError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, items.ErrCannotManage), errors.Is(err, items.ErrNotEventMember):
+		utils.ResponseError(w, http.StatusForbidden, utils.JSONError{Msg: err.Error()})
+	case errors.Is(err, items.ErrCategoryNotFound):
+		utils.ResponseError(w, http.StatusNotFound, utils.JSONError{Msg: err.Error()})
+	case errors.Is(err, sql.ErrNoRows):
+		utils.ResponseError(w, http.StatusNotFound, utils.JSONError{Msg: "item not found"})
+	default:
+		utils.ResponseError(w, http.StatusInternalServerError,
+			utils.JSONError{Msg: fmt.Sprintf("err checking permissions %s", err.Error())})
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package items
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/sunnymotiani/PackTrack/server/models/items"
+	"github.com/sunnymotiani/PackTrack/server/utils"
+)
+
+func (ic *ItemStatusController) RecordReturn(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		ItemID string `json:"item_id"`
+		UserID string `json:"user_id"`
+		items.ReturnInput
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "err bad request"})
+		return
+	}
+	if !authorizeItemWith(w, r, ic.IS.CanActOnItem, input.UserID, input.ItemID) {
+		return
+	}
+	progress, err := ic.IS.RecordReturn(r.Context(), input.ItemID, input.UserID, input.ReturnInput)
+	if errors.Is(err, items.ErrInvalidReturn) {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: err.Error()})
+		return
+	}
+	if err != nil {
+		utils.ResponseError(w, http.StatusInternalServerError,
+			utils.JSONError{Msg: fmt.Sprintf("err recording item return %s", err.Error())})
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, progress)
+}
@@ -0,0 +1,92 @@
+package labels
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sunnymotiani/PackTrack/server/models/items"
+	"github.com/sunnymotiani/PackTrack/server/models/labels"
+	"github.com/sunnymotiani/PackTrack/server/utils"
+)
+
+type LabelsController struct {
+	LS *labels.LabelsService
+}
+
+// GetLabelSheet returns a PDF of labels for an event. The kind query
+// parameter limits it to items or containers and ids, a comma-separated
+// list, to particular ones; format=json lists the labels instead.
+func (lc *LabelsController) GetLabelSheet(w http.ResponseWriter, r *http.Request) {
+	eventID := chi.URLParam(r, "eventID")
+	if eventID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing eventID in URL"})
+		return
+	}
+	q := r.URL.Query()
+	var ids []string
+	if v := q.Get("ids"); v != "" {
+		ids = strings.Split(v, ",")
+	}
+	sheet, err := lc.LS.GetLabels(r.Context(), eventID, q.Get("kind"), ids)
+	if err != nil {
+		respondLabelError(w, "err fetching labels", err)
+		return
+	}
+
+	switch q.Get("format") {
+	case "json":
+		utils.RespondJSON(w, http.StatusOK, sheet)
+	case "", "pdf":
+		var buf bytes.Buffer
+		if err := labels.WriteLabelSheet(&buf, sheet); err != nil {
+			respondLabelError(w, "err rendering labels", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"labels-%s.pdf\"", eventID))
+		w.WriteHeader(http.StatusOK)
+		w.Write(buf.Bytes())
+	default:
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "unsupported format, use pdf or json"})
+	}
+}
+
+// Scan resolves a scanned label for the member given by user_id.
+func (lc *LabelsController) Scan(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Code   string `json:"code"`
+		UserID string `json:"user_id"`
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "invalid request"})
+		return
+	}
+	result, err := lc.LS.Scan(r.Context(), input.Code, input.UserID, input.Status)
+	if err != nil {
+		respondLabelError(w, "err scanning label", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, result)
+}
+
+func respondLabelError(w http.ResponseWriter, msg string, err error) {
+	switch {
+	case errors.Is(err, labels.ErrInvalidKind), errors.Is(err, labels.ErrInvalidCode),
+		errors.Is(err, labels.ErrInvalidStatus):
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: err.Error()})
+	case errors.Is(err, labels.ErrCannotScan), errors.Is(err, items.ErrNotEventMember),
+		errors.Is(err, items.ErrCannotManage):
+		utils.ResponseError(w, http.StatusForbidden, utils.JSONError{Msg: err.Error()})
+	case errors.Is(err, labels.ErrNotFound):
+		utils.ResponseError(w, http.StatusNotFound, utils.JSONError{Msg: err.Error()})
+	default:
+		utils.ResponseError(w, http.StatusInternalServerError,
+			utils.JSONError{Msg: fmt.Sprintf("%s %s", msg, err.Error())})
+	}
+}
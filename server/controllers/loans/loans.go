@@ -0,0 +1,113 @@
+package loans
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sunnymotiani/PackTrack/server/models/loans"
+	"github.com/sunnymotiani/PackTrack/server/utils"
+)
+
+type LoansController struct {
+	LS *loans.LoansService
+}
+
+func (lc *LoansController) CreateLoan(w http.ResponseWriter, r *http.Request) {
+	var input loans.Loan
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "invalid request"})
+		return
+	}
+	if err := lc.LS.CreateLoan(r.Context(), &input); err != nil {
+		respondLoanError(w, "err creating loan", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, input)
+}
+
+func (lc *LoansController) CloseLoan(w http.ResponseWriter, r *http.Request) {
+	loanID := chi.URLParam(r, "loanID")
+	if loanID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing loanID in URL"})
+		return
+	}
+	var input struct {
+		State string  `json:"state"`
+		Notes *string `json:"notes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "invalid request"})
+		return
+	}
+	if err := lc.LS.CloseLoan(r.Context(), loanID, input.State, input.Notes); err != nil {
+		respondLoanError(w, "err closing loan", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, nil)
+}
+
+func (lc *LoansController) SetDueDate(w http.ResponseWriter, r *http.Request) {
+	loanID := chi.URLParam(r, "loanID")
+	if loanID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing loanID in URL"})
+		return
+	}
+	var input struct {
+		DueAt *time.Time `json:"due_at"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "invalid request"})
+		return
+	}
+	if err := lc.LS.SetDueDate(r.Context(), loanID, input.DueAt); err != nil {
+		respondLoanError(w, "err setting loan due date", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, nil)
+}
+
+func (lc *LoansController) GetUserLoans(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "userID")
+	if userID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing userID in URL"})
+		return
+	}
+	includeClosed := r.URL.Query().Get("include_closed") == "true"
+	ledger, err := lc.LS.GetUserLoans(r.Context(), userID, includeClosed)
+	if err != nil {
+		respondLoanError(w, "err fetching loans", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, ledger)
+}
+
+func (lc *LoansController) GetOverdueLoans(w http.ResponseWriter, r *http.Request) {
+	overdue, err := lc.LS.GetOverdueLoans(r.Context(), chi.URLParam(r, "eventID"))
+	if err != nil {
+		respondLoanError(w, "err fetching overdue loans", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, overdue)
+}
+
+func respondLoanError(w http.ResponseWriter, msg string, err error) {
+	switch {
+	case errors.Is(err, loans.ErrInvalidState), errors.Is(err, loans.ErrSelfLoan),
+		errors.Is(err, loans.ErrNotEventMember), errors.Is(err, loans.ErrNoDescription),
+		errors.Is(err, loans.ErrItemNotInEvent):
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: err.Error()})
+	case errors.Is(err, loans.ErrNotGearOwner):
+		utils.ResponseError(w, http.StatusForbidden, utils.JSONError{Msg: err.Error()})
+	case errors.Is(err, loans.ErrLoanNotFound), errors.Is(err, loans.ErrNoSuchItem):
+		utils.ResponseError(w, http.StatusNotFound, utils.JSONError{Msg: err.Error()})
+	case errors.Is(err, loans.ErrLoanClosed), errors.Is(err, loans.ErrGearOnLoan):
+		utils.ResponseError(w, http.StatusConflict, utils.JSONError{Msg: err.Error()})
+	default:
+		utils.ResponseError(w, http.StatusInternalServerError,
+			utils.JSONError{Msg: fmt.Sprintf("%s %s", msg, err.Error())})
+	}
+}
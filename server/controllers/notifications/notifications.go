@@ -0,0 +1,117 @@
+package notifications
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sunnymotiani/PackTrack/server/models/notifications"
+	"github.com/sunnymotiani/PackTrack/server/utils"
+)
+
+type NotificationsController struct {
+	NS *notifications.NotificationsService
+}
+
+func (nc *NotificationsController) GetInbox(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "userID")
+	if userID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing userID in URL"})
+		return
+	}
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "invalid limit"})
+			return
+		}
+		limit = n
+	}
+	unreadOnly := r.URL.Query().Get("unread") == "true"
+	inbox, err := nc.NS.GetInbox(r.Context(), userID, unreadOnly, limit)
+	if err != nil {
+		respondNotificationError(w, "err fetching inbox", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, inbox)
+}
+
+// SetReadState marks notifications read or unread; with all set it marks
+// every notification of the user read.
+func (nc *NotificationsController) SetReadState(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "userID")
+	if userID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing userID in URL"})
+		return
+	}
+	var input struct {
+		IDs  []string `json:"ids"`
+		Read bool     `json:"read"`
+		All  bool     `json:"all"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "invalid request"})
+		return
+	}
+	var err error
+	switch {
+	case input.All:
+		err = nc.NS.MarkAllRead(r.Context(), userID)
+	case input.Read:
+		err = nc.NS.MarkRead(r.Context(), userID, input.IDs)
+	default:
+		err = nc.NS.MarkUnread(r.Context(), userID, input.IDs)
+	}
+	if err != nil {
+		respondNotificationError(w, "err updating read state", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, nil)
+}
+
+func (nc *NotificationsController) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "userID")
+	if userID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing userID in URL"})
+		return
+	}
+	prefs, err := nc.NS.GetPreferences(r.Context(), userID)
+	if err != nil {
+		respondNotificationError(w, "err fetching preferences", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, prefs)
+}
+
+func (nc *NotificationsController) SetPreferences(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "userID")
+	if userID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing userID in URL"})
+		return
+	}
+	var prefs notifications.Preferences
+	if err := json.NewDecoder(r.Body).Decode(&prefs); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "invalid request"})
+		return
+	}
+	prefs.UserID = userID
+	if err := nc.NS.SetPreferences(r.Context(), prefs); err != nil {
+		respondNotificationError(w, "err saving preferences", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, prefs)
+}
+
+func respondNotificationError(w http.ResponseWriter, msg string, err error) {
+	switch {
+	case errors.Is(err, notifications.ErrNoNotifications):
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: err.Error()})
+	default:
+		utils.ResponseError(w, http.StatusInternalServerError,
+			utils.JSONError{Msg: fmt.Sprintf("%s %s", msg, err.Error())})
+	}
+}
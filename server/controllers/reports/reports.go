@@ -0,0 +1,136 @@
+package reports
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sunnymotiani/PackTrack/server/models/items"
+	"github.com/sunnymotiani/PackTrack/server/models/reports"
+	"github.com/sunnymotiani/PackTrack/server/utils"
+)
+
+type ReportsController struct {
+	RS *reports.ReportsService
+}
+
+func (rc *ReportsController) GetMemberContributions(w http.ResponseWriter, r *http.Request) {
+	eventID := chi.URLParam(r, "eventID")
+	if eventID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing eventID in URL"})
+		return
+	}
+
+	report, err := rc.RS.GetMemberContributions(r.Context(), eventID)
+	if err != nil {
+		utils.ResponseError(w, http.StatusInternalServerError,
+			utils.JSONError{Msg: fmt.Sprintf("err fetching contributions %s", err.Error())})
+		return
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		utils.RespondJSON(w, http.StatusOK, report)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"contributions-%s.csv\"", eventID))
+		w.WriteHeader(http.StatusOK)
+		reports.WriteContributionsCSV(w, report)
+	default:
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "unsupported format, use json or csv"})
+	}
+}
+
+func (rc *ReportsController) GetLoadTotals(w http.ResponseWriter, r *http.Request) {
+	eventID := chi.URLParam(r, "eventID")
+	if eventID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing eventID in URL"})
+		return
+	}
+
+	q := r.URL.Query()
+	totals, err := rc.RS.GetLoadTotals(r.Context(), eventID, q.Get("weight_unit"), q.Get("volume_unit"))
+	if errors.Is(err, items.ErrUnknownUnit) {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: err.Error()})
+		return
+	}
+	if err != nil {
+		utils.ResponseError(w, http.StatusInternalServerError,
+			utils.JSONError{Msg: fmt.Sprintf("err fetching load totals %s", err.Error())})
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, totals)
+}
+
+func (rc *ReportsController) GetReconciliation(w http.ResponseWriter, r *http.Request) {
+	eventID := chi.URLParam(r, "eventID")
+	if eventID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing eventID in URL"})
+		return
+	}
+
+	report, err := rc.RS.GetReconciliation(r.Context(), eventID)
+	if err != nil {
+		utils.ResponseError(w, http.StatusInternalServerError,
+			utils.JSONError{Msg: fmt.Sprintf("err fetching reconciliation %s", err.Error())})
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, report)
+}
+
+// ExportPackingList renders an event's packing list as json, csv, md or pdf,
+// grouped by category or by assignee.
+func (rc *ReportsController) ExportPackingList(w http.ResponseWriter, r *http.Request) {
+	eventID := chi.URLParam(r, "eventID")
+	if eventID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing eventID in URL"})
+		return
+	}
+
+	q := r.URL.Query()
+	list, err := rc.RS.GetPackingList(r.Context(), eventID, q.Get("group_by"))
+	switch {
+	case errors.Is(err, reports.ErrInvalidGroupBy):
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: err.Error()})
+		return
+	case errors.Is(err, reports.ErrEventNotFound):
+		utils.ResponseError(w, http.StatusNotFound, utils.JSONError{Msg: err.Error()})
+		return
+	case err != nil:
+		utils.ResponseError(w, http.StatusInternalServerError,
+			utils.JSONError{Msg: fmt.Sprintf("err fetching packing list %s", err.Error())})
+		return
+	}
+
+	filename := fmt.Sprintf("packing-list-%s", eventID)
+	switch q.Get("format") {
+	case "", "json":
+		utils.RespondJSON(w, http.StatusOK, list)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.csv\"", filename))
+		w.WriteHeader(http.StatusOK)
+		reports.WritePackingListCSV(w, list)
+	case "md":
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.md\"", filename))
+		w.WriteHeader(http.StatusOK)
+		reports.WritePackingListMarkdown(w, list)
+	case "pdf":
+		// Render first so a failure can still be reported as an error.
+		var buf bytes.Buffer
+		if err := reports.WritePackingListPDF(&buf, list); err != nil {
+			utils.ResponseError(w, http.StatusInternalServerError,
+				utils.JSONError{Msg: fmt.Sprintf("err rendering pdf %s", err.Error())})
+			return
+		}
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.pdf\"", filename))
+		w.WriteHeader(http.StatusOK)
+		w.Write(buf.Bytes())
+	default:
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "unsupported format, use json, csv, md or pdf"})
+	}
+}
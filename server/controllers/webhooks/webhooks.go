@@ -0,0 +1,150 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sunnymotiani/PackTrack/server/models/webhooks"
+	"github.com/sunnymotiani/PackTrack/server/utils"
+)
+
+type WebhooksController struct {
+	WS *webhooks.WebhooksService
+}
+
+func (wc *WebhooksController) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	eventID := chi.URLParam(r, "eventID")
+	if eventID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing eventID in URL"})
+		return
+	}
+	var input struct {
+		webhooks.Webhook
+		UserID string `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "invalid request"})
+		return
+	}
+	hook := input.Webhook
+	hook.EventID = eventID
+	if err := wc.WS.CreateWebhook(r.Context(), input.UserID, &hook); err != nil {
+		respondWebhookError(w, "err creating webhook", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, hook)
+}
+
+func (wc *WebhooksController) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	eventID := chi.URLParam(r, "eventID")
+	if eventID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing eventID in URL"})
+		return
+	}
+	hooks, err := wc.WS.GetWebhooks(r.Context(), r.URL.Query().Get("user_id"), eventID)
+	if err != nil {
+		respondWebhookError(w, "err fetching webhooks", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, hooks)
+}
+
+func (wc *WebhooksController) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	webhookID := chi.URLParam(r, "webhookID")
+	if webhookID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing webhookID in URL"})
+		return
+	}
+	var input struct {
+		webhooks.WebhookUpdate
+		UserID string `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "invalid request"})
+		return
+	}
+	if err := wc.WS.UpdateWebhook(r.Context(), input.UserID, webhookID, input.WebhookUpdate); err != nil {
+		respondWebhookError(w, "err updating webhook", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, nil)
+}
+
+func (wc *WebhooksController) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	webhookID := chi.URLParam(r, "webhookID")
+	if webhookID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing webhookID in URL"})
+		return
+	}
+	if err := wc.WS.DeleteWebhook(r.Context(), r.URL.Query().Get("user_id"), webhookID); err != nil {
+		respondWebhookError(w, "err deleting webhook", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, nil)
+}
+
+func (wc *WebhooksController) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	webhookID := chi.URLParam(r, "webhookID")
+	if webhookID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing webhookID in URL"})
+		return
+	}
+	deliveries, err := wc.WS.GetDeliveries(r.Context(), r.URL.Query().Get("user_id"), webhookID, r.URL.Query().Get("state"))
+	if err != nil {
+		respondWebhookError(w, "err fetching deliveries", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, deliveries)
+}
+
+func (wc *WebhooksController) GetDeadLetters(w http.ResponseWriter, r *http.Request) {
+	eventID := chi.URLParam(r, "eventID")
+	if eventID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing eventID in URL"})
+		return
+	}
+	deliveries, err := wc.WS.GetDeadLetters(r.Context(), r.URL.Query().Get("user_id"), eventID)
+	if err != nil {
+		respondWebhookError(w, "err fetching dead letters", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, deliveries)
+}
+
+func (wc *WebhooksController) Redeliver(w http.ResponseWriter, r *http.Request) {
+	deliveryID := chi.URLParam(r, "deliveryID")
+	if deliveryID == "" {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "missing deliveryID in URL"})
+		return
+	}
+	var input struct {
+		UserID string `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: "invalid request"})
+		return
+	}
+	if err := wc.WS.Redeliver(r.Context(), input.UserID, deliveryID); err != nil {
+		respondWebhookError(w, "err queueing redelivery", err)
+		return
+	}
+	utils.RespondJSON(w, http.StatusOK, nil)
+}
+
+func respondWebhookError(w http.ResponseWriter, msg string, err error) {
+	switch {
+	case errors.Is(err, webhooks.ErrInvalidURL), errors.Is(err, webhooks.ErrInternalURL), errors.Is(err, webhooks.ErrInvalidEventType),
+		errors.Is(err, webhooks.ErrNoEventTypes), errors.Is(err, webhooks.ErrInvalidDeliveryState):
+		utils.ResponseError(w, http.StatusBadRequest, utils.JSONError{Msg: err.Error()})
+	case errors.Is(err, webhooks.ErrNotEventAdmin):
+		utils.ResponseError(w, http.StatusForbidden, utils.JSONError{Msg: err.Error()})
+	case errors.Is(err, webhooks.ErrWebhookNotFound), errors.Is(err, webhooks.ErrDeliveryNotFound):
+		utils.ResponseError(w, http.StatusNotFound, utils.JSONError{Msg: err.Error()})
+	default:
+		utils.ResponseError(w, http.StatusInternalServerError,
+			utils.JSONError{Msg: fmt.Sprintf("%s %s", msg, err.Error())})
+	}
+}
@@ -0,0 +1,56 @@
+package activity
+
+import (
+	"context"
+	"time"
+)
+
+// Types of activity services publish.
+const (
+	TypeAssignment    = "assignment"
+	TypeStatusChange  = "status_change"
+	TypeMembership    = "membership"
+	TypeMemberRemoved = "member_removed"
+	TypeInvitation    = "invitation"
+	TypeReminder      = "reminder"
+)
+
+// Activity is something that happened in an event. Recipients are the users
+// it concerns; publishers decide what to do with it.
+type Activity struct {
+	Type       string                 `json:"type"`
+	EventID    string                 `json:"event_id"`
+	ItemID     string                 `json:"item_id,omitempty"`
+	ActorID    string                 `json:"actor_id,omitempty"`
+	Recipients []string               `json:"recipients,omitempty"`
+	Message    string                 `json:"message"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+	OccurredAt time.Time              `json:"occurred_at"`
+}
+
+// Publisher receives activity after the change it describes is committed.
+// Publishing must not fail the change, so implementations handle their own
+// errors.
+type Publisher interface {
+	Publish(ctx context.Context, a Activity)
+}
+
+// Publishers fans activity out to several publishers.
+type Publishers []Publisher
+
+func (ps Publishers) Publish(ctx context.Context, a Activity) {
+	for _, p := range ps {
+		p.Publish(ctx, a)
+	}
+}
+
+// Publish stamps the activity and hands it to p; a nil publisher drops it.
+func Publish(ctx context.Context, p Publisher, a Activity) {
+	if p == nil {
+		return
+	}
+	if a.OccurredAt.IsZero() {
+		a.OccurredAt = time.Now()
+	}
+	p.Publish(ctx, a)
+}
@@ -0,0 +1,338 @@
+package archive
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/sunnymotiani/PackTrack/server/models/events"
+	"github.com/sunnymotiani/PackTrack/server/models/items"
+	"github.com/sunnymotiani/PackTrack/server/models/templates"
+	"github.com/sunnymotiani/PackTrack/server/models/users"
+)
+
+// Format identifies PackTrack event bundles; Version is bumped whenever the
+// bundle layout changes in a way older importers cannot read.
+const (
+	Format  = "packtrack.event"
+	Version = 1
+)
+
+var ErrEventNotFound = errors.New("event not found")
+
+// ArchiveService exports an event with its packing list as a portable bundle
+// and imports such bundles as new events. Users are identified by email so a
+// bundle can move between instances; ids in a bundle only link its parts.
+type ArchiveService struct {
+	DB        *sql.DB
+	Templates *templates.TemplatesService
+}
+
+type Bundle struct {
+	Format     string           `json:"format"`
+	Version    int              `json:"version"`
+	ExportedAt time.Time        `json:"exported_at"`
+	Event      BundleEvent      `json:"event"`
+	Members    []BundleMember   `json:"members"`
+	Categories []BundleCategory `json:"categories"`
+	Items      []BundleItem     `json:"items"`
+	History    []BundleHistory  `json:"history"`
+	Templates  []BundleTemplate `json:"templates"`
+}
+
+type BundleEvent struct {
+	ID              string     `json:"id"`
+	Name            string     `json:"name"`
+	Description     string     `json:"description,omitempty"`
+	OwnerEmail      string     `json:"owner_email,omitempty"`
+	StartsAt        *time.Time `json:"starts_at,omitempty"`
+	EndsAt          *time.Time `json:"ends_at,omitempty"`
+	PackingDeadline *time.Time `json:"packing_deadline,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+type BundleMember struct {
+	Email string `json:"email"`
+	Name  string `json:"name"`
+	Role  string `json:"role"`
+}
+
+type BundleCategory struct {
+	ID       string `json:"id"`
+	ParentID string `json:"parent_id,omitempty"`
+	Name     string `json:"name"`
+}
+
+// BundleItem carries weights in grams and volumes in millilitres with the
+// units they are shown in.
+type BundleItem struct {
+	ID                string             `json:"id"`
+	CategoryID        string             `json:"category_id"`
+	Name              string             `json:"name"`
+	Quantity          int                `json:"quantity"`
+	PackedQuantity    int                `json:"packed_quantity"`
+	DeliveredQuantity int                `json:"delivered_quantity"`
+	ReturnedQuantity  int                `json:"returned_quantity"`
+	MissingQuantity   int                `json:"missing_quantity"`
+	DamagedQuantity   int                `json:"damaged_quantity"`
+	ReturnNotes       *string            `json:"return_notes,omitempty"`
+	Status            string             `json:"status"`
+	Notes             *string            `json:"notes,omitempty"`
+	WeightG           *float64           `json:"weight_g,omitempty"`
+	VolumeML          *float64           `json:"volume_ml,omitempty"`
+	WeightUnit        string             `json:"weight_unit,omitempty"`
+	VolumeUnit        string             `json:"volume_unit,omitempty"`
+	DueAt             *time.Time         `json:"due_at,omitempty"`
+	CreatedAt         time.Time          `json:"created_at"`
+	Assignments       []BundleAssignment `json:"assignments"`
+}
+
+type BundleAssignment struct {
+	Email             string `json:"email"`
+	Quantity          int    `json:"quantity"`
+	PackedQuantity    int    `json:"packed_quantity"`
+	DeliveredQuantity int    `json:"delivered_quantity"`
+	Status            string `json:"status"`
+}
+
+type BundleHistory struct {
+	ItemID         string    `json:"item_id"`
+	UserEmail      string    `json:"user_email,omitempty"`
+	OldStatus      string    `json:"old_status"`
+	NewStatus      string    `json:"new_status"`
+	PackedDelta    int       `json:"packed_delta"`
+	DeliveredDelta int       `json:"delivered_delta"`
+	ReturnedDelta  int       `json:"returned_delta"`
+	MissingDelta   int       `json:"missing_delta"`
+	DamagedDelta   int       `json:"damaged_delta"`
+	Notes          *string   `json:"notes,omitempty"`
+	ChangedAt      time.Time `json:"changed_at"`
+}
+
+type BundleTemplate struct {
+	Name      string               `json:"name"`
+	AppliedAt time.Time            `json:"applied_at"`
+	Items     []BundleTemplateItem `json:"items"`
+}
+
+type BundleTemplateItem struct {
+	Category   string   `json:"category"`
+	Name       string   `json:"name"`
+	Quantity   int      `json:"quantity"`
+	WeightG    *float64 `json:"weight_g,omitempty"`
+	VolumeML   *float64 `json:"volume_ml,omitempty"`
+	WeightUnit string   `json:"weight_unit,omitempty"`
+	VolumeUnit string   `json:"volume_unit,omitempty"`
+}
+
+// ExportEvent builds the bundle of an event. Everything is read in one
+// repeatable read transaction so the bundle is a consistent snapshot.
+func (as *ArchiveService) ExportEvent(ctx context.Context, eventID string) (*Bundle, error) {
+	tx, err := as.DB.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("error beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	b := &Bundle{
+		Format:     Format,
+		Version:    Version,
+		ExportedAt: time.Now().UTC(),
+		Members:    []BundleMember{},
+		Categories: []BundleCategory{},
+		Items:      []BundleItem{},
+		History:    []BundleHistory{},
+		Templates:  []BundleTemplate{},
+	}
+	if err := as.exportEvent(ctx, tx, eventID, b); err != nil {
+		return nil, err
+	}
+	if err := as.exportMembers(ctx, tx, eventID, b); err != nil {
+		return nil, err
+	}
+	if err := as.exportCategories(ctx, tx, eventID, b); err != nil {
+		return nil, err
+	}
+	if err := as.exportItems(ctx, tx, eventID, b); err != nil {
+		return nil, err
+	}
+	if err := as.exportHistory(ctx, tx, eventID, b); err != nil {
+		return nil, err
+	}
+
+	applied, err := templates.GetEventTemplatesTx(ctx, tx, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching event templates: %w", err)
+	}
+	for _, a := range applied {
+		t := BundleTemplate{Name: a.Name, AppliedAt: a.AppliedAt, Items: []BundleTemplateItem{}}
+		for _, ti := range a.Items {
+			t.Items = append(t.Items, BundleTemplateItem{
+				Category: ti.Category, Name: ti.Name, Quantity: ti.Quantity,
+				WeightG: ti.WeightG, VolumeML: ti.VolumeML, WeightUnit: ti.WeightUnit, VolumeUnit: ti.VolumeUnit,
+			})
+		}
+		b.Templates = append(b.Templates, t)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (as *ArchiveService) exportEvent(ctx context.Context, tx *sql.Tx, eventID string, b *Bundle) error {
+	query := sq.Select("e.id", "e.name", "e.description", "u.email", "e.starts_at", "e.ends_at",
+		"e.packing_deadline", "e.created_at").
+		From(events.TableEvents + " e").
+		LeftJoin(users.TableUsers + " u ON u.id = e.owner_id").
+		Where(sq.Eq{"e.id": eventID}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building export event query: %w", err)
+	}
+	var description, ownerEmail sql.NullString
+	err = tx.QueryRowContext(ctx, sqlStr, args...).Scan(&b.Event.ID, &b.Event.Name, &description, &ownerEmail,
+		&b.Event.StartsAt, &b.Event.EndsAt, &b.Event.PackingDeadline, &b.Event.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrEventNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("error fetching event: %w", err)
+	}
+	b.Event.Description, b.Event.OwnerEmail = description.String, ownerEmail.String
+	return nil
+}
+
+func (as *ArchiveService) exportMembers(ctx context.Context, tx *sql.Tx, eventID string, b *Bundle) error {
+	query := sq.Select("u.email", "u.name", "em.role").
+		From(events.TableEventMemberships + " em").
+		Join(users.TableUsers + " u ON u.id = em.user_id").
+		Where(sq.Eq{"em.event_id": eventID}).
+		OrderBy("u.email ASC")
+	return as.scanRows(ctx, tx, query, "members", func(rows *sql.Rows) error {
+		var m BundleMember
+		if err := rows.Scan(&m.Email, &m.Name, &m.Role); err != nil {
+			return err
+		}
+		b.Members = append(b.Members, m)
+		return nil
+	})
+}
+
+func (as *ArchiveService) exportCategories(ctx context.Context, tx *sql.Tx, eventID string, b *Bundle) error {
+	query := sq.Select("id", "COALESCE(parent_id::text, '')", "name").
+		From(items.TableCategories).
+		Where(sq.Eq{"event_id": eventID}).
+		OrderBy("position ASC", "id ASC")
+	return as.scanRows(ctx, tx, query, "categories", func(rows *sql.Rows) error {
+		var c BundleCategory
+		if err := rows.Scan(&c.ID, &c.ParentID, &c.Name); err != nil {
+			return err
+		}
+		b.Categories = append(b.Categories, c)
+		return nil
+	})
+}
+
+func (as *ArchiveService) exportItems(ctx context.Context, tx *sql.Tx, eventID string, b *Bundle) error {
+	query := sq.Select("i.id", "i.category_id", "i.name", "i.quantity", "i.packed_quantity", "i.delivered_quantity",
+		"i.returned_quantity", "i.missing_quantity", "i.damaged_quantity", "i.return_notes", "i.status", "i.notes",
+		"i.weight_g", "i.volume_ml", "i.weight_unit", "i.volume_unit", "i.due_at", "i.created_at").
+		From(items.TableItems+" i").
+		Join(items.TableCategories+" c ON c.id = i.category_id").
+		Where(sq.Eq{"c.event_id": eventID}).
+		OrderBy("c.position ASC", "c.id ASC", "i.position ASC", "i.id ASC")
+
+	index := map[string]int{}
+	err := as.scanRows(ctx, tx, query, "items", func(rows *sql.Rows) error {
+		var it BundleItem
+		var quantity sql.NullInt64
+		var status, weightUnit, volumeUnit sql.NullString
+		if err := rows.Scan(&it.ID, &it.CategoryID, &it.Name, &quantity, &it.PackedQuantity, &it.DeliveredQuantity,
+			&it.ReturnedQuantity, &it.MissingQuantity, &it.DamagedQuantity, &it.ReturnNotes, &status, &it.Notes,
+			&it.WeightG, &it.VolumeML, &weightUnit, &volumeUnit, &it.DueAt, &it.CreatedAt); err != nil {
+			return err
+		}
+		it.Quantity, it.Status = int(quantity.Int64), status.String
+		it.WeightUnit, it.VolumeUnit = weightUnit.String, volumeUnit.String
+		it.Assignments = []BundleAssignment{}
+		index[it.ID] = len(b.Items)
+		b.Items = append(b.Items, it)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	assignments := sq.Select("ia.item_id", "u.email", "ia.quantity", "ia.packed_quantity", "ia.delivered_quantity",
+		"ia.status").
+		From(items.TableItemAssignments+" ia").
+		Join(items.TableItems+" i ON i.id = ia.item_id").
+		Join(items.TableCategories+" c ON c.id = i.category_id").
+		Join(users.TableUsers+" u ON u.id = ia.user_id").
+		Where(sq.Eq{"c.event_id": eventID}).
+		OrderBy("ia.created_at ASC", "ia.id ASC")
+	return as.scanRows(ctx, tx, assignments, "assignments", func(rows *sql.Rows) error {
+		var itemID string
+		var a BundleAssignment
+		if err := rows.Scan(&itemID, &a.Email, &a.Quantity, &a.PackedQuantity, &a.DeliveredQuantity, &a.Status); err != nil {
+			return err
+		}
+		i, ok := index[itemID]
+		if !ok {
+			// Only assignments of exported items belong in the bundle.
+			return nil
+		}
+		it := &b.Items[i]
+		it.Assignments = append(it.Assignments, a)
+		return nil
+	})
+}
+
+func (as *ArchiveService) exportHistory(ctx context.Context, tx *sql.Tx, eventID string, b *Bundle) error {
+	query := sq.Select("h.item_id", "u.email", "h.old_status", "h.new_status", "h.packed_delta", "h.delivered_delta",
+		"h.returned_delta", "h.missing_delta", "h.damaged_delta", "h.notes", "h.changed_at").
+		From(items.TableItemStatusHistory+" h").
+		Join(items.TableItems+" i ON i.id = h.item_id").
+		Join(items.TableCategories+" c ON c.id = i.category_id").
+		LeftJoin(users.TableUsers+" u ON u.id = h.user_id").
+		Where(sq.Eq{"c.event_id": eventID}).
+		OrderBy("h.changed_at ASC", "h.id ASC")
+	return as.scanRows(ctx, tx, query, "history", func(rows *sql.Rows) error {
+		var h BundleHistory
+		var email, oldStatus, newStatus sql.NullString
+		if err := rows.Scan(&h.ItemID, &email, &oldStatus, &newStatus, &h.PackedDelta, &h.DeliveredDelta,
+			&h.ReturnedDelta, &h.MissingDelta, &h.DamagedDelta, &h.Notes, &h.ChangedAt); err != nil {
+			return err
+		}
+		h.UserEmail, h.OldStatus, h.NewStatus = email.String, oldStatus.String, newStatus.String
+		b.History = append(b.History, h)
+		return nil
+	})
+}
+
+func (as *ArchiveService) scanRows(ctx context.Context, tx *sql.Tx, query sq.SelectBuilder, what string, scan func(*sql.Rows) error) error {
+	sqlStr, args, err := query.PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return fmt.Errorf("error building export %s query: %w", what, err)
+	}
+	rows, err := tx.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return fmt.Errorf("error querying %s: %w", what, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return fmt.Errorf("error scanning %s row: %w", what, err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows iteration error: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,516 @@
+package archive
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/sunnymotiani/PackTrack/server/models/events"
+	"github.com/sunnymotiani/PackTrack/server/models/items"
+	"github.com/sunnymotiani/PackTrack/server/models/templates"
+	"github.com/sunnymotiani/PackTrack/server/models/users"
+)
+
+var (
+	ErrInvalidBundle      = errors.New("invalid event bundle")
+	ErrUnsupportedVersion = errors.New("unsupported event bundle version")
+	ErrUnknownImporter    = errors.New("importing user does not exist")
+)
+
+// ImportResult describes the event created from a bundle. Skipped lists the
+// emails of members and assignees with no account on this instance, and of
+// assignees who are only viewers of the event; their memberships and
+// assignments are left out.
+type ImportResult struct {
+	EventID    string   `json:"event_id"`
+	Categories int      `json:"categories"`
+	Items      int      `json:"items"`
+	History    int      `json:"history"`
+	Templates  int      `json:"templates"`
+	Skipped    []string `json:"skipped"`
+}
+
+var (
+	validRoles            = map[string]bool{"owner": true, "admin": true, "member": true, "viewer": true}
+	validAssignmentStatus = map[string]bool{items.StatusToPack: true, items.StatusPacked: true, items.StatusDelivered: true}
+	validItemStatus       = map[string]bool{
+		items.StatusToPack: true, items.StatusPacked: true, items.StatusDelivered: true,
+		items.StatusReturned: true, items.StatusMissing: true, items.StatusDamaged: true,
+	}
+)
+
+// Validate checks that a bundle has a known format and version and that its
+// parts are consistent with each other.
+func (b *Bundle) Validate() error {
+	if b.Format != Format {
+		return fmt.Errorf("%w: format must be %q", ErrInvalidBundle, Format)
+	}
+	if b.Version < 1 || b.Version > Version {
+		return fmt.Errorf("%w: got %d, this server reads up to %d", ErrUnsupportedVersion, b.Version, Version)
+	}
+	if strings.TrimSpace(b.Event.Name) == "" {
+		return fmt.Errorf("%w: event name is required", ErrInvalidBundle)
+	}
+	for _, m := range b.Members {
+		if m.Email == "" || !validRoles[m.Role] {
+			return fmt.Errorf("%w: member %q needs an email and a valid role", ErrInvalidBundle, m.Email)
+		}
+	}
+
+	categories := map[string]bool{}
+	names := map[[2]string]bool{}
+	for _, c := range b.Categories {
+		key := [2]string{c.ParentID, c.Name}
+		if c.ID == "" || c.Name == "" || categories[c.ID] || names[key] {
+			return fmt.Errorf("%w: category %q must have a unique id and a name unique among its siblings",
+				ErrInvalidBundle, c.Name)
+		}
+		categories[c.ID], names[key] = true, true
+	}
+	if _, err := categoryOrder(b.Categories); err != nil {
+		return err
+	}
+
+	itemIDs := map[string]bool{}
+	for _, it := range b.Items {
+		if it.ID == "" || itemIDs[it.ID] {
+			return fmt.Errorf("%w: item %q must have a unique id", ErrInvalidBundle, it.Name)
+		}
+		itemIDs[it.ID] = true
+		if err := validateItem(it, categories); err != nil {
+			return fmt.Errorf("%w: item %q: %v", ErrInvalidBundle, it.Name, err)
+		}
+	}
+	for _, h := range b.History {
+		if !itemIDs[h.ItemID] {
+			return fmt.Errorf("%w: history refers to unknown item %q", ErrInvalidBundle, h.ItemID)
+		}
+	}
+	for _, t := range b.Templates {
+		if strings.TrimSpace(t.Name) == "" {
+			return fmt.Errorf("%w: template name is required", ErrInvalidBundle)
+		}
+		for _, ti := range t.Items {
+			if ti.Name == "" || ti.Category == "" || ti.Quantity < 0 {
+				return fmt.Errorf("%w: template %q has an invalid item", ErrInvalidBundle, t.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// categoryOrder returns the indexes of categories with every parent before
+// its subcategories, or an error for unknown parents and cycles.
+func categoryOrder(categories []BundleCategory) ([]int, error) {
+	index := map[string]int{}
+	for i, c := range categories {
+		index[c.ID] = i
+	}
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, len(categories))
+	order := make([]int, 0, len(categories))
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visiting:
+			return fmt.Errorf("%w: categories are nested in a cycle", ErrInvalidBundle)
+		case done:
+			return nil
+		}
+		state[i] = visiting
+		if parent := categories[i].ParentID; parent != "" {
+			p, ok := index[parent]
+			if !ok {
+				return fmt.Errorf("%w: category %q has unknown parent %q", ErrInvalidBundle, categories[i].Name, parent)
+			}
+			if err := visit(p); err != nil {
+				return err
+			}
+		}
+		state[i] = done
+		order = append(order, i)
+		return nil
+	}
+	for i := range categories {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+func validateItem(it BundleItem, categories map[string]bool) error {
+	switch {
+	case !categories[it.CategoryID]:
+		return fmt.Errorf("unknown category %q", it.CategoryID)
+	case it.Name == "":
+		return errors.New("name is required")
+	case !validItemStatus[it.Status]:
+		return fmt.Errorf("invalid status %q", it.Status)
+	case it.Quantity < 0 || it.DeliveredQuantity < 0 || it.DeliveredQuantity > it.PackedQuantity || it.PackedQuantity > it.Quantity:
+		return items.ErrInvalidProgress
+	case it.ReturnedQuantity < 0 || it.MissingQuantity < 0 || it.DamagedQuantity < 0 ||
+		it.ReturnedQuantity+it.MissingQuantity+it.DamagedQuantity > it.DeliveredQuantity:
+		return items.ErrInvalidReturn
+	case it.WeightG != nil && *it.WeightG < 0, it.VolumeML != nil && *it.VolumeML < 0:
+		return errors.New("weight and volume must not be negative")
+	}
+	// The counts decide the status, except that an item of quantity 0 can
+	// be set to any packing status.
+	status := items.ReturnStatus(it.DeliveredQuantity, it.ReturnedQuantity, it.MissingQuantity, it.DamagedQuantity)
+	if status == "" {
+		status = items.DeriveStatus(it.Quantity, it.PackedQuantity, it.DeliveredQuantity)
+	}
+	if it.Status != status && !(it.Quantity == 0 && validAssignmentStatus[it.Status]) {
+		return fmt.Errorf("status %q does not match the counts, expected %q", it.Status, status)
+	}
+
+	total := 0
+	emails := map[string]bool{}
+	for _, a := range it.Assignments {
+		email := strings.ToLower(a.Email)
+		if email == "" || emails[email] || !validAssignmentStatus[a.Status] || a.Quantity <= 0 ||
+			a.DeliveredQuantity < 0 || a.DeliveredQuantity > a.PackedQuantity || a.PackedQuantity > a.Quantity {
+			return items.ErrAssignmentQuantity
+		}
+		emails[email] = true
+		total += a.Quantity
+	}
+	if total > it.Quantity {
+		return items.ErrAssignmentQuantity
+	}
+	return nil
+}
+
+// ImportEvent creates a new event from a bundle for the given user. Every
+// record gets a fresh id and users are matched by email. The importing user
+// owns the event; the bundle's owner only keeps the role its member entry
+// gives them. A template already known by name is reused rather than
+// duplicated.
+func (as *ArchiveService) ImportEvent(ctx context.Context, b *Bundle, importerID string) (*ImportResult, error) {
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+
+	tx, err := as.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	userIDs, err := lookupUsers(ctx, tx, b)
+	if err != nil {
+		return nil, err
+	}
+	if importerID == "" || !userExists(ctx, tx, importerID) {
+		return nil, ErrUnknownImporter
+	}
+
+	result := &ImportResult{EventID: uuid.NewString(), Skipped: []string{}}
+	skipped := map[string]bool{}
+	skip := func(email string) {
+		if !skipped[strings.ToLower(email)] {
+			skipped[strings.ToLower(email)] = true
+			result.Skipped = append(result.Skipped, email)
+		}
+	}
+
+	createdAt := b.Event.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	var description interface{}
+	if b.Event.Description != "" {
+		description = b.Event.Description
+	}
+	err = exec(ctx, tx, "event", sq.Insert(events.TableEvents).
+		Columns("id", "name", "description", "owner_id", "starts_at", "ends_at", "packing_deadline", "created_at").
+		Values(result.EventID, b.Event.Name, description, importerID, b.Event.StartsAt, b.Event.EndsAt,
+			b.Event.PackingDeadline, createdAt))
+	if err != nil {
+		return nil, err
+	}
+
+	// Memberships: the importer always ends up an owner.
+	roles := map[string]string{}
+	order := []string{}
+	addMember := func(userID, role string) {
+		if _, ok := roles[userID]; !ok {
+			order = append(order, userID)
+		}
+		roles[userID] = role
+	}
+	for _, m := range b.Members {
+		id, ok := userIDs[strings.ToLower(m.Email)]
+		if !ok {
+			skip(m.Email)
+			continue
+		}
+		addMember(id, m.Role)
+	}
+	addMember(importerID, "owner")
+	for _, userID := range order {
+		err := exec(ctx, tx, "membership", sq.Insert(events.TableEventMemberships).
+			Columns("id", "user_id", "event_id", "role").
+			Values(uuid.NewString(), userID, result.EventID, roles[userID]))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Categories and items keep the order they are listed in; parents are
+	// inserted before their subcategories.
+	categoryIdx, _ := categoryOrder(b.Categories)
+	categoryIDs := map[string]string{}
+	for _, c := range b.Categories {
+		categoryIDs[c.ID] = uuid.NewString()
+	}
+	for _, i := range categoryIdx {
+		c := b.Categories[i]
+		var parentID interface{}
+		if c.ParentID != "" {
+			parentID = categoryIDs[c.ParentID]
+		}
+		err := exec(ctx, tx, "category", sq.Insert(items.TableCategories).
+			Columns("id", "event_id", "parent_id", "name", "position").
+			Values(categoryIDs[c.ID], result.EventID, parentID, c.Name, float64((i+1)*items.PositionGap)))
+		if err != nil {
+			return nil, err
+		}
+		result.Categories++
+	}
+
+	itemIDs := map[string]string{}
+	counts := map[string]int{}
+	for _, it := range b.Items {
+		itemIDs[it.ID] = uuid.NewString()
+		counts[it.CategoryID]++
+		position := float64(counts[it.CategoryID] * items.PositionGap)
+		if err := importItem(ctx, tx, it, itemIDs[it.ID], categoryIDs[it.CategoryID], position, userIDs, roles, skip); err != nil {
+			return nil, err
+		}
+		result.Items++
+	}
+
+	for _, h := range b.History {
+		var userID interface{}
+		if id, ok := userIDs[strings.ToLower(h.UserEmail)]; ok {
+			userID = id
+		}
+		err := exec(ctx, tx, "history", sq.Insert(items.TableItemStatusHistory).
+			Columns("id", "item_id", "user_id", "old_status", "new_status", "packed_delta", "delivered_delta",
+				"returned_delta", "missing_delta", "damaged_delta", "notes", "changed_at").
+			Values(uuid.NewString(), itemIDs[h.ItemID], userID, h.OldStatus, h.NewStatus, h.PackedDelta,
+				h.DeliveredDelta, h.ReturnedDelta, h.MissingDelta, h.DamagedDelta, h.Notes, h.ChangedAt))
+		if err != nil {
+			return nil, err
+		}
+		result.History++
+	}
+
+	for _, t := range b.Templates {
+		if err := importTemplate(ctx, tx, result.EventID, t); err != nil {
+			return nil, err
+		}
+		result.Templates++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// importItem inserts an item and the assignments of its assignees who are
+// members of the new event; viewers cannot hold items and are skipped too.
+func importItem(ctx context.Context, tx *sql.Tx, it BundleItem, itemID, categoryID string, position float64,
+	userIDs map[string]string, roles map[string]string, skip func(string)) error {
+	type assignment struct {
+		userID string
+		BundleAssignment
+	}
+	var kept []assignment
+	for _, a := range it.Assignments {
+		id, ok := userIDs[strings.ToLower(a.Email)]
+		if role, member := roles[id]; !ok || !member || role == "viewer" {
+			skip(a.Email)
+			continue
+		}
+		kept = append(kept, assignment{id, a})
+	}
+	var assignedTo interface{}
+	if len(kept) == 1 {
+		assignedTo = kept[0].userID
+	}
+	weightUnit, volumeUnit := it.WeightUnit, it.VolumeUnit
+	if weightUnit == "" {
+		weightUnit = items.UnitGram
+	}
+	if volumeUnit == "" {
+		volumeUnit = items.UnitMillilitre
+	}
+	createdAt := it.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+
+	err := exec(ctx, tx, "item", sq.Insert(items.TableItems).
+		Columns("id", "category_id", "name", "quantity", "assigned_to", "status", "notes", "packed_quantity",
+			"delivered_quantity", "returned_quantity", "missing_quantity", "damaged_quantity", "return_notes",
+			"weight_g", "volume_ml", "weight_unit", "volume_unit", "due_at", "position", "created_at").
+		Values(itemID, categoryID, it.Name, it.Quantity, assignedTo, it.Status, it.Notes, it.PackedQuantity,
+			it.DeliveredQuantity, it.ReturnedQuantity, it.MissingQuantity, it.DamagedQuantity, it.ReturnNotes,
+			it.WeightG, it.VolumeML, weightUnit, volumeUnit, it.DueAt, position, createdAt))
+	if err != nil {
+		return err
+	}
+	for _, a := range kept {
+		err := exec(ctx, tx, "assignment", sq.Insert(items.TableItemAssignments).
+			Columns("id", "item_id", "user_id", "quantity", "packed_quantity", "delivered_quantity", "status").
+			Values(uuid.NewString(), itemID, a.userID, a.Quantity, a.PackedQuantity, a.DeliveredQuantity, a.Status))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importTemplate records that an event used a template, creating the template
+// when none has its name yet.
+func importTemplate(ctx context.Context, tx *sql.Tx, eventID string, t BundleTemplate) error {
+	query := sq.Select("id").
+		From(templates.TableTemplates).
+		Where(sq.Eq{"name": t.Name}).
+		OrderBy("created_at ASC").
+		Limit(1).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building select template query: %w", err)
+	}
+	var templateID string
+	err = tx.QueryRowContext(ctx, sqlStr, args...).Scan(&templateID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		templateID = uuid.NewString()
+		err := exec(ctx, tx, "template", sq.Insert(templates.TableTemplates).
+			Columns("id", "name").
+			Values(templateID, t.Name))
+		if err != nil {
+			return err
+		}
+		for _, ti := range t.Items {
+			weightUnit, volumeUnit := ti.WeightUnit, ti.VolumeUnit
+			if weightUnit == "" {
+				weightUnit = items.UnitGram
+			}
+			if volumeUnit == "" {
+				volumeUnit = items.UnitMillilitre
+			}
+			err := exec(ctx, tx, "template item", sq.Insert(templates.TableTemplateItems).
+				Columns("template_id", "category", "name", "quantity", "weight_g", "volume_ml", "weight_unit", "volume_unit").
+				Values(templateID, ti.Category, ti.Name, ti.Quantity, ti.WeightG, ti.VolumeML, weightUnit, volumeUnit))
+			if err != nil {
+				return err
+			}
+		}
+	case err != nil:
+		return fmt.Errorf("error fetching template: %w", err)
+	}
+
+	appliedAt := t.AppliedAt
+	if appliedAt.IsZero() {
+		appliedAt = time.Now()
+	}
+	return exec(ctx, tx, "template use", sq.Insert(templates.TableTemplateApplications).
+		Columns("event_id", "template_id", "applied_at").
+		Values(eventID, templateID, appliedAt).
+		Suffix("ON CONFLICT (event_id, template_id) DO NOTHING"))
+}
+
+// lookupUsers maps the lower-cased emails named in a bundle to the ids of
+// their accounts.
+func lookupUsers(ctx context.Context, tx *sql.Tx, b *Bundle) (map[string]string, error) {
+	emails := map[string]bool{}
+	add := func(email string) {
+		if email != "" {
+			emails[strings.ToLower(email)] = true
+		}
+	}
+	for _, m := range b.Members {
+		add(m.Email)
+	}
+	for _, it := range b.Items {
+		for _, a := range it.Assignments {
+			add(a.Email)
+		}
+	}
+	for _, h := range b.History {
+		add(h.UserEmail)
+	}
+
+	ids := map[string]string{}
+	if len(emails) == 0 {
+		return ids, nil
+	}
+	list := make([]string, 0, len(emails))
+	for email := range emails {
+		list = append(list, email)
+	}
+	query := sq.Select("id", "LOWER(email)").
+		From(users.TableUsers).
+		Where(sq.Eq{"LOWER(email)": list}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building lookup users query: %w", err)
+	}
+	rows, err := tx.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error looking up users: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, email string
+		if err := rows.Scan(&id, &email); err != nil {
+			return nil, fmt.Errorf("error scanning user row: %w", err)
+		}
+		ids[email] = id
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return ids, nil
+}
+
+func userExists(ctx context.Context, tx *sql.Tx, userID string) bool {
+	query := sq.Select("1").From(users.TableUsers).Where(sq.Eq{"id": userID}).PlaceholderFormat(sq.Dollar)
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return false
+	}
+	var one int
+	return tx.QueryRowContext(ctx, sqlStr, args...).Scan(&one) == nil
+}
+
+func exec(ctx context.Context, tx *sql.Tx, what string, query sq.InsertBuilder) error {
+	sqlStr, args, err := query.PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return fmt.Errorf("error building insert %s query: %w", what, err)
+	}
+	if _, err := tx.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("error inserting %s: %w", what, err)
+	}
+	return nil
+}
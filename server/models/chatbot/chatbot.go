@@ -0,0 +1,221 @@
+package chatbot
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/sunnymotiani/PackTrack/server/models/events"
+	"github.com/sunnymotiani/PackTrack/server/models/items"
+)
+
+const (
+	TableChatChannels = "chat_channels"
+	TableChatUsers    = "chat_users"
+)
+
+var (
+	ErrNotEventAdmin  = errors.New("only event owners and admins can link chat channels")
+	ErrMissingChatIDs = errors.New("channel_id and chat_user_id are required")
+	ErrChatUserTaken  = errors.New("chat account is already linked to another user")
+)
+
+// ChatbotService answers slash commands sent from a chat channel linked to
+// an event, acting as the PackTrack user linked to the sender.
+type ChatbotService struct {
+	DB    *sql.DB
+	Items *items.ItemsService
+}
+
+// Command is a slash command as the chat app sends it. Name includes the
+// leading slash; when it is empty the first word of Text is the command.
+type Command struct {
+	ChannelID  string
+	ChatUserID string
+	Name       string
+	Text       string
+}
+
+// ParseCommand splits a command into its lower-cased name, without the
+// slash, and its argument.
+func ParseCommand(c Command) (string, string) {
+	name, text := strings.TrimSpace(c.Name), strings.TrimSpace(c.Text)
+	if name == "" {
+		name, text, _ = strings.Cut(text, " ")
+		text = strings.TrimSpace(text)
+	}
+	return strings.ToLower(strings.TrimPrefix(name, "/")), text
+}
+
+// LinkChannel makes a chat channel answer commands for an event, replacing
+// any event it was linked to before.
+func (cs *ChatbotService) LinkChannel(ctx context.Context, actorID, eventID, channelID string) error {
+	if channelID == "" {
+		return ErrMissingChatIDs
+	}
+	role, err := cs.memberRole(ctx, eventID, actorID)
+	if err != nil && !errors.Is(err, items.ErrNotEventMember) {
+		return err
+	}
+	if role != "owner" && role != "admin" {
+		return ErrNotEventAdmin
+	}
+	query := sq.Insert(TableChatChannels).
+		Columns("channel_id", "event_id", "linked_by").
+		Values(channelID, eventID, actorID).
+		Suffix("ON CONFLICT (channel_id) DO UPDATE SET event_id = EXCLUDED.event_id, linked_by = EXCLUDED.linked_by").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building link channel query: %w", err)
+	}
+	if _, err := cs.DB.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("error linking chat channel: %w", err)
+	}
+	return nil
+}
+
+// LinkUser lets a chat account act as a PackTrack user. A chat account
+// already linked to another user is refused with ErrChatUserTaken.
+func (cs *ChatbotService) LinkUser(ctx context.Context, userID, chatUserID string) error {
+	if chatUserID == "" {
+		return ErrMissingChatIDs
+	}
+	query := sq.Insert(TableChatUsers).
+		Columns("chat_user_id", "user_id").
+		Values(chatUserID, userID).
+		Suffix("ON CONFLICT (chat_user_id) DO UPDATE SET user_id = EXCLUDED.user_id WHERE " +
+			TableChatUsers + ".user_id = EXCLUDED.user_id").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building link chat user query: %w", err)
+	}
+	res, err := cs.DB.ExecContext(ctx, sqlStr, args...)
+	if err != nil {
+		return fmt.Errorf("error linking chat user: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrChatUserTaken
+	}
+	return nil
+}
+
+// Handle runs a command and returns the text to show in the channel.
+// Mistakes of the sender are answered in the reply; the error is only set
+// when the command could not be run.
+func (cs *ChatbotService) Handle(ctx context.Context, c Command) (string, error) {
+	name, arg := ParseCommand(c)
+	if name == "help" || name == "" {
+		return helpText, nil
+	}
+
+	eventID, err := cs.channelEvent(ctx, c.ChannelID)
+	if err != nil {
+		return "", err
+	}
+	if eventID == "" {
+		return "This channel is not linked to a PackTrack event yet.", nil
+	}
+	userID, err := cs.chatUser(ctx, c.ChatUserID)
+	if err != nil {
+		return "", err
+	}
+	if userID == "" {
+		return "Your chat account is not linked to a PackTrack user yet.", nil
+	}
+	role, err := cs.memberRole(ctx, eventID, userID)
+	if errors.Is(err, items.ErrNotEventMember) {
+		return "You are not a member of this channel's event.", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	switch name {
+	case "pack":
+		return cs.setStatus(ctx, eventID, userID, role, arg, items.StatusPacked)
+	case "unpack":
+		return cs.setStatus(ctx, eventID, userID, role, arg, items.StatusToPack)
+	case "deliver":
+		return cs.setStatus(ctx, eventID, userID, role, arg, items.StatusDelivered)
+	case "assign":
+		return cs.assign(ctx, eventID, userID, role, arg)
+	case "mine":
+		return cs.mine(ctx, eventID, userID)
+	case "status":
+		return cs.status(ctx, eventID)
+	default:
+		return fmt.Sprintf("Unknown command /%s.\n\n%s", name, helpText), nil
+	}
+}
+
+const helpText = `Commands:
+/pack <item> - mark an item packed
+/unpack <item> - mark an item to pack again
+/deliver <item> - mark an item delivered
+/assign <item> to <email|me> - give an item to a member
+/mine - list the items assigned to you
+/status - show how packing is going`
+
+// channelEvent returns the event linked to a channel, or "" if none is.
+func (cs *ChatbotService) channelEvent(ctx context.Context, channelID string) (string, error) {
+	query := sq.Select("event_id").From(TableChatChannels).Where(sq.Eq{"channel_id": channelID}).PlaceholderFormat(sq.Dollar)
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return "", fmt.Errorf("error building channel query: %w", err)
+	}
+	var eventID string
+	err = cs.DB.QueryRowContext(ctx, sqlStr, args...).Scan(&eventID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error fetching chat channel: %w", err)
+	}
+	return eventID, nil
+}
+
+// chatUser returns the user linked to a chat account, or "" if none is.
+func (cs *ChatbotService) chatUser(ctx context.Context, chatUserID string) (string, error) {
+	query := sq.Select("user_id").From(TableChatUsers).Where(sq.Eq{"chat_user_id": chatUserID}).PlaceholderFormat(sq.Dollar)
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return "", fmt.Errorf("error building chat user query: %w", err)
+	}
+	var userID string
+	err = cs.DB.QueryRowContext(ctx, sqlStr, args...).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error fetching chat user: %w", err)
+	}
+	return userID, nil
+}
+
+func (cs *ChatbotService) memberRole(ctx context.Context, eventID, userID string) (string, error) {
+	query := sq.Select("role").
+		From(events.TableEventMemberships).
+		Where(sq.Eq{"event_id": eventID, "user_id": userID}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return "", fmt.Errorf("error building member role query: %w", err)
+	}
+	var role string
+	err = cs.DB.QueryRowContext(ctx, sqlStr, args...).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", items.ErrNotEventMember
+	}
+	if err != nil {
+		return "", fmt.Errorf("error fetching member role: %w", err)
+	}
+	return role, nil
+}
@@ -0,0 +1,266 @@
+package chatbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/sunnymotiani/PackTrack/server/models/events"
+	"github.com/sunnymotiani/PackTrack/server/models/items"
+	"github.com/sunnymotiani/PackTrack/server/models/users"
+)
+
+// COMMAND IMPLEMENTATION
+
+type itemMatch struct {
+	ID       string
+	Name     string
+	Category string
+}
+
+func (cs *ChatbotService) setStatus(ctx context.Context, eventID, userID, role, name, status string) (string, error) {
+	if role == "viewer" {
+		return "Viewers cannot change items.", nil
+	}
+	item, reply, err := cs.findItem(ctx, eventID, name)
+	if item == nil {
+		return reply, err
+	}
+	if reply, err := cs.checkCanAct(ctx, item, userID, cs.Items.CanActOnItem); reply != "" || err != nil {
+		return reply, err
+	}
+	if err := cs.Items.UpdateItemStatus(item.ID, status, userID); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s (%s) is now %s.", item.Name, item.Category, strings.ReplaceAll(status, "_", " ")), nil
+}
+
+// assign handles "<item> to <email|me>". Members may claim items nobody
+// holds yet; owners, admins and the item's category leads may also hand
+// items to others.
+func (cs *ChatbotService) assign(ctx context.Context, eventID, userID, role, arg string) (string, error) {
+	i := strings.LastIndex(strings.ToLower(arg), " to ")
+	if i < 0 {
+		return "Usage: /assign <item> to <email|me>", nil
+	}
+	name, target := strings.TrimSpace(arg[:i]), strings.TrimSpace(arg[i+len(" to "):])
+	if role == "viewer" {
+		return "Viewers cannot take items.", nil
+	}
+
+	assigneeID, assigneeName := userID, "you"
+	if !strings.EqualFold(target, "me") {
+		id, targetName, targetRole, err := cs.memberByEmail(ctx, eventID, target)
+		if err != nil {
+			return "", err
+		}
+		if id == "" {
+			return fmt.Sprintf("%s is not a member of this event.", target), nil
+		}
+		if targetRole == "viewer" {
+			return fmt.Sprintf("%s is a viewer and cannot hold items.", targetName), nil
+		}
+		assigneeID, assigneeName = id, targetName
+	}
+
+	item, reply, err := cs.findItem(ctx, eventID, name)
+	if item == nil {
+		return reply, err
+	}
+	check := cs.Items.CanManageItem
+	if assigneeID == userID {
+		check = cs.Items.CanClaimItem
+	}
+	if reply, err := cs.checkCanAct(ctx, item, userID, check); reply != "" || err != nil {
+		return reply, err
+	}
+	if assigneeID == userID {
+		err = cs.Items.ClaimItem(ctx, item.ID, userID)
+	} else {
+		err = cs.Items.AssignItem(item.ID, assigneeID)
+	}
+	switch {
+	case errors.Is(err, items.ErrItemAlreadyClaimed):
+		return fmt.Sprintf("%s (%s) is already assigned to someone else; ask them to swap it with you.", item.Name, item.Category), nil
+	case errors.Is(err, items.ErrNothingToAssign):
+		return fmt.Sprintf("%s (%s) has a quantity of 0, so there is nothing to take.", item.Name, item.Category), nil
+	case err != nil:
+		return "", err
+	}
+	return fmt.Sprintf("%s (%s) is assigned to %s.", item.Name, item.Category, assigneeName), nil
+}
+
+// checkCanAct returns the reply to send when check, one of the items
+// service's permission checks, refuses userID the item, or "" when it
+// passes.
+func (cs *ChatbotService) checkCanAct(ctx context.Context, item *itemMatch, userID string,
+	check func(context.Context, string, string) error) (string, error) {
+	err := check(ctx, item.ID, userID)
+	if errors.Is(err, items.ErrCannotManage) {
+		return fmt.Sprintf("You cannot change %s (%s); ask an event admin or a lead of its category.", item.Name, item.Category), nil
+	}
+	return "", err
+}
+
+func (cs *ChatbotService) mine(ctx context.Context, eventID, userID string) (string, error) {
+	query := sq.Select("i.name", "c.name", "ia.quantity", "ia.packed_quantity", "ia.status").
+		From(items.TableItemAssignments+" ia").
+		Join(items.TableItems+" i ON i.id = ia.item_id").
+		Join(items.TableCategories+" c ON c.id = i.category_id").
+		Where(sq.Eq{"c.event_id": eventID, "ia.user_id": userID}).
+		OrderBy("c.position ASC", "c.id ASC", "i.position ASC", "i.id ASC").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return "", fmt.Errorf("error building my items query: %w", err)
+	}
+	rows, err := cs.DB.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return "", fmt.Errorf("error querying my items: %w", err)
+	}
+	defer rows.Close()
+
+	var lines []string
+	for rows.Next() {
+		var name, category, status string
+		var quantity, packed int
+		if err := rows.Scan(&name, &category, &quantity, &packed, &status); err != nil {
+			return "", fmt.Errorf("error scanning my item row: %w", err)
+		}
+		lines = append(lines, fmt.Sprintf("- %s (%s): %d/%d packed, %s",
+			name, category, packed, quantity, strings.ReplaceAll(status, "_", " ")))
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("rows iteration error: %w", err)
+	}
+	if len(lines) == 0 {
+		return "Nothing is assigned to you.", nil
+	}
+	return "Your items:\n" + strings.Join(lines, "\n"), nil
+}
+
+// status summarises how many items, and how many units, are packed and
+// delivered.
+func (cs *ChatbotService) status(ctx context.Context, eventID string) (string, error) {
+	query := sq.Select("e.name", "COUNT(i.id)",
+		"COUNT(i.id) FILTER (WHERE i.packed_quantity >= i.quantity)",
+		"COUNT(i.id) FILTER (WHERE i.delivered_quantity >= i.quantity)",
+		"COUNT(i.id) FILTER (WHERE NOT EXISTS (SELECT 1 FROM "+items.TableItemAssignments+" ia WHERE ia.item_id = i.id))",
+		"COALESCE(SUM(i.quantity), 0)", "COALESCE(SUM(i.packed_quantity), 0)", "COALESCE(SUM(i.delivered_quantity), 0)").
+		From(events.TableEvents+" e").
+		LeftJoin(items.TableCategories+" c ON c.event_id = e.id").
+		LeftJoin(items.TableItems+" i ON i.category_id = c.id").
+		Where(sq.Eq{"e.id": eventID}).
+		GroupBy("e.id", "e.name").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return "", fmt.Errorf("error building event status query: %w", err)
+	}
+	var name string
+	var total, packed, delivered, unassigned, units, packedUnits, deliveredUnits int
+	err = cs.DB.QueryRowContext(ctx, sqlStr, args...).
+		Scan(&name, &total, &packed, &delivered, &unassigned, &units, &packedUnits, &deliveredUnits)
+	if err != nil {
+		return "", fmt.Errorf("error fetching event status: %w", err)
+	}
+	return fmt.Sprintf("%s: %d items\nPacked: %d of %d items (%d of %d units)\nDelivered: %d of %d items (%d of %d units)\nUnassigned: %d",
+		name, total, packed, total, packedUnits, units, delivered, total, deliveredUnits, units, unassigned), nil
+}
+
+// findItem looks an item of the event up by name, exact matches first, then
+// by substring. When nothing or several items match it returns the reply
+// explaining why instead.
+func (cs *ChatbotService) findItem(ctx context.Context, eventID, name string) (*itemMatch, string, error) {
+	if name == "" {
+		return nil, "Which item? Give its name after the command.", nil
+	}
+	matches, err := cs.queryItems(ctx, eventID, sq.Expr("LOWER(i.name) = LOWER(?)", name))
+	if err == nil && len(matches) == 0 {
+		matches, err = cs.queryItems(ctx, eventID, sq.Expr("STRPOS(LOWER(i.name), LOWER(?)) > 0", name))
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Sprintf("No item matches %q.", name), nil
+	case 1:
+		return &matches[0], "", nil
+	}
+	lines := []string{fmt.Sprintf("%q matches several items:", name)}
+	for i, m := range matches {
+		if i == 5 {
+			lines = append(lines, fmt.Sprintf("...and %d more", len(matches)-5))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("- %s (%s)", m.Name, m.Category))
+	}
+	return nil, strings.Join(lines, "\n"), nil
+}
+
+func (cs *ChatbotService) queryItems(ctx context.Context, eventID string, match sq.Sqlizer) ([]itemMatch, error) {
+	query := sq.Select("i.id", "i.name", "c.name").
+		From(items.TableItems+" i").
+		Join(items.TableCategories+" c ON c.id = i.category_id").
+		Where(sq.Eq{"c.event_id": eventID}).
+		Where(match).
+		OrderBy("c.name ASC", "i.name ASC").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building item lookup query: %w", err)
+	}
+	rows, err := cs.DB.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error looking up items: %w", err)
+	}
+	defer rows.Close()
+
+	var matches []itemMatch
+	for rows.Next() {
+		var m itemMatch
+		if err := rows.Scan(&m.ID, &m.Name, &m.Category); err != nil {
+			return nil, fmt.Errorf("error scanning item row: %w", err)
+		}
+		matches = append(matches, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return matches, nil
+}
+
+// memberByEmail returns the id, name and role of the event member with the
+// given email, or an empty id if there is none.
+func (cs *ChatbotService) memberByEmail(ctx context.Context, eventID, email string) (string, string, string, error) {
+	query := sq.Select("u.id", "u.name", "em.role").
+		From(users.TableUsers + " u").
+		Join(events.TableEventMemberships + " em ON em.user_id = u.id").
+		Where(sq.Eq{"em.event_id": eventID}).
+		Where(sq.Expr("LOWER(u.email) = LOWER(?)", email)).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return "", "", "", fmt.Errorf("error building member lookup query: %w", err)
+	}
+	rows, err := cs.DB.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return "", "", "", fmt.Errorf("error looking up member: %w", err)
+	}
+	defer rows.Close()
+
+	var id, name, role string
+	if rows.Next() {
+		if err := rows.Scan(&id, &name, &role); err != nil {
+			return "", "", "", fmt.Errorf("error scanning member row: %w", err)
+		}
+	}
+	return id, name, role, rows.Err()
+}
@@ -0,0 +1,92 @@
+package chatbot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sunnymotiani/PackTrack/server/models/webhooks"
+)
+
+// Form fields of a command request, as chat apps post them.
+const (
+	FieldChannelID = "channel_id"
+	FieldUserID    = "user_id"
+	FieldCommand   = "command"
+	FieldText      = "text"
+)
+
+// Reply is the JSON body answering a command.
+type Reply struct {
+	ResponseType string `json:"response_type"`
+	Text         string `json:"text"`
+}
+
+// Encode returns the form body a chat app would post for the command.
+func (c Command) Encode() []byte {
+	form := url.Values{}
+	form.Set(FieldChannelID, c.ChannelID)
+	form.Set(FieldUserID, c.ChatUserID)
+	form.Set(FieldCommand, c.Name)
+	form.Set(FieldText, c.Text)
+	return []byte(form.Encode())
+}
+
+// DecodeCommand reads a command from a posted form body.
+func DecodeCommand(body []byte) (Command, error) {
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return Command{}, err
+	}
+	return Command{
+		ChannelID:  form.Get(FieldChannelID),
+		ChatUserID: form.Get(FieldUserID),
+		Name:       form.Get(FieldCommand),
+		Text:       form.Get(FieldText),
+	}, nil
+}
+
+// Sender posts signed commands the way a chat app would, so the command
+// endpoint can be tried locally without one.
+type Sender struct {
+	URL    string
+	Secret string
+	Client *http.Client
+}
+
+// Send posts a command and returns the reply text.
+func (s *Sender) Send(ctx context.Context, c Command) (string, error) {
+	body := c.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, strings.NewReader(string(body)))
+	if err != nil {
+		return "", err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set(webhooks.HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(webhooks.HeaderSignature, webhooks.Sign(s.Secret, timestamp, body))
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	var reply Reply
+	if err := json.NewDecoder(res.Body).Decode(&reply); err != nil {
+		return "", fmt.Errorf("error decoding reply (%s): %w", res.Status, err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return reply.Text, fmt.Errorf("command rejected: %s", res.Status)
+	}
+	return reply.Text, nil
+}
@@ -0,0 +1,318 @@
+package containers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/sunnymotiani/PackTrack/server/models/events"
+	"github.com/sunnymotiani/PackTrack/server/models/items"
+)
+
+const TableContainers = "containers"
+
+var (
+	ErrInvalidType    = errors.New("container type must be bag, box, vehicle or other")
+	ErrEventMismatch  = errors.New("item and container belong to different events")
+	ErrNoSuchResource = errors.New("item or container not found")
+	ErrInvalidOwner   = errors.New("container owner must be a member of its event who is not a viewer")
+)
+
+var validTypes = map[string]bool{
+	"bag":     true,
+	"box":     true,
+	"vehicle": true,
+	"other":   true,
+}
+
+type ContainersService struct {
+	DB *sql.DB
+}
+
+type Container struct {
+	ID          string    `json:"id"`
+	EventID     string    `json:"event_id"`
+	Name        string    `json:"name"`
+	Type        string    `json:"type"`
+	MaxWeightG  *float64  `json:"max_weight_g,omitempty"`
+	MaxVolumeML *float64  `json:"max_volume_ml,omitempty"`
+	OwnerID     *string   `json:"owner_id,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ContainerLoad is a container with the total weight and volume of the items
+// placed in it. Items without a weight or volume count as zero.
+type ContainerLoad struct {
+	Container
+	ItemCount  int     `json:"item_count"`
+	WeightG    float64 `json:"weight_g"`
+	VolumeML   float64 `json:"volume_ml"`
+	OverWeight bool    `json:"over_weight"`
+	OverVolume bool    `json:"over_volume"`
+}
+
+func (l *ContainerLoad) checkCapacity() {
+	l.OverWeight = l.MaxWeightG != nil && l.WeightG > *l.MaxWeightG
+	l.OverVolume = l.MaxVolumeML != nil && l.VolumeML > *l.MaxVolumeML
+}
+
+func (cs *ContainersService) CreateContainer(ctx context.Context, c *Container) error {
+	if c.Type == "" {
+		c.Type = "other"
+	}
+	if !validTypes[c.Type] {
+		return ErrInvalidType
+	}
+	if c.OwnerID != nil {
+		if err := cs.checkOwner(ctx, c.EventID, *c.OwnerID); err != nil {
+			return err
+		}
+	}
+	c.ID = uuid.NewString()
+	c.CreatedAt = time.Now()
+
+	query := sq.Insert(TableContainers).
+		Columns("id", "event_id", "name", "type", "max_weight_g", "max_volume_ml", "owner_id", "created_at").
+		Values(c.ID, c.EventID, c.Name, c.Type, c.MaxWeightG, c.MaxVolumeML, c.OwnerID, c.CreatedAt).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building create container query: %w", err)
+	}
+	if _, err := cs.DB.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("error inserting container: %w", err)
+	}
+	return nil
+}
+
+func (cs *ContainersService) UpdateContainer(ctx context.Context, id string, updates map[string]interface{}) error {
+	if len(updates) == 0 {
+		return fmt.Errorf("no fields to update")
+	}
+	allowed := map[string]bool{"name": true, "type": true, "max_weight_g": true, "max_volume_ml": true, "owner_id": true}
+	for field, value := range updates {
+		if !allowed[field] {
+			return fmt.Errorf("field %s cannot be updated", field)
+		}
+		if t, ok := value.(string); field == "type" && (!ok || !validTypes[t]) {
+			return ErrInvalidType
+		}
+	}
+	if owner, ok := updates["owner_id"]; ok && owner != nil {
+		ownerID, ok := owner.(string)
+		if !ok {
+			return ErrInvalidOwner
+		}
+		eventID, err := cs.containerEvent(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := cs.checkOwner(ctx, eventID, ownerID); err != nil {
+			return err
+		}
+	}
+
+	query := sq.Update(TableContainers).
+		SetMap(updates).
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building update container query: %w", err)
+	}
+	if _, err := cs.DB.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("error updating container: %w", err)
+	}
+	return nil
+}
+
+// checkOwner makes sure a container owner is a non-viewer member of the
+// container's event.
+func (cs *ContainersService) checkOwner(ctx context.Context, eventID, ownerID string) error {
+	query := sq.Select("role").
+		From(events.TableEventMemberships).
+		Where(sq.Eq{"event_id": eventID, "user_id": ownerID}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building owner role query: %w", err)
+	}
+	var role string
+	err = cs.DB.QueryRowContext(ctx, sqlStr, args...).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) || err == nil && role == "viewer" {
+		return ErrInvalidOwner
+	}
+	if err != nil {
+		return fmt.Errorf("error fetching owner role: %w", err)
+	}
+	return nil
+}
+
+func (cs *ContainersService) containerEvent(ctx context.Context, id string) (string, error) {
+	query := sq.Select("event_id").From(TableContainers).Where(sq.Eq{"id": id}).PlaceholderFormat(sq.Dollar)
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return "", fmt.Errorf("error building container event query: %w", err)
+	}
+	var eventID string
+	err = cs.DB.QueryRowContext(ctx, sqlStr, args...).Scan(&eventID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNoSuchResource
+	}
+	if err != nil {
+		return "", fmt.Errorf("error fetching container event: %w", err)
+	}
+	return eventID, nil
+}
+
+// DeleteContainer removes a container; items in it become unplaced.
+func (cs *ContainersService) DeleteContainer(ctx context.Context, id string) error {
+	query := sq.Delete(TableContainers).Where(sq.Eq{"id": id}).PlaceholderFormat(sq.Dollar)
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building delete container query: %w", err)
+	}
+	_, err = cs.DB.ExecContext(ctx, sqlStr, args...)
+	return err
+}
+
+// GetContainerLoads returns every container of an event with its current load.
+func (cs *ContainersService) GetContainerLoads(ctx context.Context, eventID string) ([]ContainerLoad, error) {
+	query := sq.Select("ct.id", "ct.event_id", "ct.name", "ct.type", "ct.max_weight_g", "ct.max_volume_ml",
+		"ct.owner_id", "ct.created_at",
+		"COUNT(i.id)",
+		"COALESCE(SUM(COALESCE(i.weight_g, 0) * COALESCE(i.quantity, 0)), 0)",
+		"COALESCE(SUM(COALESCE(i.volume_ml, 0) * COALESCE(i.quantity, 0)), 0)").
+		From(TableContainers+" ct").
+		LeftJoin(items.TableItems+" i ON i.container_id = ct.id").
+		Where(sq.Eq{"ct.event_id": eventID}).
+		GroupBy("ct.id").
+		OrderBy("ct.created_at ASC", "ct.id ASC").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building container loads query: %w", err)
+	}
+	rows, err := cs.DB.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying container loads: %w", err)
+	}
+	defer rows.Close()
+
+	var loads []ContainerLoad
+	for rows.Next() {
+		var l ContainerLoad
+		if err := rows.Scan(&l.ID, &l.EventID, &l.Name, &l.Type, &l.MaxWeightG, &l.MaxVolumeML,
+			&l.OwnerID, &l.CreatedAt, &l.ItemCount, &l.WeightG, &l.VolumeML); err != nil {
+			return nil, fmt.Errorf("error scanning container load row: %w", err)
+		}
+		l.checkCapacity()
+		loads = append(loads, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return loads, nil
+}
+
+// GetOverCapacity returns the containers of an event loaded beyond their
+// weight or volume capacity.
+func (cs *ContainersService) GetOverCapacity(ctx context.Context, eventID string) ([]ContainerLoad, error) {
+	loads, err := cs.GetContainerLoads(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	over := []ContainerLoad{}
+	for _, l := range loads {
+		if l.OverWeight || l.OverVolume {
+			over = append(over, l)
+		}
+	}
+	return over, nil
+}
+
+// PlaceItem puts an item into a container of the same event, or takes it out
+// of any container when containerID is nil.
+func (cs *ContainersService) PlaceItem(ctx context.Context, itemID string, containerID *string) error {
+	tx, err := cs.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := placeItem(ctx, tx, "", itemID, containerID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ApplyPlacements places several items at once, typically from a packing
+// suggestion. Every item and container must belong to eventID.
+func (cs *ContainersService) ApplyPlacements(ctx context.Context, eventID string, placements []Placement) error {
+	tx, err := cs.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, p := range placements {
+		containerID := p.ContainerID
+		if err := placeItem(ctx, tx, eventID, p.ItemID, &containerID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func placeItem(ctx context.Context, tx *sql.Tx, eventID, itemID string, containerID *string) error {
+	if containerID != nil {
+		check := sq.Select("c.event_id", "ct.event_id").
+			From(items.TableItems+" i").
+			Join(items.TableCategories+" c ON c.id = i.category_id").
+			Join(TableContainers+" ct ON ct.id = ?", *containerID).
+			Where(sq.Eq{"i.id": itemID}).
+			PlaceholderFormat(sq.Dollar)
+
+		checkSQL, checkArgs, err := check.ToSql()
+		if err != nil {
+			return fmt.Errorf("error building placement check query: %w", err)
+		}
+		var itemEventID, containerEventID string
+		err = tx.QueryRowContext(ctx, checkSQL, checkArgs...).Scan(&itemEventID, &containerEventID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoSuchResource
+		}
+		if err != nil {
+			return fmt.Errorf("error checking placement: %w", err)
+		}
+		if itemEventID != containerEventID || (eventID != "" && itemEventID != eventID) {
+			return ErrEventMismatch
+		}
+	}
+
+	query := sq.Update(items.TableItems).
+		Set("container_id", containerID).
+		Where(sq.Eq{"id": itemID}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building place item query: %w", err)
+	}
+	res, err := tx.ExecContext(ctx, sqlStr, args...)
+	if err != nil {
+		return fmt.Errorf("error placing item: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNoSuchResource
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package containers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/sunnymotiani/PackTrack/server/models/items"
+)
+
+// LOAD PLANNING IMPLEMENTATION
+
+type Placement struct {
+	ItemID        string `json:"item_id"`
+	ItemName      string `json:"item_name,omitempty"`
+	ContainerID   string `json:"container_id"`
+	ContainerName string `json:"container_name,omitempty"`
+}
+
+type UnplacedItem struct {
+	ItemID   string  `json:"item_id"`
+	ItemName string  `json:"item_name"`
+	WeightG  float64 `json:"weight_g"`
+	VolumeML float64 `json:"volume_ml"`
+}
+
+// PackingSuggestion is a proposed placement of items into containers with
+// the loads the containers would end up with.
+type PackingSuggestion struct {
+	Placements []Placement     `json:"placements"`
+	Unplaced   []UnplacedItem  `json:"unplaced"`
+	Loads      []ContainerLoad `json:"loads"`
+}
+
+type packingItem struct {
+	ID       string
+	Name     string
+	WeightG  float64
+	VolumeML float64
+}
+
+// SuggestPacking proposes where to put the items of an event using best-fit
+// decreasing: the heaviest and bulkiest items go first, each into the
+// container it fills most tightly without exceeding weight or volume
+// capacity. Unless repack is set, items already placed stay where they are
+// and only unplaced items are suggested. Nothing is saved.
+func (cs *ContainersService) SuggestPacking(ctx context.Context, eventID string, repack bool) (*PackingSuggestion, error) {
+	loads, err := cs.GetContainerLoads(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if repack {
+		for i := range loads {
+			loads[i].ItemCount, loads[i].WeightG, loads[i].VolumeML = 0, 0, 0
+		}
+	}
+
+	pending, err := cs.packingItems(ctx, eventID, !repack)
+	if err != nil {
+		return nil, err
+	}
+	return suggestPacking(pending, loads), nil
+}
+
+func suggestPacking(pending []packingItem, loads []ContainerLoad) *PackingSuggestion {
+	suggestion := &PackingSuggestion{Placements: []Placement{}, Unplaced: []UnplacedItem{}}
+
+	// Size each item relative to the largest capacity so weight and volume
+	// weigh equally when ordering.
+	var maxWeight, maxVolume float64
+	for _, l := range loads {
+		if l.MaxWeightG != nil {
+			maxWeight = math.Max(maxWeight, *l.MaxWeightG)
+		}
+		if l.MaxVolumeML != nil {
+			maxVolume = math.Max(maxVolume, *l.MaxVolumeML)
+		}
+	}
+	size := func(p packingItem) float64 {
+		var s float64
+		if maxWeight > 0 {
+			s = math.Max(s, p.WeightG/maxWeight)
+		}
+		if maxVolume > 0 {
+			s = math.Max(s, p.VolumeML/maxVolume)
+		}
+		return s
+	}
+	sort.SliceStable(pending, func(i, j int) bool { return size(pending[i]) > size(pending[j]) })
+
+	for _, p := range pending {
+		best := -1
+		bestSlack := math.Inf(1)
+		for i, l := range loads {
+			slack, ok := remaining(l, p)
+			if ok && slack < bestSlack {
+				best, bestSlack = i, slack
+			}
+		}
+		if best < 0 {
+			suggestion.Unplaced = append(suggestion.Unplaced, UnplacedItem{
+				ItemID: p.ID, ItemName: p.Name, WeightG: p.WeightG, VolumeML: p.VolumeML,
+			})
+			continue
+		}
+		loads[best].ItemCount++
+		loads[best].WeightG += p.WeightG
+		loads[best].VolumeML += p.VolumeML
+		suggestion.Placements = append(suggestion.Placements, Placement{
+			ItemID:        p.ID,
+			ItemName:      p.Name,
+			ContainerID:   loads[best].ID,
+			ContainerName: loads[best].Name,
+		})
+	}
+
+	for i := range loads {
+		loads[i].checkCapacity()
+	}
+	suggestion.Loads = loads
+	return suggestion
+}
+
+// remaining reports whether the item fits in the container and how much
+// relative capacity would be left; containers without limits never fill up.
+func remaining(l ContainerLoad, p packingItem) (float64, bool) {
+	slack := 0.0
+	capped := false
+	if l.MaxWeightG != nil {
+		left := *l.MaxWeightG - l.WeightG - p.WeightG
+		if left < 0 {
+			return 0, false
+		}
+		if *l.MaxWeightG > 0 {
+			slack += left / *l.MaxWeightG
+		}
+		capped = true
+	}
+	if l.MaxVolumeML != nil {
+		left := *l.MaxVolumeML - l.VolumeML - p.VolumeML
+		if left < 0 {
+			return 0, false
+		}
+		if *l.MaxVolumeML > 0 {
+			slack += left / *l.MaxVolumeML
+		}
+		capped = true
+	}
+	if !capped {
+		return math.MaxFloat64, true
+	}
+	return slack, true
+}
+
+func (cs *ContainersService) packingItems(ctx context.Context, eventID string, onlyUnplaced bool) ([]packingItem, error) {
+	query := sq.Select("i.id", "i.name",
+		"COALESCE(i.weight_g, 0) * COALESCE(i.quantity, 0)",
+		"COALESCE(i.volume_ml, 0) * COALESCE(i.quantity, 0)").
+		From(items.TableItems+" i").
+		Join(items.TableCategories+" c ON c.id = i.category_id").
+		Where(sq.Eq{"c.event_id": eventID}).
+		OrderBy("i.created_at ASC", "i.id ASC").
+		PlaceholderFormat(sq.Dollar)
+	if onlyUnplaced {
+		query = query.Where(sq.Eq{"i.container_id": nil})
+	}
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building packing items query: %w", err)
+	}
+	rows, err := cs.DB.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying packing items: %w", err)
+	}
+	defer rows.Close()
+
+	var pending []packingItem
+	for rows.Next() {
+		var p packingItem
+		if err := rows.Scan(&p.ID, &p.Name, &p.WeightG, &p.VolumeML); err != nil {
+			return nil, fmt.Errorf("error scanning packing item row: %w", err)
+		}
+		pending = append(pending, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return pending, nil
+}
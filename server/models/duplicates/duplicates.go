@@ -0,0 +1,287 @@
+package duplicates
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/sunnymotiani/PackTrack/server/models/items"
+)
+
+// DefaultThreshold is the trigram similarity two item names need to be
+// reported as likely duplicates when no threshold is given.
+const DefaultThreshold = 0.5
+
+// Reasons a pair of items is reported.
+const (
+	ReasonSameName    = "same_name"
+	ReasonSimilarName = "similar_name"
+)
+
+var ErrInvalidThreshold = errors.New("threshold must be greater than 0 and at most 1")
+
+// DuplicatesService finds items of an event that are probably the same thing
+// entered twice and merges them.
+type DuplicatesService struct {
+	DB    *sql.DB
+	Items *items.ItemsService
+}
+
+// DuplicateItem is one side of a likely duplicate.
+type DuplicateItem struct {
+	ID                string    `json:"id"`
+	Name              string    `json:"name"`
+	CategoryID        string    `json:"category_id"`
+	CategoryPath      string    `json:"category_path"`
+	Quantity          int       `json:"quantity"`
+	PackedQuantity    int       `json:"packed_quantity"`
+	DeliveredQuantity int       `json:"delivered_quantity"`
+	Status            string    `json:"status"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// DuplicatePair is two items that are likely the same. Score is 1 when their
+// names normalise to the same text and the trigram similarity of the names
+// otherwise. SuggestedTargetID is the item to keep when merging them.
+type DuplicatePair struct {
+	A                 DuplicateItem `json:"a"`
+	B                 DuplicateItem `json:"b"`
+	Score             float64       `json:"score"`
+	Reason            string        `json:"reason"`
+	SuggestedTargetID string        `json:"suggested_target_id"`
+}
+
+// NormalizeItemName reduces an item name to what matters when comparing it
+// with others: lower case, without notes in parentheses or punctuation, and
+// with plural words made singular, so "Tents (4p)" and "tent" match.
+func NormalizeItemName(name string) string {
+	var b strings.Builder
+	depth := 0
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r == '(' || r == '[':
+			depth++
+		case r == ')' || r == ']':
+			if depth > 0 {
+				depth--
+			}
+		case depth > 0:
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	words := strings.Fields(b.String())
+	for i, w := range words {
+		words[i] = singular(w)
+	}
+	return strings.Join(words, " ")
+}
+
+// singular strips common English plural endings from a word.
+func singular(w string) string {
+	switch {
+	case len(w) > 4 && strings.HasSuffix(w, "ies"):
+		return strings.TrimSuffix(w, "ies") + "y"
+	case len(w) > 4 && (strings.HasSuffix(w, "ches") || strings.HasSuffix(w, "shes") ||
+		strings.HasSuffix(w, "sses") || strings.HasSuffix(w, "xes")):
+		return strings.TrimSuffix(w, "es")
+	case len(w) > 3 && strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "ss") && !strings.HasSuffix(w, "us"):
+		return strings.TrimSuffix(w, "s")
+	default:
+		return w
+	}
+}
+
+// FindDuplicates lists pairs of an event's items that are likely duplicates:
+// items whose names normalise to the same text, and items whose names have a
+// trigram similarity of at least threshold (DefaultThreshold when 0). Pairs
+// come most likely first.
+func (ds *DuplicatesService) FindDuplicates(ctx context.Context, eventID string, threshold float64) ([]DuplicatePair, error) {
+	if threshold == 0 {
+		threshold = DefaultThreshold
+	}
+	if threshold < 0 || threshold > 1 {
+		return nil, ErrInvalidThreshold
+	}
+
+	all, err := ds.eventItems(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	byID := map[string]DuplicateItem{}
+	for _, it := range all {
+		byID[it.ID] = it
+	}
+
+	type key struct{ a, b string }
+	found := map[key]*DuplicatePair{}
+	add := func(a, b string, score float64, reason string) {
+		if a > b {
+			a, b = b, a
+		}
+		k := key{a, b}
+		if p, ok := found[k]; ok && p.Score >= score {
+			return
+		}
+		found[k] = &DuplicatePair{A: byID[a], B: byID[b], Score: score, Reason: reason}
+	}
+
+	groups := map[string][]string{}
+	for _, it := range all {
+		if n := NormalizeItemName(it.Name); n != "" {
+			groups[n] = append(groups[n], it.ID)
+		}
+	}
+	for _, ids := range groups {
+		for i := range ids {
+			for j := i + 1; j < len(ids); j++ {
+				add(ids[i], ids[j], 1, ReasonSameName)
+			}
+		}
+	}
+
+	similar, err := ds.similarPairs(ctx, eventID, threshold)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range similar {
+		if _, ok := byID[s.a]; ok {
+			if _, ok := byID[s.b]; ok {
+				add(s.a, s.b, s.score, ReasonSimilarName)
+			}
+		}
+	}
+
+	pairs := make([]DuplicatePair, 0, len(found))
+	for _, p := range found {
+		p.SuggestedTargetID = suggestTarget(p.A, p.B).ID
+		pairs = append(pairs, *p)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Score != pairs[j].Score {
+			return pairs[i].Score > pairs[j].Score
+		}
+		if pairs[i].A.Name != pairs[j].A.Name {
+			return pairs[i].A.Name < pairs[j].A.Name
+		}
+		return pairs[i].A.ID+pairs[i].B.ID < pairs[j].A.ID+pairs[j].B.ID
+	})
+	return pairs, nil
+}
+
+// suggestTarget picks the item to keep: the one further along in packing,
+// then the one entered first.
+func suggestTarget(a, b DuplicateItem) DuplicateItem {
+	pa, pb := a.PackedQuantity+a.DeliveredQuantity, b.PackedQuantity+b.DeliveredQuantity
+	switch {
+	case pa != pb:
+		if pb > pa {
+			return b
+		}
+		return a
+	case b.CreatedAt.Before(a.CreatedAt):
+		return b
+	default:
+		return a
+	}
+}
+
+func (ds *DuplicatesService) eventItems(ctx context.Context, eventID string) ([]DuplicateItem, error) {
+	tree, err := items.LoadCategoryTree(ctx, ds.DB, eventID)
+	if err != nil {
+		return nil, err
+	}
+	paths := map[string]string{}
+	for _, n := range items.FlattenCategoryTree(tree) {
+		paths[n.ID] = n.Path
+	}
+
+	query := sq.Select("i.id", "i.name", "i.category_id", "i.quantity", "i.packed_quantity",
+		"i.delivered_quantity", "i.status", "i.created_at").
+		From(items.TableItems+" i").
+		Join(items.TableCategories+" c ON c.id = i.category_id").
+		Where(sq.Eq{"c.event_id": eventID}).
+		OrderBy("i.created_at ASC", "i.id ASC").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building event items query: %w", err)
+	}
+	rows, err := ds.DB.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying event items: %w", err)
+	}
+	defer rows.Close()
+
+	var all []DuplicateItem
+	for rows.Next() {
+		var it DuplicateItem
+		if err := rows.Scan(&it.ID, &it.Name, &it.CategoryID, &it.Quantity, &it.PackedQuantity,
+			&it.DeliveredQuantity, &it.Status, &it.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning item row: %w", err)
+		}
+		it.CategoryPath = paths[it.CategoryID]
+		all = append(all, it)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return all, nil
+}
+
+type similarPair struct {
+	a, b  string
+	score float64
+}
+
+// similarPairs asks pg_trgm for the pairs of an event's items whose lower
+// cased names are at least threshold similar. The threshold is set for the
+// transaction only so the % operator can use the trigram index.
+func (ds *DuplicatesService) similarPairs(ctx context.Context, eventID string, threshold float64) ([]similarPair, error) {
+	tx, err := ds.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("error beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SELECT set_config('pg_trgm.similarity_threshold', $1, true)",
+		strconv.FormatFloat(threshold, 'f', -1, 64)); err != nil {
+		return nil, fmt.Errorf("error setting similarity threshold: %w", err)
+	}
+
+	query := fmt.Sprintf(`SELECT a.id, b.id, similarity(LOWER(a.name), LOWER(b.name))
+		FROM %[1]s a
+		JOIN %[2]s ca ON ca.id = a.category_id
+		JOIN %[1]s b ON LOWER(b.name) %% LOWER(a.name) AND b.id > a.id
+		JOIN %[2]s cb ON cb.id = b.category_id AND cb.event_id = ca.event_id
+		WHERE ca.event_id = $1`, items.TableItems, items.TableCategories)
+
+	rows, err := tx.QueryContext(ctx, query, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying similar items: %w", err)
+	}
+	defer rows.Close()
+
+	var pairs []similarPair
+	for rows.Next() {
+		var p similarPair
+		if err := rows.Scan(&p.a, &p.b, &p.score); err != nil {
+			return nil, fmt.Errorf("error scanning similar items row: %w", err)
+		}
+		pairs = append(pairs, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return pairs, nil
+}
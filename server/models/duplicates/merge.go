@@ -0,0 +1,378 @@
+package duplicates
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/sunnymotiani/PackTrack/server/models/activity"
+	"github.com/sunnymotiani/PackTrack/server/models/expenses"
+	"github.com/sunnymotiani/PackTrack/server/models/items"
+	"github.com/sunnymotiani/PackTrack/server/models/loans"
+	"github.com/sunnymotiani/PackTrack/server/models/notifications"
+)
+
+// ITEM MERGE IMPLEMENTATION
+
+var (
+	ErrItemNotFound    = errors.New("item not found")
+	ErrSameItem        = errors.New("an item cannot be merged into itself")
+	ErrDifferentEvents = errors.New("only items of the same event can be merged")
+)
+
+// mergeItem is the part of an item a merge reads and rewrites.
+type mergeItem struct {
+	ID          string
+	EventID     string
+	Name        string
+	Quantity    int
+	Packed      int
+	Delivered   int
+	Returned    int
+	Missing     int
+	Damaged     int
+	Status      string
+	Notes       *string
+	ReturnNotes *string
+	WeightG     *float64
+	VolumeML    *float64
+	ContainerID *string
+	GearID      *string
+	DueAt       *time.Time
+}
+
+// MergeItems folds the source item into the target and deletes the source.
+// Quantities and progress are added up, notes are combined, and the source's
+// assignments, status history, swap requests, expense, loans and
+// notifications move to the target. Pending swap requests involving the
+// source are cancelled, and a source expense is unlinked when the target
+// already has one.
+func (ds *DuplicatesService) MergeItems(ctx context.Context, sourceID, targetID, userID string) (*items.ItemProgress, error) {
+	if sourceID == targetID {
+		return nil, ErrSameItem
+	}
+	for _, id := range []string{sourceID, targetID} {
+		if err := ds.Items.CanManageItem(ctx, id, userID); errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrItemNotFound
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := ds.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	locked, err := lockMergeItems(ctx, tx, sourceID, targetID)
+	if err != nil {
+		return nil, err
+	}
+	source, target := locked[sourceID], locked[targetID]
+	if source == nil || target == nil {
+		return nil, ErrItemNotFound
+	}
+	if source.EventID != target.EventID {
+		return nil, ErrDifferentEvents
+	}
+
+	merged := combine(source, target)
+	if err := mergeAssignments(ctx, tx, sourceID, targetID); err != nil {
+		return nil, err
+	}
+
+	update := sq.Update(items.TableItems).
+		Set("quantity", merged.Quantity).
+		Set("packed_quantity", merged.Packed).
+		Set("delivered_quantity", merged.Delivered).
+		Set("returned_quantity", merged.Returned).
+		Set("missing_quantity", merged.Missing).
+		Set("damaged_quantity", merged.Damaged).
+		Set("status", merged.Status).
+		Set("notes", merged.Notes).
+		Set("return_notes", merged.ReturnNotes).
+		Set("weight_g", merged.WeightG).
+		Set("volume_ml", merged.VolumeML).
+		Set("container_id", merged.ContainerID).
+		Set("gear_id", merged.GearID).
+		Set("due_at", merged.DueAt).
+		Where(sq.Eq{"id": targetID})
+	if err := exec(ctx, tx, update, "updating merged item"); err != nil {
+		return nil, err
+	}
+
+	repoint := []struct {
+		table, column string
+	}{
+		{items.TableItemStatusHistory, "item_id"},
+		{items.TableItemSwapRequests, "item_id"},
+		{items.TableItemSwapRequests, "counter_item_id"},
+		{loans.TableLoans, "item_id"},
+		{notifications.TableNotifications, "item_id"},
+	}
+	cancelSwaps := sq.Update(items.TableItemSwapRequests).
+		Set("status", items.SwapCancelled).
+		Set("resolved_at", sq.Expr("now()")).
+		Where(sq.Eq{"status": items.SwapPending}).
+		Where(sq.Or{sq.Eq{"item_id": sourceID}, sq.Eq{"counter_item_id": sourceID}})
+	if err := exec(ctx, tx, cancelSwaps, "cancelling swap requests"); err != nil {
+		return nil, err
+	}
+	for _, r := range repoint {
+		query := sq.Update(r.table).Set(r.column, targetID).Where(sq.Eq{r.column: sourceID})
+		if err := exec(ctx, tx, query, "moving "+r.table); err != nil {
+			return nil, err
+		}
+	}
+
+	// An item has at most one expense, so the source's is kept but unlinked
+	// when the target already has its own.
+	targetHasExpense := sq.Select("1").From(expenses.TableExpenses).Where(sq.Eq{"item_id": targetID}).Prefix("EXISTS (").Suffix(")")
+	moveExpense := sq.Update(expenses.TableExpenses).
+		Set("item_id", sq.Case().When(targetHasExpense, "NULL").Else(sq.Expr("?::uuid", targetID))).
+		Where(sq.Eq{"item_id": sourceID})
+	if err := exec(ctx, tx, moveExpense, "moving expense"); err != nil {
+		return nil, err
+	}
+
+	// The source's history now belongs to the target, so the merge itself
+	// records no deltas; they would count the source's progress twice.
+	notes := fmt.Sprintf("Merged duplicate %q", source.Name)
+	history := sq.Insert(items.TableItemStatusHistory).
+		Columns("id", "item_id", "user_id", "old_status", "new_status", "packed_delta", "delivered_delta",
+			"returned_delta", "missing_delta", "damaged_delta", "notes").
+		Values(uuid.NewString(), targetID, nullable(userID), target.Status, merged.Status, 0, 0, 0, 0, 0, notes)
+	if err := exec(ctx, tx, history, "recording merge"); err != nil {
+		return nil, err
+	}
+
+	remove := sq.Delete(items.TableItems).Where(sq.Eq{"id": sourceID})
+	if err := exec(ctx, tx, remove, "deleting merged item"); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing merge: %w", err)
+	}
+
+	ds.publishMerge(ctx, source, target, userID)
+	return &items.ItemProgress{
+		ItemID:            targetID,
+		Quantity:          merged.Quantity,
+		PackedQuantity:    merged.Packed,
+		DeliveredQuantity: merged.Delivered,
+		ReturnedQuantity:  merged.Returned,
+		MissingQuantity:   merged.Missing,
+		DamagedQuantity:   merged.Damaged,
+		Status:            merged.Status,
+	}, nil
+}
+
+// combine returns the target as it is after absorbing the source. Counts
+// add up; single-valued details keep the target's value unless it has none,
+// and the earlier due date wins.
+func combine(source, target *mergeItem) *mergeItem {
+	m := *target
+	m.Quantity += source.Quantity
+	m.Packed += source.Packed
+	m.Delivered += source.Delivered
+	m.Returned += source.Returned
+	m.Missing += source.Missing
+	m.Damaged += source.Damaged
+	m.Status = items.ReturnStatus(m.Delivered, m.Returned, m.Missing, m.Damaged)
+	if m.Status == "" {
+		m.Status = items.DeriveStatus(m.Quantity, m.Packed, m.Delivered)
+	}
+	m.Notes = joinNotes(target.Notes, source.Notes)
+	m.ReturnNotes = joinNotes(target.ReturnNotes, source.ReturnNotes)
+	if m.WeightG == nil {
+		m.WeightG = source.WeightG
+	}
+	if m.VolumeML == nil {
+		m.VolumeML = source.VolumeML
+	}
+	if m.ContainerID == nil {
+		m.ContainerID = source.ContainerID
+	}
+	if m.GearID == nil {
+		m.GearID = source.GearID
+	}
+	if m.DueAt == nil || source.DueAt != nil && source.DueAt.Before(*m.DueAt) {
+		m.DueAt = source.DueAt
+	}
+	return &m
+}
+
+// joinNotes puts two notes on separate lines, dropping empty and repeated
+// ones.
+func joinNotes(a, b *string) *string {
+	var parts []string
+	for _, n := range []*string{a, b} {
+		if n == nil {
+			continue
+		}
+		if s := strings.TrimSpace(*n); s != "" && (len(parts) == 0 || parts[0] != s) {
+			parts = append(parts, s)
+		}
+	}
+	if len(parts) == 0 {
+		return nil
+	}
+	joined := strings.Join(parts, "\n")
+	return &joined
+}
+
+// lockMergeItems locks both items in id order, so that concurrent merges of
+// the same pair cannot deadlock.
+func lockMergeItems(ctx context.Context, tx *sql.Tx, ids ...string) (map[string]*mergeItem, error) {
+	query := sq.Select("i.id", "c.event_id", "i.name", "i.quantity", "i.packed_quantity", "i.delivered_quantity",
+		"i.returned_quantity", "i.missing_quantity", "i.damaged_quantity", "i.status", "i.notes", "i.return_notes",
+		"i.weight_g", "i.volume_ml", "i.container_id", "i.gear_id", "i.due_at").
+		From(items.TableItems + " i").
+		Join(items.TableCategories + " c ON c.id = i.category_id").
+		Where(sq.Eq{"i.id": ids}).
+		OrderBy("i.id ASC").
+		Suffix("FOR UPDATE OF i").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building lock items query: %w", err)
+	}
+	rows, err := tx.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error locking items: %w", err)
+	}
+	defer rows.Close()
+
+	locked := map[string]*mergeItem{}
+	for rows.Next() {
+		var m mergeItem
+		if err := rows.Scan(&m.ID, &m.EventID, &m.Name, &m.Quantity, &m.Packed, &m.Delivered, &m.Returned,
+			&m.Missing, &m.Damaged, &m.Status, &m.Notes, &m.ReturnNotes, &m.WeightG, &m.VolumeML,
+			&m.ContainerID, &m.GearID, &m.DueAt); err != nil {
+			return nil, fmt.Errorf("error scanning item row: %w", err)
+		}
+		locked[m.ID] = &m
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return locked, nil
+}
+
+// mergeAssignments moves the source's assignments to the target. A member
+// assigned to both keeps one assignment with the shares added up.
+func mergeAssignments(ctx context.Context, tx *sql.Tx, sourceID, targetID string) error {
+	query := sq.Select("s.id", "t.id", "t.quantity + s.quantity", "t.packed_quantity + s.packed_quantity",
+		"t.delivered_quantity + s.delivered_quantity").
+		From(items.TableItemAssignments+" s").
+		Join(items.TableItemAssignments+" t ON t.user_id = s.user_id AND t.item_id = ?", targetID).
+		Where(sq.Eq{"s.item_id": sourceID}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building shared assignments query: %w", err)
+	}
+	rows, err := tx.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return fmt.Errorf("error querying shared assignments: %w", err)
+	}
+	type shared struct {
+		sourceID, targetID          string
+		quantity, packed, delivered int
+	}
+	var both []shared
+	for rows.Next() {
+		var s shared
+		if err := rows.Scan(&s.sourceID, &s.targetID, &s.quantity, &s.packed, &s.delivered); err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning assignment row: %w", err)
+		}
+		both = append(both, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	for _, s := range both {
+		update := sq.Update(items.TableItemAssignments).
+			Set("quantity", s.quantity).
+			Set("packed_quantity", s.packed).
+			Set("delivered_quantity", s.delivered).
+			Set("status", items.DeriveStatus(s.quantity, s.packed, s.delivered)).
+			Where(sq.Eq{"id": s.targetID})
+		if err := exec(ctx, tx, update, "combining assignments"); err != nil {
+			return err
+		}
+		remove := sq.Delete(items.TableItemAssignments).Where(sq.Eq{"id": s.sourceID})
+		if err := exec(ctx, tx, remove, "removing combined assignment"); err != nil {
+			return err
+		}
+	}
+
+	move := sq.Update(items.TableItemAssignments).Set("item_id", targetID).Where(sq.Eq{"item_id": sourceID})
+	if err := exec(ctx, tx, move, "moving assignments"); err != nil {
+		return err
+	}
+
+	sole := sq.Select("CASE WHEN COUNT(*) = 1 THEN (array_agg(user_id))[1] END").
+		From(items.TableItemAssignments).
+		Where(sq.Eq{"item_id": targetID})
+	assignedTo := sq.Update(items.TableItems).
+		Set("assigned_to", sq.Expr("(?)", sole)).
+		Where(sq.Eq{"id": targetID})
+	return exec(ctx, tx, assignedTo, "updating assigned_to")
+}
+
+// publishMerge tells the merged item's assignees about the merge.
+func (ds *DuplicatesService) publishMerge(ctx context.Context, source, target *mergeItem, userID string) {
+	if ds.Items.Activity == nil {
+		return
+	}
+	assignments, err := ds.Items.GetItemAssignments(ctx, target.ID)
+	if err != nil {
+		return
+	}
+	recipients := make([]string, 0, len(assignments))
+	for _, a := range assignments {
+		recipients = append(recipients, a.UserID)
+	}
+	activity.Publish(ctx, ds.Items.Activity, activity.Activity{
+		Type:       activity.TypeStatusChange,
+		EventID:    target.EventID,
+		ItemID:     target.ID,
+		ActorID:    userID,
+		Recipients: recipients,
+		Message:    fmt.Sprintf("%s was merged into %s", source.Name, target.Name),
+	})
+}
+
+// exec runs a statement built with ? placeholders inside the merge.
+func exec(ctx context.Context, tx *sql.Tx, query sq.Sqlizer, what string) error {
+	sqlStr, args, err := query.ToSql()
+	if err == nil {
+		sqlStr, err = sq.Dollar.ReplacePlaceholders(sqlStr)
+	}
+	if err != nil {
+		return fmt.Errorf("error building query for %s: %w", what, err)
+	}
+	if _, err := tx.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("error %s: %w", what, err)
+	}
+	return nil
+}
+
+func nullable(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
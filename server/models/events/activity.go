@@ -0,0 +1,41 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/sunnymotiani/PackTrack/server/models/activity"
+)
+
+// publish reports a membership change to the member it concerns; format
+// receives the event name.
+func (es *EventService) publish(ctx context.Context, kind, eventID, userID, format string) {
+	if es.Activity == nil {
+		return
+	}
+	query := sq.Select("name").
+		From(TableEvents).
+		Where(sq.Eq{"id": eventID}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		log.Printf("activity: building event query: %v", err)
+		return
+	}
+	var name string
+	if err := es.DB.QueryRowContext(ctx, sqlStr, args...).Scan(&name); err != nil {
+		log.Printf("activity: fetching event %s: %v", eventID, err)
+		return
+	}
+
+	activity.Publish(ctx, es.Activity, activity.Activity{
+		Type:       kind,
+		EventID:    eventID,
+		Recipients: []string{userID},
+		Message:    fmt.Sprintf(format, name),
+		Data:       map[string]interface{}{"user_id": userID},
+	})
+}
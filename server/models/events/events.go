@@ -3,11 +3,13 @@ package events
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/google/uuid"
+	"github.com/sunnymotiani/PackTrack/server/models/activity"
 	"github.com/sunnymotiani/PackTrack/server/models/users"
 )
 
@@ -17,11 +19,14 @@ const (
 )
 
 type Event struct {
-	ID          string    `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description,omitempty"`
-	OwnerID     *string   `json:"owner_id,omitempty"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID              string     `json:"id"`
+	Name            string     `json:"name"`
+	Description     string     `json:"description,omitempty"`
+	OwnerID         *string    `json:"owner_id,omitempty"`
+	StartsAt        *time.Time `json:"starts_at,omitempty"`
+	EndsAt          *time.Time `json:"ends_at,omitempty"`
+	PackingDeadline *time.Time `json:"packing_deadline,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
 }
 
 type EventMembership struct {
@@ -35,6 +40,8 @@ type EventMembership struct {
 
 type EventService struct {
 	DB *sql.DB
+	// Activity, when set, is told about invitations and membership changes.
+	Activity activity.Publisher
 }
 
 func (es *EventService) CreateEvent(ctx context.Context, name, description, ownerID string) (*Event, error) {
@@ -116,6 +123,7 @@ func (es *EventService) AddMember(ctx context.Context, eventID, userID, role str
 		return fmt.Errorf("error executing AddMember query: %w", err)
 	}
 
+	es.publish(ctx, activity.TypeInvitation, eventID, userID, "You were added to %s as "+role)
 	return nil
 }
 func (es *EventService) GetEventMembers(ctx context.Context, eventID string) ([]EventMembership, error) {
@@ -164,6 +172,7 @@ func (es *EventService) UpdateMemberRole(ctx context.Context, eventID, userID, n
 		return fmt.Errorf("error executing UpdateMemberRole query: %w", err)
 	}
 
+	es.publish(ctx, activity.TypeMembership, eventID, userID, "Your role in %s is now "+newRole)
 	return nil
 }
 func (es *EventService) RemoveMember(ctx context.Context, eventID, userID string) error {
@@ -182,11 +191,105 @@ func (es *EventService) RemoveMember(ctx context.Context, eventID, userID string
 		return fmt.Errorf("error executing RemoveMember query: %w", err)
 	}
 
+	es.publish(ctx, activity.TypeMemberRemoved, eventID, userID, "You were removed from %s")
+	return nil
+}
+
+// ErrGearConflict is returned when gear would end up committed to two events
+// that overlap. The gear package links items to gear and shares it.
+var ErrGearConflict = errors.New("gear is already committed to an item in an overlapping event")
+
+// SetEventDates sets when an event takes place; nil clears a date. The new
+// dates are refused with ErrGearConflict when gear linked to the event's
+// items is committed to another event in that time.
+func (es *EventService) SetEventDates(ctx context.Context, eventID string, startsAt, endsAt *time.Time) error {
+	if startsAt != nil && endsAt != nil && endsAt.Before(*startsAt) {
+		return fmt.Errorf("event cannot end before it starts")
+	}
+
+	tx, err := es.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Linking gear locks the gear row, so locking the event's gear keeps new
+	// links from slipping in while the dates are checked.
+	lockGear := `SELECT id FROM gear WHERE id IN (
+			SELECT i.gear_id FROM items i JOIN categories c ON c.id = i.category_id WHERE c.event_id = $1
+		) ORDER BY id FOR UPDATE`
+	rows, err := tx.QueryContext(ctx, lockGear, eventID)
+	if err != nil {
+		return fmt.Errorf("error locking event gear: %w", err)
+	}
+	rows.Close()
+
+	query := sq.
+		Update(TableEvents).
+		Set("starts_at", startsAt).
+		Set("ends_at", endsAt).
+		Where(sq.Eq{"id": eventID}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building SetEventDates query: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, sqlStr, args...)
+	if err != nil {
+		return fmt.Errorf("error executing SetEventDates query: %w", err)
+	}
+
+	if startsAt != nil {
+		end := startsAt
+		if endsAt != nil {
+			end = endsAt
+		}
+		conflicts := `SELECT EXISTS (
+			SELECT 1 FROM items i
+			JOIN categories c ON c.id = i.category_id
+			JOIN items o ON o.gear_id = i.gear_id AND o.id <> i.id
+			JOIN categories oc ON oc.id = o.category_id
+			JOIN events oe ON oe.id = oc.event_id
+			WHERE c.event_id = $1 AND oc.event_id <> $1
+				AND oe.starts_at <= $3 AND COALESCE(oe.ends_at, oe.starts_at) >= $2)`
+		var conflict bool
+		if err := tx.QueryRowContext(ctx, conflicts, eventID, *startsAt, *end).Scan(&conflict); err != nil {
+			return fmt.Errorf("error checking gear conflicts: %w", err)
+		}
+		if conflict {
+			return ErrGearConflict
+		}
+	}
+
+	return tx.Commit()
+}
+
+// SetPackingDeadline sets when the items of an event should be packed; nil
+// clears the deadline.
+func (es *EventService) SetPackingDeadline(ctx context.Context, eventID string, deadline *time.Time) error {
+	query := sq.
+		Update(TableEvents).
+		Set("packing_deadline", deadline).
+		Where(sq.Eq{"id": eventID}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building SetPackingDeadline query: %w", err)
+	}
+
+	_, err = es.DB.ExecContext(ctx, sqlStr, args...)
+	if err != nil {
+		return fmt.Errorf("error executing SetPackingDeadline query: %w", err)
+	}
+
 	return nil
 }
 func (es *EventService) GetEventsForUser(ctx context.Context, userID string) ([]*Event, error) {
 	query := sq.
-		Select("e.id", "e.name", "e.description", "e.owner_id", "e.created_at").
+		Select("e.id", "e.name", "e.description", "e.owner_id", "e.starts_at", "e.ends_at", "e.packing_deadline", "e.created_at").
 		From(fmt.Sprintf("%s AS e", TableEvents)).
 		Join(fmt.Sprintf("%s AS em ON em.event_id = e.id", TableEventMemberships)).
 		Where(sq.Eq{"em.user_id": userID}).
@@ -206,7 +309,7 @@ func (es *EventService) GetEventsForUser(ctx context.Context, userID string) ([]
 	var events []*Event
 	for rows.Next() {
 		var e Event
-		if err := rows.Scan(&e.ID, &e.Name, &e.Description, &e.OwnerID, &e.CreatedAt); err != nil {
+		if err := rows.Scan(&e.ID, &e.Name, &e.Description, &e.OwnerID, &e.StartsAt, &e.EndsAt, &e.PackingDeadline, &e.CreatedAt); err != nil {
 			return nil, fmt.Errorf("error scanning event row: %w", err)
 		}
 		events = append(events, &e)
@@ -0,0 +1,370 @@
+package expenses
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/sunnymotiani/PackTrack/server/models/events"
+	"github.com/sunnymotiani/PackTrack/server/models/items"
+)
+
+const (
+	TableExpenses      = "expenses"
+	TableExpenseSplits = "expense_splits"
+)
+
+const (
+	SplitEqual  = "equal"
+	SplitShares = "shares"
+)
+
+var (
+	ErrInvalidSplit    = errors.New("split_type must be equal or shares, and a shares split needs at least one member with shares")
+	ErrInvalidAmount   = errors.New("amount_cents must not be negative")
+	ErrDuplicateShare  = errors.New("each member can appear only once in the shares")
+	ErrNotEventMember  = errors.New("payer and everyone in the split must be members of the event")
+	ErrExpenseNotFound = errors.New("expense not found")
+	ErrItemNotFound    = errors.New("item not found")
+)
+
+type ExpensesService struct {
+	DB *sql.DB
+}
+
+// Expense is money one member spent for an event. ItemID is set when the
+// expense is the cost of an item.
+//
+// With an equal split every event member pays the same except those listed
+// in Exclude; with a shares split only the members in Shares pay, in
+// proportion to their shares.
+type Expense struct {
+	ID          string    `json:"id"`
+	EventID     string    `json:"event_id"`
+	ItemID      *string   `json:"item_id,omitempty"`
+	Description string    `json:"description"`
+	AmountCents int64     `json:"amount_cents"`
+	PaidBy      *string   `json:"paid_by,omitempty"`
+	SplitType   string    `json:"split_type"`
+	Shares      []Share   `json:"shares,omitempty"`
+	Exclude     []string  `json:"exclude,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type Share struct {
+	UserID string `json:"user_id"`
+	Shares int    `json:"shares"`
+}
+
+func (e *Expense) validate() error {
+	if e.SplitType == "" {
+		e.SplitType = SplitEqual
+	}
+	if e.AmountCents < 0 {
+		return ErrInvalidAmount
+	}
+	switch e.SplitType {
+	case SplitEqual:
+		e.Shares = nil
+	case SplitShares:
+		e.Exclude = nil
+		total := 0
+		seen := map[string]bool{}
+		for _, s := range e.Shares {
+			if s.Shares < 0 {
+				return ErrInvalidSplit
+			}
+			if seen[s.UserID] {
+				return ErrDuplicateShare
+			}
+			seen[s.UserID] = true
+			total += s.Shares
+		}
+		if total == 0 {
+			return ErrInvalidSplit
+		}
+	default:
+		return ErrInvalidSplit
+	}
+	return nil
+}
+
+// splitRows returns the expense_splits rows for the expense; excluded members
+// of an equal split are stored with 0 shares, once each.
+func (e *Expense) splitRows() []Share {
+	if e.SplitType == SplitShares {
+		return e.Shares
+	}
+	rows := make([]Share, 0, len(e.Exclude))
+	seen := map[string]bool{}
+	for _, userID := range e.Exclude {
+		if !seen[userID] {
+			seen[userID] = true
+			rows = append(rows, Share{UserID: userID})
+		}
+	}
+	return rows
+}
+
+func (e *Expense) memberIDs() []string {
+	seen := map[string]bool{}
+	var ids []string
+	add := func(id string) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if e.PaidBy != nil {
+		add(*e.PaidBy)
+	}
+	for _, s := range e.splitRows() {
+		add(s.UserID)
+	}
+	return ids
+}
+
+// CreateExpense records an event-level expense not tied to an item.
+func (es *ExpensesService) CreateExpense(ctx context.Context, e *Expense) error {
+	if err := e.validate(); err != nil {
+		return err
+	}
+	e.ItemID = nil
+	e.ID = uuid.NewString()
+	e.CreatedAt = time.Now()
+
+	tx, err := es.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := checkEventMembers(ctx, tx, e.EventID, e.memberIDs()); err != nil {
+		return err
+	}
+
+	query := sq.Insert(TableExpenses).
+		Columns("id", "event_id", "description", "amount_cents", "paid_by", "split_type", "created_at").
+		Values(e.ID, e.EventID, e.Description, e.AmountCents, e.PaidBy, e.SplitType, e.CreatedAt).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building create expense query: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("error inserting expense: %w", err)
+	}
+	if err := replaceSplits(ctx, tx, e); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetItemCost records what an item cost and who bought it, replacing any cost
+// recorded before. The description defaults to the item's name.
+func (es *ExpensesService) SetItemCost(ctx context.Context, itemID string, e *Expense) error {
+	if err := e.validate(); err != nil {
+		return err
+	}
+
+	tx, err := es.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	itemQuery := sq.Select("c.event_id", "i.name").
+		From(items.TableItems + " i").
+		Join(items.TableCategories + " c ON c.id = i.category_id").
+		Where(sq.Eq{"i.id": itemID}).
+		PlaceholderFormat(sq.Dollar)
+
+	itemSQL, itemArgs, err := itemQuery.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building item lookup query: %w", err)
+	}
+	var itemName string
+	err = tx.QueryRowContext(ctx, itemSQL, itemArgs...).Scan(&e.EventID, &itemName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrItemNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("error looking up item: %w", err)
+	}
+	if e.Description == "" {
+		e.Description = itemName
+	}
+	if err := checkEventMembers(ctx, tx, e.EventID, e.memberIDs()); err != nil {
+		return err
+	}
+
+	e.ItemID = &itemID
+	e.CreatedAt = time.Now()
+	query := sq.Insert(TableExpenses).
+		Columns("id", "event_id", "item_id", "description", "amount_cents", "paid_by", "split_type", "created_at").
+		Values(uuid.NewString(), e.EventID, itemID, e.Description, e.AmountCents, e.PaidBy, e.SplitType, e.CreatedAt).
+		Suffix(`ON CONFLICT (item_id) DO UPDATE SET
+			description = EXCLUDED.description,
+			amount_cents = EXCLUDED.amount_cents,
+			paid_by = EXCLUDED.paid_by,
+			split_type = EXCLUDED.split_type
+			RETURNING id, created_at`).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building item cost query: %w", err)
+	}
+	if err := tx.QueryRowContext(ctx, sqlStr, args...).Scan(&e.ID, &e.CreatedAt); err != nil {
+		return fmt.Errorf("error saving item cost: %w", err)
+	}
+	if err := replaceSplits(ctx, tx, e); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (es *ExpensesService) DeleteExpense(ctx context.Context, id string) error {
+	query := sq.Delete(TableExpenses).Where(sq.Eq{"id": id}).PlaceholderFormat(sq.Dollar)
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building delete expense query: %w", err)
+	}
+	res, err := es.DB.ExecContext(ctx, sqlStr, args...)
+	if err != nil {
+		return fmt.Errorf("error deleting expense: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrExpenseNotFound
+	}
+	return nil
+}
+
+// GetEventExpenses returns every expense of an event, item costs included,
+// oldest first.
+func (es *ExpensesService) GetEventExpenses(ctx context.Context, eventID string) ([]Expense, error) {
+	query := sq.Select("id", "event_id", "item_id", "description", "amount_cents", "paid_by", "split_type", "created_at").
+		From(TableExpenses).
+		Where(sq.Eq{"event_id": eventID}).
+		OrderBy("created_at ASC", "id ASC").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building event expenses query: %w", err)
+	}
+	rows, err := es.DB.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying event expenses: %w", err)
+	}
+	defer rows.Close()
+
+	expenses := []Expense{}
+	index := map[string]int{}
+	for rows.Next() {
+		var e Expense
+		if err := rows.Scan(&e.ID, &e.EventID, &e.ItemID, &e.Description, &e.AmountCents,
+			&e.PaidBy, &e.SplitType, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning expense row: %w", err)
+		}
+		index[e.ID] = len(expenses)
+		expenses = append(expenses, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	splitsQuery := sq.Select("s.expense_id", "s.user_id", "s.shares").
+		From(TableExpenseSplits + " s").
+		Join(TableExpenses + " e ON e.id = s.expense_id").
+		Where(sq.Eq{"e.event_id": eventID}).
+		OrderBy("s.user_id ASC").
+		PlaceholderFormat(sq.Dollar)
+
+	splitsSQL, splitsArgs, err := splitsQuery.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building expense splits query: %w", err)
+	}
+	splitRows, err := es.DB.QueryContext(ctx, splitsSQL, splitsArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying expense splits: %w", err)
+	}
+	defer splitRows.Close()
+
+	for splitRows.Next() {
+		var expenseID string
+		var s Share
+		if err := splitRows.Scan(&expenseID, &s.UserID, &s.Shares); err != nil {
+			return nil, fmt.Errorf("error scanning expense split row: %w", err)
+		}
+		e := &expenses[index[expenseID]]
+		if e.SplitType == SplitShares {
+			e.Shares = append(e.Shares, s)
+		} else if s.Shares == 0 {
+			e.Exclude = append(e.Exclude, s.UserID)
+		}
+	}
+	if err := splitRows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return expenses, nil
+}
+
+func replaceSplits(ctx context.Context, tx *sql.Tx, e *Expense) error {
+	del := sq.Delete(TableExpenseSplits).Where(sq.Eq{"expense_id": e.ID}).PlaceholderFormat(sq.Dollar)
+	delSQL, delArgs, err := del.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building delete splits query: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, delSQL, delArgs...); err != nil {
+		return fmt.Errorf("error deleting expense splits: %w", err)
+	}
+
+	rows := e.splitRows()
+	if len(rows) == 0 {
+		return nil
+	}
+	insert := sq.Insert(TableExpenseSplits).
+		Columns("expense_id", "user_id", "shares").
+		Suffix("ON CONFLICT (expense_id, user_id) DO UPDATE SET shares = EXCLUDED.shares").
+		PlaceholderFormat(sq.Dollar)
+	for _, s := range rows {
+		insert = insert.Values(e.ID, s.UserID, s.Shares)
+	}
+	insertSQL, insertArgs, err := insert.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building insert splits query: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, insertSQL, insertArgs...); err != nil {
+		return fmt.Errorf("error inserting expense splits: %w", err)
+	}
+	return nil
+}
+
+func checkEventMembers(ctx context.Context, tx *sql.Tx, eventID string, userIDs []string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	query := sq.Select("COUNT(DISTINCT user_id)").
+		From(events.TableEventMemberships).
+		Where(sq.Eq{"event_id": eventID, "user_id": userIDs}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building membership check query: %w", err)
+	}
+	var count int
+	if err := tx.QueryRowContext(ctx, sqlStr, args...).Scan(&count); err != nil {
+		return fmt.Errorf("error checking event membership: %w", err)
+	}
+	if count != len(userIDs) {
+		return ErrNotEventMember
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package expenses
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/sunnymotiani/PackTrack/server/models/events"
+	"github.com/sunnymotiani/PackTrack/server/models/users"
+)
+
+// SETTLE UP IMPLEMENTATION
+
+// Balance is what a member paid and owes across all expenses of an event.
+// A positive NetCents means the member is owed money.
+type Balance struct {
+	UserID    string `json:"user_id"`
+	Name      string `json:"name"`
+	PaidCents int64  `json:"paid_cents"`
+	OwedCents int64  `json:"owed_cents"`
+	NetCents  int64  `json:"net_cents"`
+}
+
+type Transfer struct {
+	FromUserID  string `json:"from_user_id"`
+	ToUserID    string `json:"to_user_id"`
+	AmountCents int64  `json:"amount_cents"`
+}
+
+type Settlement struct {
+	Balances  []Balance  `json:"balances"`
+	Transfers []Transfer `json:"transfers"`
+}
+
+// SettleUp works out every member's balance and the transfers that even them
+// out. Equal splits are shared by the event's current members, so someone
+// who joins later takes part in earlier equal expenses too.
+func (es *ExpensesService) SettleUp(ctx context.Context, eventID string) (*Settlement, error) {
+	expenses, err := es.GetEventExpenses(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	members, err := es.eventMembers(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	balances := map[string]*Balance{}
+	order := []string{}
+	balanceOf := func(userID string) *Balance {
+		b, ok := balances[userID]
+		if !ok {
+			b = &Balance{UserID: userID}
+			balances[userID] = b
+			order = append(order, userID)
+		}
+		return b
+	}
+	memberIDs := make([]string, 0, len(members))
+	for _, m := range members {
+		balanceOf(m.UserID).Name = m.Name
+		memberIDs = append(memberIDs, m.UserID)
+	}
+
+	for _, e := range expenses {
+		if e.PaidBy == nil {
+			continue
+		}
+		owed := splitExpense(e, memberIDs)
+		if len(owed) == 0 {
+			continue
+		}
+		balanceOf(*e.PaidBy).PaidCents += e.AmountCents
+		for userID, cents := range owed {
+			balanceOf(userID).OwedCents += cents
+		}
+	}
+
+	settlement := &Settlement{Balances: make([]Balance, 0, len(order))}
+	for _, userID := range order {
+		b := balances[userID]
+		b.NetCents = b.PaidCents - b.OwedCents
+		settlement.Balances = append(settlement.Balances, *b)
+	}
+	settlement.Transfers = settleBalances(settlement.Balances)
+	return settlement, nil
+}
+
+// splitExpense returns how many cents each member owes for an expense. Cents
+// that do not divide evenly go to the members with the largest remainders,
+// ties broken by user id, so the parts always add up to the amount.
+func splitExpense(e Expense, memberIDs []string) map[string]int64 {
+	weights := map[string]int64{}
+	if e.SplitType == SplitShares {
+		for _, s := range e.Shares {
+			if s.Shares > 0 {
+				weights[s.UserID] = int64(s.Shares)
+			}
+		}
+	} else {
+		excluded := map[string]bool{}
+		for _, userID := range e.Exclude {
+			excluded[userID] = true
+		}
+		for _, userID := range memberIDs {
+			if !excluded[userID] {
+				weights[userID] = 1
+			}
+		}
+	}
+
+	var total int64
+	ids := make([]string, 0, len(weights))
+	for userID, w := range weights {
+		total += w
+		ids = append(ids, userID)
+	}
+	if total == 0 {
+		return nil
+	}
+	sort.Strings(ids)
+
+	owed := make(map[string]int64, len(ids))
+	remainders := make(map[string]int64, len(ids))
+	var assigned int64
+	for _, userID := range ids {
+		part := e.AmountCents * weights[userID]
+		owed[userID] = part / total
+		remainders[userID] = part % total
+		assigned += owed[userID]
+	}
+	sort.SliceStable(ids, func(i, j int) bool { return remainders[ids[i]] > remainders[ids[j]] })
+	for i := int64(0); i < e.AmountCents-assigned; i++ {
+		owed[ids[i]]++
+	}
+	return owed
+}
+
+// settleBalances pairs debtors with creditors. Members whose debt exactly
+// matches someone's credit settle with each other first; the rest are
+// matched largest debt to largest credit, which needs at most one transfer
+// fewer than the number of members with a non-zero balance.
+func settleBalances(balances []Balance) []Transfer {
+	type party struct {
+		userID string
+		cents  int64
+	}
+	var debtors, creditors []party
+	for _, b := range balances {
+		switch {
+		case b.NetCents < 0:
+			debtors = append(debtors, party{b.UserID, -b.NetCents})
+		case b.NetCents > 0:
+			creditors = append(creditors, party{b.UserID, b.NetCents})
+		}
+	}
+
+	transfers := []Transfer{}
+	for i := range debtors {
+		for j := range creditors {
+			if creditors[j].cents != 0 && debtors[i].cents == creditors[j].cents {
+				transfers = append(transfers, Transfer{debtors[i].userID, creditors[j].userID, debtors[i].cents})
+				debtors[i].cents, creditors[j].cents = 0, 0
+				break
+			}
+		}
+	}
+
+	for {
+		sort.SliceStable(debtors, func(i, j int) bool { return debtors[i].cents > debtors[j].cents })
+		sort.SliceStable(creditors, func(i, j int) bool { return creditors[i].cents > creditors[j].cents })
+		if len(debtors) == 0 || len(creditors) == 0 || debtors[0].cents == 0 || creditors[0].cents == 0 {
+			return transfers
+		}
+		amount := debtors[0].cents
+		if creditors[0].cents < amount {
+			amount = creditors[0].cents
+		}
+		transfers = append(transfers, Transfer{debtors[0].userID, creditors[0].userID, amount})
+		debtors[0].cents -= amount
+		creditors[0].cents -= amount
+	}
+}
+
+func (es *ExpensesService) eventMembers(ctx context.Context, eventID string) ([]events.EventMembership, error) {
+	query := sq.Select("em.user_id", "u.name").
+		From(events.TableEventMemberships+" em").
+		Join(users.TableUsers+" u ON u.id = em.user_id").
+		Where(sq.Eq{"em.event_id": eventID}).
+		OrderBy("u.name ASC", "em.user_id ASC").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building event members query: %w", err)
+	}
+	rows, err := es.DB.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying event members: %w", err)
+	}
+	defer rows.Close()
+
+	var members []events.EventMembership
+	for rows.Next() {
+		var m events.EventMembership
+		if err := rows.Scan(&m.UserID, &m.Name); err != nil {
+			return nil, fmt.Errorf("error scanning member row: %w", err)
+		}
+		members = append(members, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return members, nil
+}
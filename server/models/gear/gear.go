@@ -0,0 +1,258 @@
+package gear
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/sunnymotiani/PackTrack/server/models/events"
+	"github.com/sunnymotiani/PackTrack/server/models/items"
+)
+
+const (
+	TableGear       = "gear"
+	TableGearPhotos = "gear_photos"
+)
+
+const (
+	ConditionNew         = "new"
+	ConditionGood        = "good"
+	ConditionWorn        = "worn"
+	ConditionNeedsRepair = "needs_repair"
+	ConditionRetired     = "retired"
+)
+
+var (
+	ErrInvalidCondition = errors.New("condition must be new, good, worn, needs_repair or retired")
+	ErrGearNotFound     = errors.New("gear not found")
+	ErrGearRetired      = errors.New("retired gear cannot be linked to items")
+	ErrGearConflict     = events.ErrGearConflict
+	ErrNotEventMember   = errors.New("gear owner is not a member of the item's event")
+	ErrItemNotFound     = errors.New("item not found")
+)
+
+var validConditions = map[string]bool{
+	ConditionNew:         true,
+	ConditionGood:        true,
+	ConditionWorn:        true,
+	ConditionNeedsRepair: true,
+	ConditionRetired:     true,
+}
+
+type GearService struct {
+	DB *sql.DB
+}
+
+// Gear is a physical piece of equipment a member owns. WeightG is stored in
+// grams; Weight is the same value in WeightUnit.
+type Gear struct {
+	ID          string         `json:"id"`
+	OwnerID     string         `json:"owner_id"`
+	Name        string         `json:"name"`
+	Description *string        `json:"description,omitempty"`
+	WeightG     *float64       `json:"weight_g,omitempty"`
+	WeightUnit  string         `json:"weight_unit,omitempty"`
+	Weight      *items.Measure `json:"weight,omitempty"`
+	Condition   string         `json:"condition"`
+	Notes       *string        `json:"notes,omitempty"`
+	Photos      []Photo        `json:"photos,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+}
+
+type Photo struct {
+	ID        string    `json:"id"`
+	GearID    string    `json:"gear_id"`
+	URL       string    `json:"url"`
+	Caption   *string   `json:"caption,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (gs *GearService) CreateGear(ctx context.Context, g *Gear) error {
+	if g.Condition == "" {
+		g.Condition = ConditionGood
+	}
+	if !validConditions[g.Condition] {
+		return ErrInvalidCondition
+	}
+	if g.Weight != nil {
+		grams, err := g.Weight.Grams()
+		if err != nil {
+			return err
+		}
+		g.WeightG, g.WeightUnit = &grams, g.Weight.Unit
+	}
+	if g.WeightUnit == "" {
+		g.WeightUnit = items.UnitGram
+	}
+	if !items.ValidWeightUnit(g.WeightUnit) {
+		return items.ErrUnknownUnit
+	}
+	g.ID = uuid.NewString()
+	g.CreatedAt = time.Now()
+
+	query := sq.Insert(TableGear).
+		Columns("id", "owner_id", "name", "description", "weight_g", "weight_unit", "condition", "notes", "created_at").
+		Values(g.ID, g.OwnerID, g.Name, g.Description, g.WeightG, g.WeightUnit, g.Condition, g.Notes, g.CreatedAt).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building create gear query: %w", err)
+	}
+	if _, err := gs.DB.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("error inserting gear: %w", err)
+	}
+	g.Weight = items.WeightIn(g.WeightG, g.WeightUnit)
+	return nil
+}
+
+func (gs *GearService) UpdateGear(ctx context.Context, id string, updates map[string]interface{}) error {
+	if len(updates) == 0 {
+		return fmt.Errorf("no fields to update")
+	}
+	allowed := map[string]bool{"name": true, "description": true, "weight_g": true, "weight_unit": true,
+		"condition": true, "notes": true}
+	for field, value := range updates {
+		if !allowed[field] {
+			return fmt.Errorf("field %s cannot be updated", field)
+		}
+		if c, ok := value.(string); field == "condition" && (!ok || !validConditions[c]) {
+			return ErrInvalidCondition
+		}
+		if u, ok := value.(string); field == "weight_unit" && (!ok || !items.ValidWeightUnit(u)) {
+			return items.ErrUnknownUnit
+		}
+	}
+
+	query := sq.Update(TableGear).
+		SetMap(updates).
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building update gear query: %w", err)
+	}
+	res, err := gs.DB.ExecContext(ctx, sqlStr, args...)
+	if err != nil {
+		return fmt.Errorf("error updating gear: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrGearNotFound
+	}
+	return nil
+}
+
+// DeleteGear removes gear from its owner's inventory; items it covered are
+// unlinked.
+func (gs *GearService) DeleteGear(ctx context.Context, id string) error {
+	query := sq.Delete(TableGear).Where(sq.Eq{"id": id}).PlaceholderFormat(sq.Dollar)
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building delete gear query: %w", err)
+	}
+	_, err = gs.DB.ExecContext(ctx, sqlStr, args...)
+	return err
+}
+
+// GetUserGear returns the inventory of a user with the photos of each piece.
+func (gs *GearService) GetUserGear(ctx context.Context, ownerID string) ([]Gear, error) {
+	query := sq.Select("id", "owner_id", "name", "description", "weight_g", "weight_unit", "condition", "notes", "created_at").
+		From(TableGear).
+		Where(sq.Eq{"owner_id": ownerID}).
+		OrderBy("name ASC", "id ASC").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building user gear query: %w", err)
+	}
+	rows, err := gs.DB.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying user gear: %w", err)
+	}
+	defer rows.Close()
+
+	inventory := []Gear{}
+	index := map[string]int{}
+	for rows.Next() {
+		var g Gear
+		var weightUnit sql.NullString
+		if err := rows.Scan(&g.ID, &g.OwnerID, &g.Name, &g.Description, &g.WeightG, &weightUnit,
+			&g.Condition, &g.Notes, &g.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning gear row: %w", err)
+		}
+		g.WeightUnit = weightUnit.String
+		g.Weight = items.WeightIn(g.WeightG, g.WeightUnit)
+		index[g.ID] = len(inventory)
+		inventory = append(inventory, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	photosQuery := sq.Select("p.id", "p.gear_id", "p.url", "p.caption", "p.created_at").
+		From(TableGearPhotos+" p").
+		Join(TableGear+" g ON g.id = p.gear_id").
+		Where(sq.Eq{"g.owner_id": ownerID}).
+		OrderBy("p.created_at ASC", "p.id ASC").
+		PlaceholderFormat(sq.Dollar)
+
+	photosSQL, photosArgs, err := photosQuery.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building gear photos query: %w", err)
+	}
+	photoRows, err := gs.DB.QueryContext(ctx, photosSQL, photosArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying gear photos: %w", err)
+	}
+	defer photoRows.Close()
+
+	for photoRows.Next() {
+		var p Photo
+		if err := photoRows.Scan(&p.ID, &p.GearID, &p.URL, &p.Caption, &p.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning gear photo row: %w", err)
+		}
+		g := &inventory[index[p.GearID]]
+		g.Photos = append(g.Photos, p)
+	}
+	if err := photoRows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return inventory, nil
+}
+
+// AddGearPhoto attaches a photo, stored elsewhere and referenced by URL, to a
+// piece of gear.
+func (gs *GearService) AddGearPhoto(ctx context.Context, p *Photo) error {
+	p.ID = uuid.NewString()
+	p.CreatedAt = time.Now()
+
+	query := sq.Insert(TableGearPhotos).
+		Columns("id", "gear_id", "url", "caption", "created_at").
+		Values(p.ID, p.GearID, p.URL, p.Caption, p.CreatedAt).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building add gear photo query: %w", err)
+	}
+	if _, err := gs.DB.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("error inserting gear photo: %w", err)
+	}
+	return nil
+}
+
+func (gs *GearService) DeleteGearPhoto(ctx context.Context, id string) error {
+	query := sq.Delete(TableGearPhotos).Where(sq.Eq{"id": id}).PlaceholderFormat(sq.Dollar)
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building delete gear photo query: %w", err)
+	}
+	_, err = gs.DB.ExecContext(ctx, sqlStr, args...)
+	return err
+}
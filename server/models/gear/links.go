@@ -0,0 +1,271 @@
+package gear
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/sunnymotiani/PackTrack/server/models/events"
+	"github.com/sunnymotiani/PackTrack/server/models/items"
+	"github.com/sunnymotiani/PackTrack/server/models/users"
+)
+
+// ITEM COVERAGE IMPLEMENTATION
+
+// GearRef identifies a piece of gear and who owns it.
+type GearRef struct {
+	GearID    string `json:"gear_id"`
+	Name      string `json:"name"`
+	OwnerID   string `json:"owner_id"`
+	OwnerName string `json:"owner_name"`
+	Condition string `json:"condition"`
+}
+
+type CoveredItem struct {
+	ItemID   string  `json:"item_id"`
+	ItemName string  `json:"item_name"`
+	Category string  `json:"category"`
+	Gear     GearRef `json:"gear"`
+}
+
+// UncoveredItem is an item no gear is linked to yet. Candidates is the gear
+// of event members with the same name as the item.
+type UncoveredItem struct {
+	ItemID     string    `json:"item_id"`
+	ItemName   string    `json:"item_name"`
+	Category   string    `json:"category"`
+	Candidates []GearRef `json:"candidates"`
+}
+
+type Coverage struct {
+	Covered   []CoveredItem   `json:"covered"`
+	Uncovered []UncoveredItem `json:"uncovered"`
+}
+
+// LinkItem records that a piece of gear covers an item, or unlinks the item
+// when gearID is nil. The gear's owner must be a member of the item's event
+// and the gear cannot already cover another item of the same event or of an
+// event whose dates overlap it; events without dates only conflict with
+// themselves. An item without a weight takes the gear's weight.
+func (gs *GearService) LinkItem(ctx context.Context, itemID string, gearID *string) error {
+	tx, err := gs.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	update := sq.Update(items.TableItems).
+		Set("gear_id", gearID).
+		Where(sq.Eq{"id": itemID}).
+		PlaceholderFormat(sq.Dollar)
+
+	if gearID != nil {
+		g, err := lockGear(ctx, tx, *gearID)
+		if err != nil {
+			return err
+		}
+		if g.Condition == ConditionRetired {
+			return ErrGearRetired
+		}
+		if err := checkGearAvailable(ctx, tx, itemID, g); err != nil {
+			return err
+		}
+		if g.WeightG != nil {
+			update = update.
+				Set("weight_unit", sq.Expr("CASE WHEN weight_g IS NULL THEN ? ELSE weight_unit END", g.WeightUnit)).
+				Set("weight_g", sq.Expr("COALESCE(weight_g, ?)", *g.WeightG))
+		}
+	}
+
+	sqlStr, args, err := update.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building link item query: %w", err)
+	}
+	res, err := tx.ExecContext(ctx, sqlStr, args...)
+	if err != nil {
+		return fmt.Errorf("error linking item to gear: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrItemNotFound
+	}
+	return tx.Commit()
+}
+
+// lockGear reads a piece of gear and locks its row so two items cannot claim
+// it concurrently.
+func lockGear(ctx context.Context, tx *sql.Tx, gearID string) (*Gear, error) {
+	query := sq.Select("id", "owner_id", "name", "weight_g", "weight_unit", "condition").
+		From(TableGear).
+		Where(sq.Eq{"id": gearID}).
+		Suffix("FOR UPDATE").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building lock gear query: %w", err)
+	}
+	g := &Gear{}
+	var weightUnit sql.NullString
+	err = tx.QueryRowContext(ctx, sqlStr, args...).
+		Scan(&g.ID, &g.OwnerID, &g.Name, &g.WeightG, &weightUnit, &g.Condition)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrGearNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching gear: %w", err)
+	}
+	g.WeightUnit = weightUnit.String
+	return g, nil
+}
+
+func checkGearAvailable(ctx context.Context, tx *sql.Tx, itemID string, g *Gear) error {
+	eventQuery := sq.Select("c.event_id", "e.starts_at", "COALESCE(e.ends_at, e.starts_at)").
+		Column(sq.Expr(fmt.Sprintf("EXISTS (SELECT 1 FROM %s em WHERE em.event_id = c.event_id AND em.user_id = ?)",
+			events.TableEventMemberships), g.OwnerID)).
+		From(items.TableItems + " i").
+		Join(items.TableCategories + " c ON c.id = i.category_id").
+		Join(events.TableEvents + " e ON e.id = c.event_id").
+		Where(sq.Eq{"i.id": itemID}).
+		PlaceholderFormat(sq.Dollar)
+
+	eventSQL, eventArgs, err := eventQuery.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building item event query: %w", err)
+	}
+
+	var eventID string
+	var startsAt, endsAt *time.Time
+	var ownerIsMember bool
+	err = tx.QueryRowContext(ctx, eventSQL, eventArgs...).Scan(&eventID, &startsAt, &endsAt, &ownerIsMember)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrItemNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("error fetching item event: %w", err)
+	}
+	if !ownerIsMember {
+		return ErrNotEventMember
+	}
+
+	overlap := sq.Or{sq.Eq{"c.event_id": eventID}}
+	if startsAt != nil {
+		overlap = append(overlap, sq.And{
+			sq.Expr("e.starts_at <= ?", *endsAt),
+			sq.Expr("COALESCE(e.ends_at, e.starts_at) >= ?", *startsAt),
+		})
+	}
+	conflictQuery := sq.Select("COUNT(*)").
+		From(items.TableItems + " i").
+		Join(items.TableCategories + " c ON c.id = i.category_id").
+		Join(events.TableEvents + " e ON e.id = c.event_id").
+		Where(sq.Eq{"i.gear_id": g.ID}).
+		Where(sq.NotEq{"i.id": itemID}).
+		Where(overlap).
+		PlaceholderFormat(sq.Dollar)
+
+	conflictSQL, conflictArgs, err := conflictQuery.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building gear conflict query: %w", err)
+	}
+	var conflicts int
+	if err := tx.QueryRowContext(ctx, conflictSQL, conflictArgs...).Scan(&conflicts); err != nil {
+		return fmt.Errorf("error checking gear conflicts: %w", err)
+	}
+	if conflicts > 0 {
+		return ErrGearConflict
+	}
+	return nil
+}
+
+// GetCoverage lists which items of an event are covered by someone's gear
+// and, for the others, matching gear event members own that is not retired.
+func (gs *GearService) GetCoverage(ctx context.Context, eventID string) (*Coverage, error) {
+	coverage := &Coverage{Covered: []CoveredItem{}, Uncovered: []UncoveredItem{}}
+
+	coveredQuery := sq.Select("i.id", "i.name", "c.name", "g.id", "g.name", "g.owner_id", "u.name", "g.condition").
+		From(items.TableItems+" i").
+		Join(items.TableCategories+" c ON c.id = i.category_id").
+		Join(TableGear+" g ON g.id = i.gear_id").
+		Join(users.TableUsers+" u ON u.id = g.owner_id").
+		Where(sq.Eq{"c.event_id": eventID}).
+		OrderBy("c.position ASC", "c.id ASC", "i.position ASC", "i.id ASC").
+		PlaceholderFormat(sq.Dollar)
+
+	coveredSQL, coveredArgs, err := coveredQuery.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building covered items query: %w", err)
+	}
+	rows, err := gs.DB.QueryContext(ctx, coveredSQL, coveredArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying covered items: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ci CoveredItem
+		if err := rows.Scan(&ci.ItemID, &ci.ItemName, &ci.Category, &ci.Gear.GearID, &ci.Gear.Name,
+			&ci.Gear.OwnerID, &ci.Gear.OwnerName, &ci.Gear.Condition); err != nil {
+			return nil, fmt.Errorf("error scanning covered item row: %w", err)
+		}
+		coverage.Covered = append(coverage.Covered, ci)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	candidates := fmt.Sprintf(`(%s g
+			JOIN %s em ON em.user_id = g.owner_id AND em.event_id = ?
+			JOIN %s u ON u.id = g.owner_id)
+		ON LOWER(g.name) = LOWER(i.name) AND g.condition <> ?`,
+		TableGear, events.TableEventMemberships, users.TableUsers)
+	uncoveredQuery := sq.Select("i.id", "i.name", "c.name", "g.id", "g.name", "g.owner_id", "u.name", "g.condition").
+		From(items.TableItems+" i").
+		Join(items.TableCategories+" c ON c.id = i.category_id").
+		LeftJoin(candidates, eventID, ConditionRetired).
+		Where(sq.Eq{"c.event_id": eventID, "i.gear_id": nil}).
+		OrderBy("c.position ASC", "c.id ASC", "i.position ASC", "i.id ASC", "u.name ASC").
+		PlaceholderFormat(sq.Dollar)
+
+	uncoveredSQL, uncoveredArgs, err := uncoveredQuery.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building uncovered items query: %w", err)
+	}
+	uncoveredRows, err := gs.DB.QueryContext(ctx, uncoveredSQL, uncoveredArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying uncovered items: %w", err)
+	}
+	defer uncoveredRows.Close()
+
+	index := map[string]int{}
+	for uncoveredRows.Next() {
+		var ui UncoveredItem
+		var gearID, gearName, ownerID, ownerName, condition sql.NullString
+		if err := uncoveredRows.Scan(&ui.ItemID, &ui.ItemName, &ui.Category, &gearID, &gearName,
+			&ownerID, &ownerName, &condition); err != nil {
+			return nil, fmt.Errorf("error scanning uncovered item row: %w", err)
+		}
+		i, ok := index[ui.ItemID]
+		if !ok {
+			i = len(coverage.Uncovered)
+			index[ui.ItemID] = i
+			ui.Candidates = []GearRef{}
+			coverage.Uncovered = append(coverage.Uncovered, ui)
+		}
+		if gearID.Valid {
+			coverage.Uncovered[i].Candidates = append(coverage.Uncovered[i].Candidates, GearRef{
+				GearID:    gearID.String,
+				Name:      gearName.String,
+				OwnerID:   ownerID.String,
+				OwnerName: ownerName.String,
+				Condition: condition.String,
+			})
+		}
+	}
+	if err := uncoveredRows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return coverage, nil
+}
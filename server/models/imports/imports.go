@@ -0,0 +1,209 @@
+package imports
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/sunnymotiani/PackTrack/server/models/events"
+	"github.com/sunnymotiani/PackTrack/server/models/items"
+	"github.com/sunnymotiani/PackTrack/server/models/users"
+)
+
+// Columns an import file can have. Headers are matched case-insensitively
+// and may use any of the aliases in columnAliases.
+const (
+	ColumnCategory = "category"
+	ColumnName     = "name"
+	ColumnQuantity = "quantity"
+	ColumnAssignee = "assignee_email"
+	ColumnNotes    = "notes"
+)
+
+var columnAliases = map[string]string{
+	"category":       ColumnCategory,
+	"name":           ColumnName,
+	"item":           ColumnName,
+	"quantity":       ColumnQuantity,
+	"qty":            ColumnQuantity,
+	"assignee_email": ColumnAssignee,
+	"assignee":       ColumnAssignee,
+	"email":          ColumnAssignee,
+	"notes":          ColumnNotes,
+}
+
+// MaxRows bounds the number of items one file can import.
+const MaxRows = 2000
+
+var (
+	ErrEmptyFile      = errors.New("import file has no header row")
+	ErrMissingColumns = errors.New("import file needs category and name columns")
+	ErrTooManyRows    = fmt.Errorf("import file has more than %d rows", MaxRows)
+	ErrInvalidRows    = errors.New("import file has invalid rows; nothing was imported")
+)
+
+type ImportService struct {
+	DB    *sql.DB
+	Items *items.ItemsService
+}
+
+// RowError explains why a row was rejected. Row is the line number in the
+// file, counting the header as row 1.
+type RowError struct {
+	Row    int    `json:"row"`
+	Column string `json:"column,omitempty"`
+	Msg    string `json:"msg"`
+}
+
+// Result reports an import. When Errors is not empty nothing was imported.
+type Result struct {
+	Imported   int          `json:"imported"`
+	Categories []string     `json:"categories"`
+	Items      []items.Item `json:"items"`
+	Errors     []RowError   `json:"errors"`
+}
+
+// ImportItems validates every row of a parsed file and, if they are all
+// valid, adds them to the event in a single transaction for userID, creating
+// missing categories. Blank rows are skipped. When any row is invalid the
+// result lists every problem and ErrInvalidRows is returned. userID must be
+// allowed to add items to every category the file names, as
+// items.CanAddToCategoryPath decides.
+func (s *ImportService) ImportItems(ctx context.Context, eventID, userID string, rows [][]string) (*Result, error) {
+	if err := s.Items.CanEditEvent(ctx, eventID, userID); err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrEmptyFile
+	}
+	if len(rows) > MaxRows+1 {
+		return nil, ErrTooManyRows
+	}
+	columns := map[string]int{}
+	for i, header := range rows[0] {
+		key := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(header)), " ", "_")
+		if column, ok := columnAliases[key]; ok {
+			if _, dup := columns[column]; !dup {
+				columns[column] = i
+			}
+		}
+	}
+	if _, ok := columns[ColumnCategory]; !ok {
+		return nil, ErrMissingColumns
+	}
+	if _, ok := columns[ColumnName]; !ok {
+		return nil, ErrMissingColumns
+	}
+
+	members, err := s.assignableMembers(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Categories: []string{}, Items: []items.Item{}, Errors: []RowError{}}
+	seenCategories := map[string]bool{}
+	var drafts []items.ItemDraft
+	for i, row := range rows[1:] {
+		line := i + 2
+		cell := func(column string) string {
+			idx, ok := columns[column]
+			if !ok || idx >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[idx])
+		}
+		if strings.TrimSpace(strings.Join(row, "")) == "" {
+			continue
+		}
+		rowErr := func(column, msg string) {
+			result.Errors = append(result.Errors, RowError{Row: line, Column: column, Msg: msg})
+		}
+
+		draft := items.ItemDraft{CategoryName: cell(ColumnCategory)}
+		draft.Item.Name = cell(ColumnName)
+		if draft.CategoryName == "" {
+			rowErr(ColumnCategory, "category is required")
+		}
+		if draft.Item.Name == "" {
+			rowErr(ColumnName, "name is required")
+		}
+		draft.Item.Quantity = 1
+		if v := cell(ColumnQuantity); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				rowErr(ColumnQuantity, fmt.Sprintf("quantity %q is not a positive whole number", v))
+			}
+			draft.Item.Quantity = n
+		}
+		if email := cell(ColumnAssignee); email != "" {
+			userID, ok := members[strings.ToLower(email)]
+			if !ok {
+				rowErr(ColumnAssignee, fmt.Sprintf("%s is not a member of this event who can hold items", email))
+			}
+			draft.Item.AssignedTo = &userID
+		}
+		if notes := cell(ColumnNotes); notes != "" {
+			draft.Item.Notes = &notes
+		}
+
+		if draft.CategoryName != "" && !seenCategories[draft.CategoryName] {
+			seenCategories[draft.CategoryName] = true
+			result.Categories = append(result.Categories, draft.CategoryName)
+		}
+		drafts = append(drafts, draft)
+	}
+	if len(result.Errors) > 0 {
+		return result, ErrInvalidRows
+	}
+	for _, category := range result.Categories {
+		if err := s.Items.CanAddToCategoryPath(ctx, eventID, category, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	created, err := s.Items.AddItemsToEvent(ctx, eventID, drafts)
+	if err != nil {
+		return nil, err
+	}
+	result.Items = created
+	result.Imported = len(created)
+	return result, nil
+}
+
+// assignableMembers maps the lower-cased email of every event member who
+// can hold items to their user id.
+func (s *ImportService) assignableMembers(ctx context.Context, eventID string) (map[string]string, error) {
+	query := sq.Select("u.id", "u.email").
+		From(events.TableEventMemberships + " em").
+		Join(users.TableUsers + " u ON u.id = em.user_id").
+		Where(sq.Eq{"em.event_id": eventID}).
+		Where(sq.NotEq{"em.role": "viewer"}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building event members query: %w", err)
+	}
+	rows, err := s.DB.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying event members: %w", err)
+	}
+	defer rows.Close()
+
+	members := map[string]string{}
+	for rows.Next() {
+		var id, email string
+		if err := rows.Scan(&id, &email); err != nil {
+			return nil, fmt.Errorf("error scanning member row: %w", err)
+		}
+		members[strings.ToLower(email)] = id
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return members, nil
+}
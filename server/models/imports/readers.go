@@ -0,0 +1,47 @@
+package imports
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// FILE READERS
+
+var ErrNoSheets = errors.New("spreadsheet has no sheets")
+
+// ReadCSV reads every record of a CSV file. Rows may have different numbers
+// of fields; missing trailing cells read as empty.
+func ReadCSV(r io.Reader) ([][]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error reading csv: %w", err)
+	}
+	return rows, nil
+}
+
+// ReadXLSX reads the rows of the first sheet of a spreadsheet, as the
+// displayed cell values.
+func ReadXLSX(r io.Reader) ([][]string, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("error opening spreadsheet: %w", err)
+	}
+	defer f.Close()
+
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, ErrNoSheets
+	}
+	rows, err := f.GetRows(sheets[0])
+	if err != nil {
+		return nil, fmt.Errorf("error reading spreadsheet: %w", err)
+	}
+	return rows, nil
+}
@@ -0,0 +1,76 @@
+package items
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/sunnymotiani/PackTrack/server/models/activity"
+)
+
+// ACTIVITY IMPLEMENTATION
+
+// publish reports a committed change to an item. Recipients default to the
+// item's current assignees; format receives the item name.
+func (is *ItemsService) publish(ctx context.Context, kind, itemID, actorID string, recipients []string, format string, args ...interface{}) {
+	if is.Activity == nil {
+		return
+	}
+	query := sq.Select("c.event_id", "i.name").
+		From(TableItems + " i").
+		Join(TableCategories + " c ON c.id = i.category_id").
+		Where(sq.Eq{"i.id": itemID}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, sqlArgs, err := query.ToSql()
+	if err != nil {
+		log.Printf("activity: building item query: %v", err)
+		return
+	}
+	var eventID, name string
+	if err := is.DB.QueryRowContext(ctx, sqlStr, sqlArgs...).Scan(&eventID, &name); err != nil {
+		log.Printf("activity: fetching item %s: %v", itemID, err)
+		return
+	}
+	if recipients == nil {
+		recipients = is.assigneeIDs(ctx, itemID)
+	}
+
+	activity.Publish(ctx, is.Activity, activity.Activity{
+		Type:       kind,
+		EventID:    eventID,
+		ItemID:     itemID,
+		ActorID:    actorID,
+		Recipients: recipients,
+		Message:    fmt.Sprintf(format, append([]interface{}{name}, args...)...),
+	})
+}
+
+// assigneeIDs lists the users assigned to an item, or nil if that fails.
+func (is *ItemsService) assigneeIDs(ctx context.Context, itemID string) []string {
+	assignments, err := queryAssignments(ctx, is.DB, sq.Eq{"item_id": itemID})
+	if err != nil {
+		log.Printf("activity: fetching assignees of %s: %v", itemID, err)
+		return nil
+	}
+	ids := make([]string, 0, len(assignments))
+	for _, a := range assignments {
+		ids = append(ids, a.UserID)
+	}
+	return ids
+}
+
+// withAssignees adds the item's current assignees to userIDs, skipping
+// duplicates, so that members who lost an assignment hear about it too.
+func (is *ItemsService) withAssignees(ctx context.Context, itemID string, userIDs []string) []string {
+	seen := map[string]bool{}
+	all := []string{}
+	for _, id := range append(userIDs, is.assigneeIDs(ctx, itemID)...) {
+		if !seen[id] {
+			seen[id] = true
+			all = append(all, id)
+		}
+	}
+	return all
+}
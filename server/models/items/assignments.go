@@ -0,0 +1,329 @@
+package items
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/sunnymotiani/PackTrack/server/models/activity"
+	"github.com/sunnymotiani/PackTrack/server/models/events"
+)
+
+// ASSIGNMENTS IMPLEMENTATION
+
+const TableItemAssignments = "item_assignments"
+
+var (
+	ErrAssignmentQuantity = errors.New("assigned quantities must be positive and add up to at most the item quantity")
+	ErrNotEventMember     = errors.New("user is not a member of the item's event")
+	ErrNothingToAssign    = errors.New("item has a quantity of 0, so there is nothing to assign")
+)
+
+// Assignment is the share of an item one member is bringing, with its own
+// packing progress.
+type Assignment struct {
+	ID                string    `json:"id"`
+	ItemID            string    `json:"item_id"`
+	UserID            string    `json:"user_id"`
+	Quantity          int       `json:"quantity"`
+	PackedQuantity    int       `json:"packed_quantity"`
+	DeliveredQuantity int       `json:"delivered_quantity"`
+	Status            string    `json:"status"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+type AssignmentInput struct {
+	UserID   string `json:"user_id"`
+	Quantity int    `json:"quantity"`
+}
+
+func (is *ItemsService) GetItemAssignments(ctx context.Context, itemID string) ([]Assignment, error) {
+	return queryAssignments(ctx, is.DB, sq.Eq{"item_id": itemID})
+}
+
+// SetItemAssignments replaces the assignees of an item. Members who keep an
+// assignment keep their packing progress; the item's assigned_to is set to
+// the assignee when there is exactly one and cleared otherwise.
+func (is *ItemsService) SetItemAssignments(ctx context.Context, itemID string, assignments []AssignmentInput) ([]Assignment, error) {
+	tx, err := is.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	previous := is.assigneeIDs(ctx, itemID)
+	if err := setItemAssignments(ctx, tx, itemID, assignments); err != nil {
+		return nil, err
+	}
+	result, err := queryAssignments(ctx, tx, sq.Eq{"item_id": itemID})
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	is.publish(ctx, activity.TypeAssignment, itemID, "", is.withAssignees(ctx, itemID, previous), "Assignees of %s changed")
+	return result, nil
+}
+
+// AdjustAssignmentProgress changes the packed and delivered counts of one
+// assignee's share and rolls the same deltas up into the item.
+func (is *ItemsService) AdjustAssignmentProgress(ctx context.Context, itemID, userID string, packedDelta, deliveredDelta int) (*Assignment, error) {
+	if packedDelta == 0 && deliveredDelta == 0 {
+		return nil, fmt.Errorf("no quantity change requested")
+	}
+
+	tx, err := is.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	selectQuery := sq.Select("id", "quantity", "packed_quantity", "delivered_quantity").
+		From(TableItemAssignments).
+		Where(sq.Eq{"item_id": itemID, "user_id": userID}).
+		Suffix("FOR UPDATE").
+		PlaceholderFormat(sq.Dollar)
+
+	selectSQL, selectArgs, err := selectQuery.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building select assignment query: %w", err)
+	}
+
+	a := Assignment{ItemID: itemID, UserID: userID}
+	err = tx.QueryRowContext(ctx, selectSQL, selectArgs...).
+		Scan(&a.ID, &a.Quantity, &a.PackedQuantity, &a.DeliveredQuantity)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching assignment: %w", err)
+	}
+
+	a.PackedQuantity += packedDelta
+	a.DeliveredQuantity += deliveredDelta
+	if a.DeliveredQuantity < 0 || a.DeliveredQuantity > a.PackedQuantity || a.PackedQuantity > a.Quantity {
+		return nil, ErrInvalidProgress
+	}
+	a.Status = DeriveStatus(a.Quantity, a.PackedQuantity, a.DeliveredQuantity)
+
+	updateQuery := sq.Update(TableItemAssignments).
+		Set("packed_quantity", a.PackedQuantity).
+		Set("delivered_quantity", a.DeliveredQuantity).
+		Set("status", a.Status).
+		Where(sq.Eq{"id": a.ID}).
+		PlaceholderFormat(sq.Dollar)
+
+	updateSQL, updateArgs, err := updateQuery.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building update assignment query: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, updateSQL, updateArgs...); err != nil {
+		return nil, fmt.Errorf("error updating assignment progress: %w", err)
+	}
+
+	if _, err := adjustItemProgress(ctx, tx, itemID, userID, packedDelta, deliveredDelta); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	is.publish(ctx, activity.TypeStatusChange, itemID, userID, nil, "%s: %d of %d packed, %d delivered",
+		a.PackedQuantity, a.Quantity, a.DeliveredQuantity)
+	return &a, nil
+}
+
+// wholeItem is the assignment giving userID every unit of an item. An item
+// with a quantity of 0 has no units to hand out and fails with
+// ErrNothingToAssign.
+func wholeItem(current *ItemProgress, userID string) ([]AssignmentInput, error) {
+	if current.Quantity <= 0 {
+		return nil, ErrNothingToAssign
+	}
+	return []AssignmentInput{{UserID: userID, Quantity: current.Quantity}}, nil
+}
+
+func setItemAssignments(ctx context.Context, tx *sql.Tx, itemID string, assignments []AssignmentInput) error {
+	current, err := lockItemProgress(ctx, tx, itemID)
+	if err != nil {
+		return err
+	}
+
+	total := 0
+	userIDs := make([]string, 0, len(assignments))
+	seen := map[string]bool{}
+	for _, a := range assignments {
+		if a.Quantity <= 0 || seen[a.UserID] {
+			return ErrAssignmentQuantity
+		}
+		seen[a.UserID] = true
+		total += a.Quantity
+		userIDs = append(userIDs, a.UserID)
+	}
+	if total > current.Quantity {
+		return ErrAssignmentQuantity
+	}
+	if err := checkEventMembers(ctx, tx, itemID, userIDs); err != nil {
+		return err
+	}
+
+	existing, err := queryAssignments(ctx, tx, sq.Eq{"item_id": itemID})
+	if err != nil {
+		return err
+	}
+	byUser := map[string]Assignment{}
+	for _, a := range existing {
+		byUser[a.UserID] = a
+	}
+
+	deleteQuery := sq.Delete(TableItemAssignments).Where(sq.Eq{"item_id": itemID})
+	if len(userIDs) > 0 {
+		deleteQuery = deleteQuery.Where(sq.NotEq{"user_id": userIDs})
+	}
+	deleteSQL, deleteArgs, err := deleteQuery.PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return fmt.Errorf("error building delete assignments query: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, deleteSQL, deleteArgs...); err != nil {
+		return fmt.Errorf("error removing assignments: %w", err)
+	}
+
+	for _, input := range assignments {
+		prev, ok := byUser[input.UserID]
+		packed := min(prev.PackedQuantity, input.Quantity)
+		delivered := min(prev.DeliveredQuantity, packed)
+		if !ok {
+			prev.ID = uuid.NewString()
+		}
+		upsert := sq.Insert(TableItemAssignments).
+			Columns("id", "item_id", "user_id", "quantity", "packed_quantity", "delivered_quantity", "status").
+			Values(prev.ID, itemID, input.UserID, input.Quantity, packed, delivered,
+				DeriveStatus(input.Quantity, packed, delivered)).
+			Suffix(`ON CONFLICT (item_id, user_id) DO UPDATE SET quantity = EXCLUDED.quantity,
+				packed_quantity = EXCLUDED.packed_quantity,
+				delivered_quantity = EXCLUDED.delivered_quantity,
+				status = EXCLUDED.status`).
+			PlaceholderFormat(sq.Dollar)
+
+		upsertSQL, upsertArgs, err := upsert.ToSql()
+		if err != nil {
+			return fmt.Errorf("error building upsert assignment query: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, upsertSQL, upsertArgs...); err != nil {
+			return fmt.Errorf("error saving assignment: %w", err)
+		}
+	}
+
+	return syncAssignedTo(ctx, tx, itemID)
+}
+
+// syncAssignedTo points items.assigned_to at the only assignee of the item,
+// or clears it when the item has none or several.
+func syncAssignedTo(ctx context.Context, tx *sql.Tx, itemID string) error {
+	sole := sq.Select("CASE WHEN COUNT(*) = 1 THEN (array_agg(user_id))[1] END").
+		From(TableItemAssignments).
+		Where(sq.Eq{"item_id": itemID})
+
+	updateQuery := sq.Update(TableItems).
+		Set("assigned_to", sq.Expr("(?)", sole)).
+		Where(sq.Eq{"id": itemID}).
+		PlaceholderFormat(sq.Dollar)
+
+	updateSQL, updateArgs, err := updateQuery.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building update assigned_to query: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, updateSQL, updateArgs...); err != nil {
+		return fmt.Errorf("error updating assigned_to: %w", err)
+	}
+	return nil
+}
+
+// resetAssignmentProgress brings every assignment of an item in line with a
+// status set on the whole item.
+func resetAssignmentProgress(ctx context.Context, tx *sql.Tx, itemID, status string) error {
+	packed, delivered := "0", "0"
+	switch status {
+	case StatusPacked:
+		packed = "quantity"
+	case StatusDelivered:
+		packed, delivered = "quantity", "quantity"
+	}
+
+	query := sq.Update(TableItemAssignments).
+		Set("packed_quantity", sq.Expr(packed)).
+		Set("delivered_quantity", sq.Expr(delivered)).
+		Set("status", status).
+		Where(sq.Eq{"item_id": itemID}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building reset assignments query: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("error resetting assignment progress: %w", err)
+	}
+	return nil
+}
+
+// checkEventMembers returns ErrNotEventMember unless every user belongs to
+// the event the item is part of.
+func checkEventMembers(ctx context.Context, q queryer, itemID string, userIDs []string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	query := sq.Select("COUNT(DISTINCT em.user_id)").
+		From(TableItems + " i").
+		Join(TableCategories + " c ON c.id = i.category_id").
+		Join(events.TableEventMemberships + " em ON em.event_id = c.event_id").
+		Where(sq.Eq{"i.id": itemID, "em.user_id": userIDs}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building membership check query: %w", err)
+	}
+	var count int
+	if err := q.QueryRowContext(ctx, sqlStr, args...).Scan(&count); err != nil {
+		return fmt.Errorf("error checking event membership: %w", err)
+	}
+	if count != len(userIDs) {
+		return ErrNotEventMember
+	}
+	return nil
+}
+
+func queryAssignments(ctx context.Context, q queryer, where sq.Sqlizer) ([]Assignment, error) {
+	query := sq.Select("id", "item_id", "user_id", "quantity", "packed_quantity", "delivered_quantity", "status", "created_at").
+		From(TableItemAssignments).
+		Where(where).
+		OrderBy("created_at ASC", "id ASC").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building select assignments query: %w", err)
+	}
+
+	rows, err := q.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying assignments: %w", err)
+	}
+	defer rows.Close()
+
+	var assignments []Assignment
+	for rows.Next() {
+		var a Assignment
+		if err := rows.Scan(&a.ID, &a.ItemID, &a.UserID, &a.Quantity, &a.PackedQuantity,
+			&a.DeliveredQuantity, &a.Status, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning assignment row: %w", err)
+		}
+		assignments = append(assignments, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return assignments, nil
+}
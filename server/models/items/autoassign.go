@@ -0,0 +1,431 @@
+package items
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/sunnymotiani/PackTrack/server/models/activity"
+	"github.com/sunnymotiani/PackTrack/server/models/events"
+)
+
+// AUTO ASSIGN IMPLEMENTATION
+
+const (
+	BalanceByCount    = "count"
+	BalanceByQuantity = "quantity"
+	BalanceByWeight   = "weight"
+)
+
+var (
+	ErrUnknownBalance = errors.New("balance_by must be count, quantity or weight")
+	ErrNoMembers      = errors.New("at least one member is required")
+	ErrPlanStale      = errors.New("an item in the plan is no longer unassigned or not in this event")
+	ErrPlanInvalid    = errors.New("the plan breaks its member, exclusion or capacity options")
+)
+
+// AutoAssignOptions controls how unassigned items are spread over members.
+// Exclusions maps a member to item or category IDs they must not receive and
+// Capacity caps a member's total load, counted in the BalanceBy unit (grams
+// when balancing by weight) and including what they already carry. Items
+// without a weight weigh nothing when balancing by weight.
+type AutoAssignOptions struct {
+	MemberIDs   []string            `json:"member_ids"`
+	BalanceBy   string              `json:"balance_by"`
+	CategoryIDs []string            `json:"category_ids,omitempty"`
+	Exclusions  map[string][]string `json:"exclusions,omitempty"`
+	Capacity    map[string]float64  `json:"capacity,omitempty"`
+}
+
+type ProposedAssignment struct {
+	ItemID     string  `json:"item_id"`
+	ItemName   string  `json:"item_name"`
+	CategoryID string  `json:"category_id"`
+	UserID     string  `json:"user_id"`
+	Quantity   int     `json:"quantity"`
+	Load       float64 `json:"load"`
+}
+
+type SkippedItem struct {
+	ItemID   string `json:"item_id"`
+	ItemName string `json:"item_name"`
+	Reason   string `json:"reason"`
+}
+
+// AutoAssignPlan is the preview of an auto-assign run: the assignments that
+// would be created, the items no member could take and each member's load
+// before and after.
+type AutoAssignPlan struct {
+	EventID     string               `json:"event_id"`
+	BalanceBy   string               `json:"balance_by"`
+	Assignments []ProposedAssignment `json:"assignments"`
+	Skipped     []SkippedItem        `json:"skipped"`
+	LoadBefore  map[string]float64   `json:"load_before"`
+	LoadAfter   map[string]float64   `json:"load_after"`
+}
+
+type autoAssignCandidate struct {
+	ItemID     string
+	Name       string
+	CategoryID string
+	Quantity   int
+	WeightG    float64
+}
+
+// PlanAutoAssign computes, without saving anything, how the unassigned items
+// of an event would be distributed across the selected members. Items are
+// handed out largest first, each to the eligible member with the lowest load.
+func (is *ItemsService) PlanAutoAssign(ctx context.Context, eventID string, opts AutoAssignOptions) (*AutoAssignPlan, error) {
+	if err := opts.normalize(); err != nil {
+		return nil, err
+	}
+	if err := checkAssignableMembers(ctx, is.DB, eventID, opts.MemberIDs); err != nil {
+		return nil, err
+	}
+
+	candidates, err := is.unassignedItems(ctx, eventID, opts.CategoryIDs)
+	if err != nil {
+		return nil, err
+	}
+	loads, err := memberLoads(ctx, is.DB, eventID, opts.MemberIDs, opts.BalanceBy)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := planAutoAssign(candidates, loads, opts)
+	plan.EventID = eventID
+	return plan, nil
+}
+
+// ApplyAutoAssign commits a previewed plan made with opts. It fails with
+// ErrPlanStale and changes nothing if any item was assigned or changed its
+// quantity since the preview, and with ErrPlanInvalid if the plan does not
+// keep to the members, exclusions and capacities of opts.
+func (is *ItemsService) ApplyAutoAssign(ctx context.Context, eventID string, opts AutoAssignOptions, assignments []ProposedAssignment) error {
+	if err := opts.normalize(); err != nil {
+		return err
+	}
+	tx, err := is.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := checkAssignableMembers(ctx, tx, eventID, opts.MemberIDs); err != nil {
+		return err
+	}
+	members := map[string]bool{}
+	for _, id := range opts.MemberIDs {
+		members[id] = true
+	}
+	categories := map[string]bool{}
+	for _, id := range opts.CategoryIDs {
+		categories[id] = true
+	}
+	excluded := exclusionSets(opts)
+
+	ordered := append([]ProposedAssignment(nil), assignments...)
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].ItemID < ordered[j].ItemID })
+
+	for _, a := range ordered {
+		current, err := lockItemProgress(ctx, tx, a.ItemID)
+		if err != nil {
+			return err
+		}
+		c, itemEventID, err := autoAssignCandidateFor(ctx, tx, a.ItemID)
+		if err != nil {
+			return err
+		}
+		existing, err := queryAssignments(ctx, tx, sq.Eq{"item_id": a.ItemID})
+		if err != nil {
+			return err
+		}
+		if itemEventID != eventID || len(existing) > 0 || c.Quantity != a.Quantity {
+			return ErrPlanStale
+		}
+		if !members[a.UserID] || excluded[a.UserID][c.ItemID] || excluded[a.UserID][c.CategoryID] ||
+			len(categories) > 0 && !categories[c.CategoryID] {
+			return ErrPlanInvalid
+		}
+		if err := checkCanHoldItems(ctx, tx, a.ItemID, a.UserID); err != nil {
+			return err
+		}
+		whole, err := wholeItem(current, a.UserID)
+		if err != nil {
+			return err
+		}
+		if err := setItemAssignments(ctx, tx, a.ItemID, whole); err != nil {
+			return err
+		}
+	}
+
+	// Capacity counts what members carry once the whole plan is in.
+	if len(opts.Capacity) > 0 {
+		loads, err := memberLoads(ctx, tx, eventID, opts.MemberIDs, opts.BalanceBy)
+		if err != nil {
+			return err
+		}
+		for member, limit := range opts.Capacity {
+			if members[member] && loads[member] > limit {
+				return ErrPlanInvalid
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	for _, a := range ordered {
+		is.publish(ctx, activity.TypeAssignment, a.ItemID, "", []string{a.UserID}, "%s was assigned to you")
+	}
+	return nil
+}
+
+// normalize defaults BalanceBy and checks the options can be planned with.
+func (opts *AutoAssignOptions) normalize() error {
+	if opts.BalanceBy == "" {
+		opts.BalanceBy = BalanceByCount
+	}
+	if opts.BalanceBy != BalanceByCount && opts.BalanceBy != BalanceByQuantity && opts.BalanceBy != BalanceByWeight {
+		return ErrUnknownBalance
+	}
+	if len(opts.MemberIDs) == 0 {
+		return ErrNoMembers
+	}
+	return nil
+}
+
+// exclusionSets turns Exclusions into a lookup by member and item or
+// category id.
+func exclusionSets(opts AutoAssignOptions) map[string]map[string]bool {
+	excluded := map[string]map[string]bool{}
+	for member, ids := range opts.Exclusions {
+		excluded[member] = map[string]bool{}
+		for _, id := range ids {
+			excluded[member][id] = true
+		}
+	}
+	return excluded
+}
+
+func planAutoAssign(candidates []autoAssignCandidate, loads map[string]float64, opts AutoAssignOptions) *AutoAssignPlan {
+	plan := &AutoAssignPlan{
+		BalanceBy:   opts.BalanceBy,
+		Assignments: []ProposedAssignment{},
+		Skipped:     []SkippedItem{},
+		LoadBefore:  map[string]float64{},
+		LoadAfter:   map[string]float64{},
+	}
+	for id, load := range loads {
+		plan.LoadBefore[id] = load
+		plan.LoadAfter[id] = load
+	}
+
+	excluded := exclusionSets(opts)
+
+	loadOf := func(c autoAssignCandidate) float64 {
+		switch opts.BalanceBy {
+		case BalanceByQuantity:
+			return float64(c.Quantity)
+		case BalanceByWeight:
+			return c.WeightG
+		}
+		return 1
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		li, lj := loadOf(candidates[i]), loadOf(candidates[j])
+		if li != lj {
+			return li > lj
+		}
+		return candidates[i].Name < candidates[j].Name
+	})
+
+	for _, c := range candidates {
+		if c.Quantity <= 0 {
+			plan.Skipped = append(plan.Skipped, SkippedItem{ItemID: c.ItemID, ItemName: c.Name, Reason: "item has a quantity of 0"})
+			continue
+		}
+		load := loadOf(c)
+		best := ""
+		reason := "excluded for every member"
+		for _, member := range opts.MemberIDs {
+			if excluded[member][c.ItemID] || excluded[member][c.CategoryID] {
+				continue
+			}
+			if limit, ok := opts.Capacity[member]; ok && plan.LoadAfter[member]+load > limit {
+				reason = "no member has enough capacity left"
+				continue
+			}
+			if best == "" || plan.LoadAfter[member] < plan.LoadAfter[best] {
+				best = member
+			}
+		}
+		if best == "" {
+			plan.Skipped = append(plan.Skipped, SkippedItem{ItemID: c.ItemID, ItemName: c.Name, Reason: reason})
+			continue
+		}
+		plan.LoadAfter[best] += load
+		plan.Assignments = append(plan.Assignments, ProposedAssignment{
+			ItemID:     c.ItemID,
+			ItemName:   c.Name,
+			CategoryID: c.CategoryID,
+			UserID:     best,
+			Quantity:   c.Quantity,
+			Load:       load,
+		})
+	}
+	return plan
+}
+
+func (is *ItemsService) unassignedItems(ctx context.Context, eventID string, categoryIDs []string) ([]autoAssignCandidate, error) {
+	query := sq.Select("i.id", "i.name", "i.category_id", "COALESCE(i.quantity, 0)",
+		"COALESCE(i.weight_g, 0) * COALESCE(i.quantity, 0)").
+		From(TableItems+" i").
+		Join(TableCategories+" c ON c.id = i.category_id").
+		Where(sq.Eq{"c.event_id": eventID}).
+		Where("NOT EXISTS (SELECT 1 FROM "+TableItemAssignments+" ia WHERE ia.item_id = i.id)").
+		OrderBy("i.created_at ASC", "i.id ASC").
+		PlaceholderFormat(sq.Dollar)
+	if len(categoryIDs) > 0 {
+		query = query.Where(sq.Eq{"i.category_id": categoryIDs})
+	}
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building unassigned items query: %w", err)
+	}
+	rows, err := is.DB.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying unassigned items: %w", err)
+	}
+	defer rows.Close()
+
+	var candidates []autoAssignCandidate
+	for rows.Next() {
+		var c autoAssignCandidate
+		if err := rows.Scan(&c.ItemID, &c.Name, &c.CategoryID, &c.Quantity, &c.WeightG); err != nil {
+			return nil, fmt.Errorf("error scanning unassigned item row: %w", err)
+		}
+		candidates = append(candidates, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return candidates, nil
+}
+
+// autoAssignCandidateFor reads one item as auto-assign sees it, with the
+// event it belongs to.
+func autoAssignCandidateFor(ctx context.Context, q queryer, itemID string) (autoAssignCandidate, string, error) {
+	query := sq.Select("i.id", "i.name", "i.category_id", "COALESCE(i.quantity, 0)",
+		"COALESCE(i.weight_g, 0) * COALESCE(i.quantity, 0)", "c.event_id").
+		From(TableItems + " i").
+		Join(TableCategories + " c ON c.id = i.category_id").
+		Where(sq.Eq{"i.id": itemID}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return autoAssignCandidate{}, "", fmt.Errorf("error building auto assign item query: %w", err)
+	}
+	var c autoAssignCandidate
+	var eventID string
+	err = q.QueryRowContext(ctx, sqlStr, args...).Scan(&c.ItemID, &c.Name, &c.CategoryID, &c.Quantity, &c.WeightG, &eventID)
+	if err != nil {
+		return autoAssignCandidate{}, "", fmt.Errorf("error fetching auto assign item: %w", err)
+	}
+	return c, eventID, nil
+}
+
+// memberLoads returns what each member already carries in the event,
+// counted in the balance unit.
+func memberLoads(ctx context.Context, q queryer, eventID string, memberIDs []string, balanceBy string) (map[string]float64, error) {
+	load := "COUNT(*)"
+	switch balanceBy {
+	case BalanceByQuantity:
+		load = "COALESCE(SUM(ia.quantity), 0)"
+	case BalanceByWeight:
+		load = "COALESCE(SUM(ia.quantity * COALESCE(i.weight_g, 0)), 0)"
+	}
+	query := sq.Select("ia.user_id", load).
+		From(TableItemAssignments + " ia").
+		Join(TableItems + " i ON i.id = ia.item_id").
+		Join(TableCategories + " c ON c.id = i.category_id").
+		Where(sq.Eq{"c.event_id": eventID, "ia.user_id": memberIDs}).
+		GroupBy("ia.user_id").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building member loads query: %w", err)
+	}
+	rows, err := q.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying member loads: %w", err)
+	}
+	defer rows.Close()
+
+	loads := make(map[string]float64, len(memberIDs))
+	for _, id := range memberIDs {
+		loads[id] = 0
+	}
+	for rows.Next() {
+		var userID string
+		var l float64
+		if err := rows.Scan(&userID, &l); err != nil {
+			return nil, fmt.Errorf("error scanning member load row: %w", err)
+		}
+		loads[userID] = l
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return loads, nil
+}
+
+// checkAssignableMembers makes sure every user is a non-viewer member of the
+// event.
+func checkAssignableMembers(ctx context.Context, q queryer, eventID string, userIDs []string) error {
+	query := sq.Select("COUNT(DISTINCT user_id)").
+		From(events.TableEventMemberships).
+		Where(sq.Eq{"event_id": eventID, "user_id": userIDs}).
+		Where(sq.NotEq{"role": "viewer"}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building membership check query: %w", err)
+	}
+	var count int
+	if err := q.QueryRowContext(ctx, sqlStr, args...).Scan(&count); err != nil {
+		return fmt.Errorf("error checking event membership: %w", err)
+	}
+	unique := map[string]bool{}
+	for _, id := range userIDs {
+		unique[id] = true
+	}
+	if count != len(unique) {
+		return ErrNotEventMember
+	}
+	return nil
+}
+
+func eventIDForItem(ctx context.Context, q queryer, itemID string) (string, error) {
+	query := sq.Select("c.event_id").
+		From(TableItems + " i").
+		Join(TableCategories + " c ON c.id = i.category_id").
+		Where(sq.Eq{"i.id": itemID}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return "", fmt.Errorf("error building item event query: %w", err)
+	}
+	var eventID string
+	if err := q.QueryRowContext(ctx, sqlStr, args...).Scan(&eventID); err != nil {
+		return "", fmt.Errorf("error fetching item event: %w", err)
+	}
+	return eventID, nil
+}
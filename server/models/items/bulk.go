@@ -0,0 +1,339 @@
+package items
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/sunnymotiani/PackTrack/server/models/activity"
+)
+
+// BULK OPERATIONS IMPLEMENTATION
+
+const (
+	BulkStatus   = "status"
+	BulkAssign   = "assign"
+	BulkUnassign = "unassign"
+	BulkMove     = "move"
+	BulkDelete   = "delete"
+	BulkEdit     = "edit"
+)
+
+const (
+	BulkResultOK      = "ok"
+	BulkResultFailed  = "failed"
+	BulkResultSkipped = "skipped"
+)
+
+var (
+	ErrBulkFailed      = errors.New("bulk operation failed, no changes were made")
+	ErrInvalidBulkOp   = errors.New("invalid bulk operation")
+	ErrItemNotInEvent  = errors.New("item does not belong to the event")
+	ErrCannotEditEvent = errors.New("viewers cannot change items")
+	ErrTooManyBulkOps  = errors.New("too many bulk operations")
+)
+
+// MaxBulkOperations caps how many operations one batch may hold.
+const MaxBulkOperations = 500
+
+// BulkOperation is one change in a batch. Op picks which fields are read:
+// status takes Status, assign takes Assignments (or UserID for the whole
+// item), move takes CategoryID and edit takes Updates like EditItem.
+type BulkOperation struct {
+	Op          string                 `json:"op"`
+	ItemID      string                 `json:"item_id"`
+	Status      string                 `json:"status,omitempty"`
+	UserID      string                 `json:"user_id,omitempty"`
+	Assignments []AssignmentInput      `json:"assignments,omitempty"`
+	CategoryID  string                 `json:"category_id,omitempty"`
+	Updates     map[string]interface{} `json:"updates,omitempty"`
+}
+
+// BulkResult reports what happened to one operation. Once an operation fails
+// the rest are skipped and nothing is saved.
+type BulkResult struct {
+	Index  int    `json:"index"`
+	Op     string `json:"op"`
+	ItemID string `json:"item_id"`
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// bulkItem is the state of an item when the batch first touched it.
+type bulkItem struct {
+	before    ItemProgress
+	assignees []string
+	assigned  bool
+	deleted   bool
+}
+
+// ApplyBulk runs operations on items of an event in one transaction, for
+// userID, who must be allowed to manage every item touched. Each changed item gets a single status history entry covering all
+// of its changes. When an operation fails, every result is returned with
+// ErrBulkFailed and the transaction is rolled back.
+func (is *ItemsService) ApplyBulk(ctx context.Context, eventID, userID string, ops []BulkOperation) ([]BulkResult, error) {
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("%w: no operations given", ErrInvalidBulkOp)
+	}
+	if len(ops) > MaxBulkOperations {
+		return nil, ErrTooManyBulkOps
+	}
+
+	tx, err := is.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := checkCanEditEvent(ctx, tx, eventID, userID); err != nil {
+		return nil, err
+	}
+
+	itemIDs := make([]string, 0, len(ops))
+	seen := map[string]bool{}
+	for _, op := range ops {
+		if !seen[op.ItemID] {
+			seen[op.ItemID] = true
+			itemIDs = append(itemIDs, op.ItemID)
+		}
+	}
+	if err := lockBulkItems(ctx, tx, itemIDs); err != nil {
+		return nil, err
+	}
+
+	results := make([]BulkResult, len(ops))
+	touched := map[string]*bulkItem{}
+	order := []string{}
+	failed := false
+	for i, op := range ops {
+		results[i] = BulkResult{Index: i, Op: op.Op, ItemID: op.ItemID, Result: BulkResultSkipped}
+		if failed {
+			continue
+		}
+		if err := is.applyBulkOp(ctx, tx, eventID, userID, op, touched, &order); err != nil {
+			results[i].Result, results[i].Error = BulkResultFailed, err.Error()
+			failed = true
+			continue
+		}
+		results[i].Result = BulkResultOK
+	}
+	if failed {
+		return results, ErrBulkFailed
+	}
+
+	changed := map[string]string{}
+	for _, itemID := range order {
+		item := touched[itemID]
+		if item.deleted {
+			continue
+		}
+		after, err := lockItemProgress(ctx, tx, itemID)
+		if err != nil {
+			return nil, err
+		}
+		b := item.before
+		if after.Status == b.Status && after.PackedQuantity == b.PackedQuantity &&
+			after.DeliveredQuantity == b.DeliveredQuantity {
+			continue
+		}
+		err = insertStatusHistory(ctx, tx, ItemStatusHistory{
+			ItemID:         itemID,
+			UserID:         &userID,
+			OldStatus:      b.Status,
+			NewStatus:      after.Status,
+			PackedDelta:    after.PackedQuantity - b.PackedQuantity,
+			DeliveredDelta: after.DeliveredQuantity - b.DeliveredQuantity,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if after.Status != b.Status {
+			changed[itemID] = after.Status
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	for _, itemID := range order {
+		item := touched[itemID]
+		if item.deleted {
+			continue
+		}
+		if status, ok := changed[itemID]; ok {
+			is.publish(ctx, activity.TypeStatusChange, itemID, userID, nil, "%s is now %s", status)
+		}
+		if item.assigned {
+			is.publish(ctx, activity.TypeAssignment, itemID, userID, is.withAssignees(ctx, itemID, item.assignees),
+				"Assignees of %s changed")
+		}
+	}
+	return results, nil
+}
+
+// lockBulkItems locks every item of a batch in id order before any of them
+// is changed, so that concurrent batches over the same items cannot deadlock.
+func lockBulkItems(ctx context.Context, tx *sql.Tx, itemIDs []string) error {
+	query := sq.Select("id").
+		From(TableItems).
+		Where(sq.Eq{"id": itemIDs}).
+		OrderBy("id ASC").
+		Suffix("FOR UPDATE").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building lock items query: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("error locking items: %w", err)
+	}
+	return nil
+}
+
+func (is *ItemsService) applyBulkOp(ctx context.Context, tx *sql.Tx, eventID, userID string, op BulkOperation,
+	touched map[string]*bulkItem, order *[]string) error {
+	item, ok := touched[op.ItemID]
+	if !ok {
+		itemEventID, err := eventIDForItem(ctx, tx, op.ItemID)
+		if errors.Is(err, sql.ErrNoRows) || err == nil && itemEventID != eventID {
+			return ErrItemNotInEvent
+		}
+		if err != nil {
+			return err
+		}
+		if err := checkCanManageItem(ctx, tx, op.ItemID, userID); err != nil {
+			return err
+		}
+		before, err := lockItemProgress(ctx, tx, op.ItemID)
+		if err != nil {
+			return err
+		}
+		assignments, err := queryAssignments(ctx, tx, sq.Eq{"item_id": op.ItemID})
+		if err != nil {
+			return err
+		}
+		item = &bulkItem{before: *before}
+		for _, a := range assignments {
+			item.assignees = append(item.assignees, a.UserID)
+		}
+		touched[op.ItemID] = item
+		*order = append(*order, op.ItemID)
+	}
+	if item.deleted {
+		return fmt.Errorf("%w: item was deleted earlier in the batch", ErrInvalidBulkOp)
+	}
+
+	switch op.Op {
+	case BulkStatus:
+		return bulkSetStatus(ctx, tx, op.ItemID, op.Status)
+	case BulkAssign:
+		assignments := op.Assignments
+		if len(assignments) == 0 {
+			if op.UserID == "" {
+				return fmt.Errorf("%w: assign needs user_id or assignments", ErrInvalidBulkOp)
+			}
+			current, err := lockItemProgress(ctx, tx, op.ItemID)
+			if err != nil {
+				return err
+			}
+			if assignments, err = wholeItem(current, op.UserID); err != nil {
+				return err
+			}
+		}
+		item.assigned = true
+		return setItemAssignments(ctx, tx, op.ItemID, assignments)
+	case BulkUnassign:
+		item.assigned = true
+		return setItemAssignments(ctx, tx, op.ItemID, nil)
+	case BulkMove:
+		if err := bulkMoveItem(ctx, tx, eventID, op.ItemID, op.CategoryID); err != nil {
+			return err
+		}
+		return checkCanManageCategory(ctx, tx, op.CategoryID, userID)
+	case BulkDelete:
+		query := sq.Delete(TableItems).Where(sq.Eq{"id": op.ItemID}).PlaceholderFormat(sq.Dollar)
+		sqlStr, args, err := query.ToSql()
+		if err != nil {
+			return fmt.Errorf("error generating delete item query: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, sqlStr, args...); err != nil {
+			return fmt.Errorf("error deleting item: %w", err)
+		}
+		item.deleted = true
+		return nil
+	case BulkEdit:
+		for _, key := range []string{"category_id", "position"} {
+			if _, ok := op.Updates[key]; ok {
+				return fmt.Errorf("%w: use a move operation to change %s", ErrInvalidBulkOp, key)
+			}
+		}
+		return editItem(ctx, tx, op.ItemID, op.Updates)
+	default:
+		return fmt.Errorf("%w: unknown op %q", ErrInvalidBulkOp, op.Op)
+	}
+}
+
+// bulkSetStatus is UpdateItemStatus without the history entry.
+func bulkSetStatus(ctx context.Context, tx *sql.Tx, itemID, status string) error {
+	current, err := lockItemProgress(ctx, tx, itemID)
+	if err != nil {
+		return err
+	}
+	packed, delivered := 0, 0
+	switch status {
+	case StatusToPack:
+	case StatusPacked:
+		packed = current.Quantity
+	case StatusDelivered:
+		packed, delivered = current.Quantity, current.Quantity
+	default:
+		return fmt.Errorf("%w: invalid status %q", ErrInvalidBulkOp, status)
+	}
+	if _, err := writeItemProgress(ctx, tx, current, packed, delivered, status); err != nil {
+		return err
+	}
+	return resetAssignmentProgress(ctx, tx, itemID, status)
+}
+
+// bulkMoveItem puts an item at the end of another category of the same
+// event.
+func bulkMoveItem(ctx context.Context, tx *sql.Tx, eventID, itemID, categoryID string) error {
+	if categoryID == "" {
+		return fmt.Errorf("%w: move needs category_id", ErrInvalidBulkOp)
+	}
+	query := sq.Update(TableItems).
+		Set("category_id", categoryID).
+		Set("position", nextPosition(TableItems, "category_id", categoryID)).
+		Where(sq.Eq{"id": itemID}).
+		Where(sq.Expr("EXISTS (SELECT 1 FROM "+TableCategories+" WHERE id = ? AND event_id = ?)", categoryID, eventID)).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building move item query: %w", err)
+	}
+	res, err := tx.ExecContext(ctx, sqlStr, args...)
+	if err != nil {
+		return fmt.Errorf("error moving item: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: category is not part of the event", ErrInvalidBulkOp)
+	}
+	return nil
+}
+
+// checkCanEditEvent makes sure userID is a member of the event with a role
+// allowed to change items.
+func checkCanEditEvent(ctx context.Context, q queryer, eventID, userID string) error {
+	role, err := eventRole(ctx, q, eventID, userID)
+	if err != nil {
+		return err
+	}
+	if role == "viewer" {
+		return ErrCannotEditEvent
+	}
+	return nil
+}
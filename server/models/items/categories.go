@@ -2,38 +2,72 @@ package items
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 
 	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgconn"
 )
 
 //CATEGORIES IMPLIMENTATION
 
 const TableCategories = "categories"
 
-func (is *ItemsService) CreateCategory(ctx context.Context, eventID string, name string) (*Category, error) {
+var (
+	ErrCategoryNotFound    = errors.New("category not found")
+	ErrCategoryNotEmpty    = errors.New("category still has items or subcategories, delete with cascade or move_to")
+	ErrInvalidDeleteOption = errors.New("give either cascade or move_to, not both")
+	ErrInvalidMerge        = errors.New("a category cannot be merged into itself or one of its subcategories")
+	ErrInvalidCategoryName = errors.New("category name must not be empty or contain \">\"")
+)
+
+// CreateCategory adds a category to an event, nested under parentID when it
+// is set.
+func (is *ItemsService) CreateCategory(ctx context.Context, eventID string, name string, parentID *string) (*Category, error) {
 	var category Category
-	query := sq.Insert(TableCategories).Columns("event_id", "name").Values(eventID, name).
-		Suffix("RETURNING id")
+	name = strings.TrimSpace(name)
+	if name == "" || strings.Contains(name, ">") {
+		return nil, ErrInvalidCategoryName
+	}
+	if parentID != nil {
+		if err := checkCategoryInEvent(ctx, is.DB, *parentID, eventID); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkSiblingName(ctx, is.DB, eventID, parentID, name, ""); err != nil {
+		return nil, err
+	}
+	query := sq.Insert(TableCategories).Columns("event_id", "parent_id", "name", "position").
+		Values(eventID, parentID, name, nextPosition(TableCategories, "event_id", eventID)).
+		Suffix("RETURNING id, position")
 	sql, args, err := query.PlaceholderFormat(sq.Dollar).ToSql()
 	if err != nil {
 		return nil, fmt.Errorf("create category sql error: %w", err)
 	}
 	row := is.DB.QueryRowContext(ctx, sql, args...)
-	err = row.Scan(&category.ID)
+	if err := row.Scan(&category.ID, &category.Position); err != nil {
+		if siblingNameTaken(err) {
+			return nil, ErrCategoryExists
+		}
+		return nil, fmt.Errorf("create category err scanning row : %w", err)
+	}
 	category.Name = name
 	category.EventID = eventID
+	category.ParentID = parentID
 	return &category, nil
 }
 
 func (is *ItemsService) GetCategories(ctx context.Context, eventID string) (*[]Category, error) {
 	var categories []Category
-	query := sq.Select("id", "name").From(TableCategories).Where(sq.Eq{"event_id": eventID})
+	query := sq.Select("id", "parent_id", "name", "position").From(TableCategories).Where(sq.Eq{"event_id": eventID}).
+		OrderBy("position ASC", "id ASC")
 	sql, args, err := query.PlaceholderFormat(sq.Dollar).ToSql()
 	if err != nil {
 		return nil, fmt.Errorf("get categories sql error: %w", err)
 	}
-	rows, err := is.DB.QueryContext(ctx, sql, args)
+	rows, err := is.DB.QueryContext(ctx, sql, args...)
 	if err != nil {
 		return nil, fmt.Errorf("get categories sql query error: %w", err)
 	}
@@ -41,7 +75,7 @@ func (is *ItemsService) GetCategories(ctx context.Context, eventID string) (*[]C
 	for rows.Next() {
 		var cat Category
 		cat.EventID = eventID
-		err := rows.Scan(&cat.ID, &cat.Name)
+		err := rows.Scan(&cat.ID, &cat.ParentID, &cat.Name, &cat.Position)
 		if err != nil {
 			return nil, fmt.Errorf("get categories err scanning rows : %w", err)
 		}
@@ -50,22 +84,316 @@ func (is *ItemsService) GetCategories(ctx context.Context, eventID string) (*[]C
 	return &categories, nil
 }
 
-func (is *ItemsService) DeleteCategory(ctx context.Context, id string) error {
+// DeleteCategoryOptions says what happens to the contents of a deleted
+// category: Cascade drops its items and subcategories, MoveTo merges them
+// into another category first. A category with contents is only deleted
+// with one of them.
+type DeleteCategoryOptions struct {
+	Cascade bool   `json:"cascade"`
+	MoveTo  string `json:"move_to,omitempty"`
+}
+
+// DeleteCategory removes a category. It refuses with ErrCategoryNotEmpty when
+// the category still holds items or subcategories and opts does not say what
+// to do with them.
+func (is *ItemsService) DeleteCategory(ctx context.Context, id string, opts DeleteCategoryOptions) error {
+	if opts.Cascade && opts.MoveTo != "" {
+		return ErrInvalidDeleteOption
+	}
+	if opts.MoveTo != "" {
+		_, err := is.MergeCategories(ctx, id, opts.MoveTo)
+		return err
+	}
+
+	tx, err := is.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := lockCategory(ctx, tx, id); err != nil {
+		return err
+	}
+	if !opts.Cascade {
+		contents := fmt.Sprintf(`WITH RECURSIVE subtree AS (
+				SELECT id FROM %[1]s WHERE id = $1
+				UNION
+				SELECT c.id FROM %[1]s c JOIN subtree s ON c.parent_id = s.id
+			)
+			SELECT (SELECT COUNT(*) FROM subtree) - 1 + (SELECT COUNT(*) FROM %[2]s WHERE category_id IN (SELECT id FROM subtree))`,
+			TableCategories, TableItems)
+		var count int
+		if err := tx.QueryRowContext(ctx, contents, id).Scan(&count); err != nil {
+			return fmt.Errorf("error counting category contents: %w", err)
+		}
+		if count > 0 {
+			return ErrCategoryNotEmpty
+		}
+	}
+
 	query := sq.Delete(TableCategories).Where(sq.Eq{"id": id})
-	sql, args, err := query.PlaceholderFormat(sq.Dollar).ToSql()
+	sqlStr, args, err := query.PlaceholderFormat(sq.Dollar).ToSql()
 	if err != nil {
 		return fmt.Errorf("err generating delete category row : %w", err)
 	}
-	_, err = is.DB.ExecContext(ctx, sql, args...)
-	return err
+	if _, err := tx.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("error deleting category: %w", err)
+	}
+	return tx.Commit()
 }
 
+// UpdateCategoryName renames a category, refusing with ErrCategoryExists when
+// a sibling already has the name.
 func (is *ItemsService) UpdateCategoryName(ctx context.Context, id string, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" || strings.Contains(name, ">") {
+		return ErrInvalidCategoryName
+	}
+
+	tx, err := is.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	category, err := lockCategory(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if err := checkSiblingName(ctx, tx, category.EventID, category.ParentID, name, id); err != nil {
+		return err
+	}
+
 	query := sq.Update(TableCategories).Set("name", name).Where(sq.Eq{"id": id})
-	sql, args, err := query.PlaceholderFormat(sq.Dollar).ToSql()
+	sqlStr, args, err := query.PlaceholderFormat(sq.Dollar).ToSql()
 	if err != nil {
 		return fmt.Errorf("err generating update category name row : %w", err)
 	}
-	_, err = is.DB.ExecContext(ctx, sql, args...)
-	return err
+	if _, err := tx.ExecContext(ctx, sqlStr, args...); err != nil {
+		if siblingNameTaken(err) {
+			return ErrCategoryExists
+		}
+		return fmt.Errorf("error renaming category: %w", err)
+	}
+	return tx.Commit()
+}
+
+// MergeCategories moves the items and subcategories of source into target,
+// another category of the same event, and deletes source. Subcategories that
+// share a name with one of target's are merged the same way. It returns how
+// many items were moved.
+func (is *ItemsService) MergeCategories(ctx context.Context, sourceID, targetID string) (int, error) {
+	if sourceID == targetID {
+		return 0, ErrInvalidMerge
+	}
+
+	tx, err := is.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	source, err := lockCategory(ctx, tx, sourceID)
+	if err != nil {
+		return 0, err
+	}
+	target, err := lockCategory(ctx, tx, targetID)
+	if err != nil {
+		return 0, err
+	}
+	if source.EventID != target.EventID {
+		return 0, ErrCategoryNotInEvent
+	}
+	if err := checkNotDescendant(ctx, tx, sourceID, targetID); errors.Is(err, ErrCategoryCycle) {
+		return 0, ErrInvalidMerge
+	} else if err != nil {
+		return 0, err
+	}
+
+	moved, err := mergeCategory(ctx, tx, sourceID, targetID)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return moved, nil
+}
+
+func mergeCategory(ctx context.Context, tx *sql.Tx, sourceID, targetID string) (int, error) {
+	children, err := childCategories(ctx, tx, sourceID)
+	if err != nil {
+		return 0, err
+	}
+	targetChildren, err := childCategories(ctx, tx, targetID)
+	if err != nil {
+		return 0, err
+	}
+	byName := map[string]string{}
+	for _, c := range targetChildren {
+		byName[c.Name] = c.ID
+	}
+
+	moved := 0
+	for _, c := range children {
+		if clash, ok := byName[c.Name]; ok {
+			n, err := mergeCategory(ctx, tx, c.ID, clash)
+			if err != nil {
+				return 0, err
+			}
+			moved += n
+			continue
+		}
+		query := sq.Update(TableCategories).Set("parent_id", targetID).Where(sq.Eq{"id": c.ID})
+		sqlStr, args, err := query.PlaceholderFormat(sq.Dollar).ToSql()
+		if err != nil {
+			return 0, fmt.Errorf("error building reparent category query: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, sqlStr, args...); err != nil {
+			return 0, fmt.Errorf("error moving subcategory: %w", err)
+		}
+	}
+
+	// Moved items go after target's own items, in their current order.
+	moveItems := fmt.Sprintf(`UPDATE %[1]s i SET category_id = $2, position = base.p + o.n * %[2]d
+		FROM (SELECT id, ROW_NUMBER() OVER (ORDER BY position, id) AS n FROM %[1]s WHERE category_id = $1) o,
+			(SELECT COALESCE(MAX(position), 0) AS p FROM %[1]s WHERE category_id = $2) base
+		WHERE i.id = o.id`, TableItems, PositionGap)
+	res, err := tx.ExecContext(ctx, moveItems, sourceID, targetID)
+	if err != nil {
+		return 0, fmt.Errorf("error moving items: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	moved += int(n)
+
+	query := sq.Delete(TableCategories).Where(sq.Eq{"id": sourceID})
+	sqlStr, args, err := query.PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("err generating delete category row : %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, sqlStr, args...); err != nil {
+		return 0, fmt.Errorf("error deleting merged category: %w", err)
+	}
+	return moved, nil
+}
+
+// lockCategory reads a category and locks its row until the transaction
+// ends.
+func lockCategory(ctx context.Context, tx *sql.Tx, id string) (*Category, error) {
+	query := sq.Select("id", "event_id", "parent_id", "name", "position").
+		From(TableCategories).
+		Where(sq.Eq{"id": id}).
+		Suffix("FOR UPDATE").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building select category query: %w", err)
+	}
+	c := &Category{}
+	err = tx.QueryRowContext(ctx, sqlStr, args...).Scan(&c.ID, &c.EventID, &c.ParentID, &c.Name, &c.Position)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCategoryNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching category: %w", err)
+	}
+	return c, nil
+}
+
+func childCategories(ctx context.Context, tx *sql.Tx, parentID string) ([]Category, error) {
+	query := sq.Select("id", "name").
+		From(TableCategories).
+		Where(sq.Eq{"parent_id": parentID}).
+		OrderBy("position ASC", "id ASC").
+		Suffix("FOR UPDATE").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building select subcategories query: %w", err)
+	}
+	rows, err := tx.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying subcategories: %w", err)
+	}
+	defer rows.Close()
+	var children []Category
+	for rows.Next() {
+		var c Category
+		if err := rows.Scan(&c.ID, &c.Name); err != nil {
+			return nil, fmt.Errorf("error scanning subcategory row: %w", err)
+		}
+		children = append(children, c)
+	}
+	return children, rows.Err()
+}
+
+// siblingNameTaken reports whether err is the unique violation raised when
+// a concurrent write gave a sibling the same name after checkSiblingName.
+func siblingNameTaken(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "categories_sibling_name_idx"
+}
+
+// checkSiblingName returns ErrCategoryExists when a category under the same
+// parent, other than excludeID when set, is already called name.
+func checkSiblingName(ctx context.Context, q queryer, eventID string, parentID *string, name, excludeID string) error {
+	query := sq.Select("1").
+		From(TableCategories).
+		Where(sq.Eq{"event_id": eventID, "parent_id": parentID, "name": name}).
+		PlaceholderFormat(sq.Dollar)
+	if excludeID != "" {
+		query = query.Where(sq.NotEq{"id": excludeID})
+	}
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building sibling name query: %w", err)
+	}
+	var one int
+	err = q.QueryRowContext(ctx, sqlStr, args...).Scan(&one)
+	if err == nil {
+		return ErrCategoryExists
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("error checking sibling names: %w", err)
+	}
+	return nil
+}
+
+// ensureCategory returns the ID of the event's category at path, creating
+// any missing level. A path is a single name or names separated by ">", like
+// "Kitchen > Cooking > Utensils".
+func ensureCategory(ctx context.Context, tx *sql.Tx, eventID, path string) (string, error) {
+	names := SplitCategoryPath(path)
+	if len(names) == 0 {
+		return "", fmt.Errorf("ensure category: empty category path %q", path)
+	}
+	var parentID *string
+	for _, name := range names {
+		insert := sq.Insert(TableCategories).Columns("event_id", "parent_id", "name", "position").
+			Values(eventID, parentID, name, nextPosition(TableCategories, "event_id", eventID)).
+			Suffix("ON CONFLICT DO NOTHING")
+		sqlStr, args, err := insert.PlaceholderFormat(sq.Dollar).ToSql()
+		if err != nil {
+			return "", fmt.Errorf("ensure category sql error: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, sqlStr, args...); err != nil {
+			return "", fmt.Errorf("ensure category %q: %w", name, err)
+		}
+
+		query := sq.Select("id").From(TableCategories).
+			Where(sq.Eq{"event_id": eventID, "parent_id": parentID, "name": name})
+		sqlStr, args, err = query.PlaceholderFormat(sq.Dollar).ToSql()
+		if err != nil {
+			return "", fmt.Errorf("ensure category sql error: %w", err)
+		}
+		var id string
+		if err := tx.QueryRowContext(ctx, sqlStr, args...).Scan(&id); err != nil {
+			return "", fmt.Errorf("ensure category %q: %w", name, err)
+		}
+		parentID = &id
+	}
+	return *parentID, nil
 }
@@ -0,0 +1,423 @@
+package items
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/sunnymotiani/PackTrack/server/models/activity"
+	"github.com/sunnymotiani/PackTrack/server/models/events"
+)
+
+// CLAIMS IMPLEMENTATION
+
+const TableItemSwapRequests = "item_swap_requests"
+
+const (
+	SwapPending   = "pending"
+	SwapAccepted  = "accepted"
+	SwapDeclined  = "declined"
+	SwapCancelled = "cancelled"
+)
+
+var (
+	ErrItemAlreadyClaimed = errors.New("item is already assigned")
+	ErrNotAssignee        = errors.New("user is not assigned to the item")
+	ErrCannotClaim        = errors.New("viewers cannot claim items")
+	ErrSwapNotPending     = errors.New("swap request is no longer pending")
+	ErrSwapForbidden      = errors.New("user cannot act on this swap request")
+	ErrSwapNotFound       = errors.New("swap request not found")
+)
+
+type SwapRequest struct {
+	ID            string     `json:"id"`
+	ItemID        string     `json:"item_id"`
+	RequesterID   string     `json:"requester_id"`
+	TargetID      string     `json:"target_id"`
+	CounterItemID *string    `json:"counter_item_id,omitempty"`
+	Status        string     `json:"status"`
+	CreatedAt     time.Time  `json:"created_at"`
+	ResolvedAt    *time.Time `json:"resolved_at,omitempty"`
+}
+
+// ClaimItem assigns an unassigned item to userID. The item row is locked
+// while the claim is checked, so when two members claim the same item at
+// once the second one gets ErrItemAlreadyClaimed.
+func (is *ItemsService) ClaimItem(ctx context.Context, itemID, userID string) error {
+	tx, err := is.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := lockItemProgress(ctx, tx, itemID)
+	if err != nil {
+		return err
+	}
+	if err := checkCanHoldItems(ctx, tx, itemID, userID); err != nil {
+		return err
+	}
+	existing, err := queryAssignments(ctx, tx, sq.Eq{"item_id": itemID})
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 {
+		return ErrItemAlreadyClaimed
+	}
+
+	assignments, err := wholeItem(current, userID)
+	if err != nil {
+		return err
+	}
+	if err := setItemAssignments(ctx, tx, itemID, assignments); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	is.publish(ctx, activity.TypeAssignment, itemID, userID, nil, "%s was claimed")
+	return nil
+}
+
+// ReleaseItem drops userID's share of an item; other assignees keep theirs.
+func (is *ItemsService) ReleaseItem(ctx context.Context, itemID, userID string) error {
+	tx, err := is.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := lockItemProgress(ctx, tx, itemID); err != nil {
+		return err
+	}
+
+	query := sq.Delete(TableItemAssignments).
+		Where(sq.Eq{"item_id": itemID, "user_id": userID}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building release query: %w", err)
+	}
+	res, err := tx.ExecContext(ctx, sqlStr, args...)
+	if err != nil {
+		return fmt.Errorf("error releasing item: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotAssignee
+	}
+	if err := syncAssignedTo(ctx, tx, itemID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	is.publish(ctx, activity.TypeAssignment, itemID, userID, is.withAssignees(ctx, itemID, []string{userID}), "%s was released")
+	return nil
+}
+
+// RequestSwap asks targetID to take over requesterID's share of an item. When
+// counterItemID is set the requester takes over the target's share of that
+// item in exchange.
+func (is *ItemsService) RequestSwap(ctx context.Context, itemID, requesterID, targetID string, counterItemID *string) (*SwapRequest, error) {
+	if requesterID == targetID {
+		return nil, ErrSwapForbidden
+	}
+	if err := checkIsAssignee(ctx, is.DB, itemID, requesterID); err != nil {
+		return nil, err
+	}
+	if err := checkCanHoldItems(ctx, is.DB, itemID, targetID); err != nil {
+		return nil, err
+	}
+	if counterItemID != nil {
+		if err := checkIsAssignee(ctx, is.DB, *counterItemID, targetID); err != nil {
+			return nil, err
+		}
+		if err := checkCanHoldItems(ctx, is.DB, *counterItemID, requesterID); err != nil {
+			return nil, err
+		}
+	}
+
+	swap := &SwapRequest{
+		ID:            uuid.NewString(),
+		ItemID:        itemID,
+		RequesterID:   requesterID,
+		TargetID:      targetID,
+		CounterItemID: counterItemID,
+		Status:        SwapPending,
+		CreatedAt:     time.Now(),
+	}
+	query := sq.Insert(TableItemSwapRequests).
+		Columns("id", "item_id", "requester_id", "target_id", "counter_item_id", "status", "created_at").
+		Values(swap.ID, swap.ItemID, swap.RequesterID, swap.TargetID, swap.CounterItemID, swap.Status, swap.CreatedAt).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building swap request insert: %w", err)
+	}
+	if _, err := is.DB.ExecContext(ctx, sqlStr, args...); err != nil {
+		return nil, fmt.Errorf("error inserting swap request: %w", err)
+	}
+	return swap, nil
+}
+
+// RespondToSwap lets the target of a swap request accept or decline it. On
+// accept the shares change hands in one transaction, provided both members
+// still hold them.
+func (is *ItemsService) RespondToSwap(ctx context.Context, swapID, userID string, accept bool) (*SwapRequest, error) {
+	tx, err := is.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	swap, err := lockSwapRequest(ctx, tx, swapID)
+	if err != nil {
+		return nil, err
+	}
+	if swap.TargetID != userID {
+		return nil, ErrSwapForbidden
+	}
+
+	status := SwapDeclined
+	if accept {
+		status = SwapAccepted
+		itemIDs := []string{swap.ItemID}
+		if swap.CounterItemID != nil {
+			itemIDs = append(itemIDs, *swap.CounterItemID)
+		}
+		// Lock in a fixed order so two crossing swaps cannot deadlock.
+		sort.Strings(itemIDs)
+		for _, id := range itemIDs {
+			if _, err := lockItemProgress(ctx, tx, id); err != nil {
+				return nil, err
+			}
+		}
+		if err := transferAssignment(ctx, tx, swap.ItemID, swap.RequesterID, swap.TargetID); err != nil {
+			return nil, err
+		}
+		if swap.CounterItemID != nil {
+			if err := transferAssignment(ctx, tx, *swap.CounterItemID, swap.TargetID, swap.RequesterID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := resolveSwapRequest(ctx, tx, swap, status); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	recipients := []string{swap.RequesterID, swap.TargetID}
+	is.publish(ctx, activity.TypeAssignment, swap.ItemID, userID, recipients, "Swap of %s was %s", status)
+	return swap, nil
+}
+
+// CancelSwap withdraws a pending swap request; only the requester may do so.
+func (is *ItemsService) CancelSwap(ctx context.Context, swapID, userID string) error {
+	tx, err := is.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	swap, err := lockSwapRequest(ctx, tx, swapID)
+	if err != nil {
+		return err
+	}
+	if swap.RequesterID != userID {
+		return ErrSwapForbidden
+	}
+	if err := resolveSwapRequest(ctx, tx, swap, SwapCancelled); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetPendingSwapsForUser returns the pending swap requests a user sent or
+// received.
+func (is *ItemsService) GetPendingSwapsForUser(ctx context.Context, userID string) ([]SwapRequest, error) {
+	query := sq.Select("id", "item_id", "requester_id", "target_id", "counter_item_id", "status", "created_at", "resolved_at").
+		From(TableItemSwapRequests).
+		Where(sq.Eq{"status": SwapPending}).
+		Where(sq.Or{sq.Eq{"requester_id": userID}, sq.Eq{"target_id": userID}}).
+		OrderBy("created_at ASC").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building select swaps query: %w", err)
+	}
+	rows, err := is.DB.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying swaps: %w", err)
+	}
+	defer rows.Close()
+
+	var swaps []SwapRequest
+	for rows.Next() {
+		var s SwapRequest
+		if err := rows.Scan(&s.ID, &s.ItemID, &s.RequesterID, &s.TargetID, &s.CounterItemID,
+			&s.Status, &s.CreatedAt, &s.ResolvedAt); err != nil {
+			return nil, fmt.Errorf("error scanning swap row: %w", err)
+		}
+		swaps = append(swaps, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return swaps, nil
+}
+
+func lockSwapRequest(ctx context.Context, tx *sql.Tx, swapID string) (*SwapRequest, error) {
+	query := sq.Select("id", "item_id", "requester_id", "target_id", "counter_item_id", "status", "created_at").
+		From(TableItemSwapRequests).
+		Where(sq.Eq{"id": swapID}).
+		Suffix("FOR UPDATE").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building select swap query: %w", err)
+	}
+	var s SwapRequest
+	err = tx.QueryRowContext(ctx, sqlStr, args...).
+		Scan(&s.ID, &s.ItemID, &s.RequesterID, &s.TargetID, &s.CounterItemID, &s.Status, &s.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSwapNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching swap request: %w", err)
+	}
+	if s.Status != SwapPending {
+		return nil, ErrSwapNotPending
+	}
+	return &s, nil
+}
+
+func resolveSwapRequest(ctx context.Context, tx *sql.Tx, swap *SwapRequest, status string) error {
+	now := time.Now()
+	query := sq.Update(TableItemSwapRequests).
+		Set("status", status).
+		Set("resolved_at", now).
+		Where(sq.Eq{"id": swap.ID}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building resolve swap query: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("error resolving swap request: %w", err)
+	}
+	swap.Status = status
+	swap.ResolvedAt = &now
+	return nil
+}
+
+// transferAssignment hands fromUserID's share of a locked item, progress
+// included, to toUserID, merging it into toUserID's share if they already
+// have one.
+func transferAssignment(ctx context.Context, tx *sql.Tx, itemID, fromUserID, toUserID string) error {
+	merge := sq.Update(TableItemAssignments+" t").
+		Set("quantity", sq.Expr("t.quantity + f.quantity")).
+		Set("packed_quantity", sq.Expr("t.packed_quantity + f.packed_quantity")).
+		Set("delivered_quantity", sq.Expr("t.delivered_quantity + f.delivered_quantity")).
+		Set("status", sq.Expr(`CASE
+			WHEN t.delivered_quantity + f.delivered_quantity >= t.quantity + f.quantity THEN ?
+			WHEN t.packed_quantity + f.packed_quantity >= t.quantity + f.quantity THEN ?
+			ELSE ? END`, StatusDelivered, StatusPacked, StatusToPack)).
+		From(TableItemAssignments + " f").
+		Where("t.item_id = f.item_id").
+		Where(sq.Eq{"t.item_id": itemID, "t.user_id": toUserID, "f.user_id": fromUserID}).
+		PlaceholderFormat(sq.Dollar)
+
+	mergeSQL, mergeArgs, err := merge.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building merge assignment query: %w", err)
+	}
+	res, err := tx.ExecContext(ctx, mergeSQL, mergeArgs...)
+	if err != nil {
+		return fmt.Errorf("error merging assignment: %w", err)
+	}
+
+	var move sq.Sqlizer
+	if n, _ := res.RowsAffected(); n > 0 {
+		move = sq.Delete(TableItemAssignments).
+			Where(sq.Eq{"item_id": itemID, "user_id": fromUserID}).
+			PlaceholderFormat(sq.Dollar)
+	} else {
+		move = sq.Update(TableItemAssignments).
+			Set("user_id", toUserID).
+			Where(sq.Eq{"item_id": itemID, "user_id": fromUserID}).
+			PlaceholderFormat(sq.Dollar)
+	}
+	moveSQL, moveArgs, err := move.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building transfer assignment query: %w", err)
+	}
+	res, err = tx.ExecContext(ctx, moveSQL, moveArgs...)
+	if err != nil {
+		return fmt.Errorf("error transferring assignment: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotAssignee
+	}
+	return syncAssignedTo(ctx, tx, itemID)
+}
+
+func checkIsAssignee(ctx context.Context, q queryer, itemID, userID string) error {
+	assignments, err := queryAssignments(ctx, q, sq.Eq{"item_id": itemID, "user_id": userID})
+	if err != nil {
+		return err
+	}
+	if len(assignments) == 0 {
+		return ErrNotAssignee
+	}
+	return nil
+}
+
+// checkCanHoldItems makes sure userID is a member of the item's event with
+// a role allowed to bring items.
+func checkCanHoldItems(ctx context.Context, q queryer, itemID, userID string) error {
+	role, err := memberRole(ctx, q, itemID, userID)
+	if err != nil {
+		return err
+	}
+	if role == "viewer" {
+		return ErrCannotClaim
+	}
+	return nil
+}
+
+// memberRole returns the role userID has in the event the item belongs to,
+// or ErrNotEventMember.
+func memberRole(ctx context.Context, q queryer, itemID, userID string) (string, error) {
+	query := sq.Select("em.role").
+		From(TableItems + " i").
+		Join(TableCategories + " c ON c.id = i.category_id").
+		Join(events.TableEventMemberships + " em ON em.event_id = c.event_id").
+		Where(sq.Eq{"i.id": itemID, "em.user_id": userID}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return "", fmt.Errorf("error building member role query: %w", err)
+	}
+	var role string
+	err = q.QueryRowContext(ctx, sqlStr, args...).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotEventMember
+	}
+	if err != nil {
+		return "", fmt.Errorf("error fetching member role: %w", err)
+	}
+	return role, nil
+}
@@ -32,7 +32,8 @@ func (is *ItemsService) LogStatusChange(ctx context.Context, itemID, userID, old
 }
 
 func (is *ItemsService) GetItemHistory(ctx context.Context, itemID string) ([]ItemStatusHistory, error) {
-	query := sq.Select("id", "item_id", "user_id", "old_status", "new_status", "changed_at").
+	query := sq.Select("id", "item_id", "user_id", "old_status", "new_status", "packed_delta", "delivered_delta",
+		"returned_delta", "missing_delta", "damaged_delta", "notes", "changed_at").
 		From(TableItemStatusHistory).
 		Where(sq.Eq{"item_id": itemID}).
 		OrderBy("changed_at ASC").
@@ -61,6 +62,12 @@ func (is *ItemsService) GetItemHistory(ctx context.Context, itemID string) ([]It
 			&userID,
 			&record.OldStatus,
 			&record.NewStatus,
+			&record.PackedDelta,
+			&record.DeliveredDelta,
+			&record.ReturnedDelta,
+			&record.MissingDelta,
+			&record.DamagedDelta,
+			&record.Notes,
 			&record.ChangedAt,
 		); err != nil {
 			return nil, fmt.Errorf("error scanning history row: %w", err)
@@ -3,50 +3,191 @@ package items
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"math"
+	"sort"
+	"strings"
 	"time"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/google/uuid"
+	"github.com/sunnymotiani/PackTrack/server/models/activity"
 )
 
 type ItemsService struct {
 	DB *sql.DB
+	// Activity, when set, is told about assignment and status changes.
+	Activity activity.Publisher
 }
 
 type Category struct {
-	ID      string `json:"id" db:"id"`
-	EventID string `json:"event_id" db:"event_id"`
-	Name    string `json:"name" db:"name"`
+	ID       string  `json:"id" db:"id"`
+	EventID  string  `json:"event_id" db:"event_id"`
+	ParentID *string `json:"parent_id,omitempty" db:"parent_id"`
+	Name     string  `json:"name" db:"name"`
+	Position float64 `json:"position" db:"position"`
 }
 
 type Item struct {
-	ID         string    `json:"id" db:"id"`
-	CategoryID string    `json:"category_id" db:"category_id"`
-	Name       string    `json:"name" db:"name"`
-	Quantity   int       `json:"quantity" db:"quantity"`
-	AssignedTo *string   `json:"assigned_to,omitempty" db:"assigned_to"`
-	Status     string    `json:"status" db:"status"`
-	Notes      *string   `json:"notes,omitempty" db:"notes"`
-	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	ID                string       `json:"id" db:"id"`
+	CategoryID        string       `json:"category_id" db:"category_id"`
+	Name              string       `json:"name" db:"name"`
+	Quantity          int          `json:"quantity" db:"quantity"`
+	PackedQuantity    int          `json:"packed_quantity" db:"packed_quantity"`
+	DeliveredQuantity int          `json:"delivered_quantity" db:"delivered_quantity"`
+	ReturnedQuantity  int          `json:"returned_quantity" db:"returned_quantity"`
+	MissingQuantity   int          `json:"missing_quantity" db:"missing_quantity"`
+	DamagedQuantity   int          `json:"damaged_quantity" db:"damaged_quantity"`
+	ReturnNotes       *string      `json:"return_notes,omitempty" db:"return_notes"`
+	AssignedTo        *string      `json:"assigned_to,omitempty" db:"assigned_to"`
+	Status            string       `json:"status" db:"status"`
+	Notes             *string      `json:"notes,omitempty" db:"notes"`
+	WeightG           *float64     `json:"weight_g,omitempty" db:"weight_g"`
+	VolumeML          *float64     `json:"volume_ml,omitempty" db:"volume_ml"`
+	WeightUnit        string       `json:"weight_unit,omitempty" db:"weight_unit"`
+	VolumeUnit        string       `json:"volume_unit,omitempty" db:"volume_unit"`
+	Weight            *Measure     `json:"weight,omitempty"`
+	Volume            *Measure     `json:"volume,omitempty"`
+	ContainerID       *string      `json:"container_id,omitempty" db:"container_id"`
+	GearID            *string      `json:"gear_id,omitempty" db:"gear_id"`
+	DueAt             *time.Time   `json:"due_at,omitempty" db:"due_at"`
+	Position          float64      `json:"position" db:"position"`
+	CreatedAt         time.Time    `json:"created_at" db:"created_at"`
+	Assignments       []Assignment `json:"assignments,omitempty"`
 }
 type ItemStatusHistory struct {
-	ID        string    `json:"id" db:"id"`
-	ItemID    string    `json:"item_id" db:"item_id"`
-	UserID    *string   `json:"user_id,omitempty" db:"user_id"`
-	OldStatus string    `json:"old_status" db:"old_status"`
-	NewStatus string    `json:"new_status" db:"new_status"`
-	ChangedAt time.Time `json:"changed_at" db:"changed_at"`
+	ID             string    `json:"id" db:"id"`
+	ItemID         string    `json:"item_id" db:"item_id"`
+	UserID         *string   `json:"user_id,omitempty" db:"user_id"`
+	OldStatus      string    `json:"old_status" db:"old_status"`
+	NewStatus      string    `json:"new_status" db:"new_status"`
+	PackedDelta    int       `json:"packed_delta" db:"packed_delta"`
+	DeliveredDelta int       `json:"delivered_delta" db:"delivered_delta"`
+	ReturnedDelta  int       `json:"returned_delta" db:"returned_delta"`
+	MissingDelta   int       `json:"missing_delta" db:"missing_delta"`
+	DamagedDelta   int       `json:"damaged_delta" db:"damaged_delta"`
+	Notes          *string   `json:"notes,omitempty" db:"notes"`
+	ChangedAt      time.Time `json:"changed_at" db:"changed_at"`
 }
 
+var (
+	ErrInvalidEdit           = errors.New("invalid item update")
+	ErrQuantityBelowProgress = errors.New("quantity cannot be less than the packed or assigned quantity")
+)
+
 const TableItems = "items"
 const TableItemStatusHistory = "item_status_history"
 
+const (
+	StatusToPack    = "to_pack"
+	StatusPacked    = "packed"
+	StatusDelivered = "delivered"
+	StatusReturned  = "returned"
+	StatusMissing   = "missing"
+	StatusDamaged   = "damaged"
+)
+
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// AddItem inserts a new item. An item created with AssignedTo gets a single
+// assignment covering its whole quantity.
 func (is *ItemsService) AddItem(item *Item) error {
+	ctx := context.Background()
+	tx, err := is.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertItem(ctx, tx, item); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ItemDraft is an item to create in the category with the given name.
+type ItemDraft struct {
+	CategoryName string
+	Item         Item
+}
+
+// AddItemsToEvent creates several items in one transaction, creating any
+// category that does not exist yet. Either every item is added or none is.
+func (is *ItemsService) AddItemsToEvent(ctx context.Context, eventID string, drafts []ItemDraft) ([]Item, error) {
+	tx, err := is.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	created, err := AddItemsToEventTx(ctx, tx, eventID, drafts)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// AddItemsToEventTx is AddItemsToEvent inside the caller's transaction, for
+// callers that record something alongside the items.
+func AddItemsToEventTx(ctx context.Context, tx *sql.Tx, eventID string, drafts []ItemDraft) ([]Item, error) {
+	var err error
+	categoryIDs := map[string]string{}
+	created := make([]Item, 0, len(drafts))
+	for _, d := range drafts {
+		categoryID, ok := categoryIDs[d.CategoryName]
+		if !ok {
+			categoryID, err = ensureCategory(ctx, tx, eventID, d.CategoryName)
+			if err != nil {
+				return nil, err
+			}
+			categoryIDs[d.CategoryName] = categoryID
+		}
+		item := d.Item
+		item.CategoryID = categoryID
+		if err := insertItem(ctx, tx, &item); err != nil {
+			return nil, err
+		}
+		created = append(created, item)
+	}
+	return created, nil
+}
+
+func insertItem(ctx context.Context, tx *sql.Tx, item *Item) error {
 	item.ID = uuid.NewString()
+	if item.Quantity <= 0 {
+		item.Quantity = 1
+	}
+	if item.Status == "" {
+		item.Status = StatusToPack
+	}
+	switch item.Status {
+	case StatusToPack:
+		item.PackedQuantity, item.DeliveredQuantity = 0, 0
+	case StatusPacked:
+		item.PackedQuantity, item.DeliveredQuantity = item.Quantity, 0
+	case StatusDelivered:
+		item.PackedQuantity, item.DeliveredQuantity = item.Quantity, item.Quantity
+	default:
+		return fmt.Errorf("invalid status: %s", item.Status)
+	}
+	if err := item.applyMeasures(); err != nil {
+		return err
+	}
 	query := sq.Insert(TableItems).
-		Columns("id", "category_id", "name", "quantity", "assigned_to", "status", "notes").
-		Values(item.ID, item.CategoryID, item.Name, item.Quantity, item.AssignedTo, item.Status, item.Notes).
+		Columns("id", "category_id", "name", "quantity", "packed_quantity", "delivered_quantity", "status", "notes",
+			"weight_g", "volume_ml", "weight_unit", "volume_unit", "due_at", "position").
+		Values(item.ID, item.CategoryID, item.Name, item.Quantity, item.PackedQuantity, item.DeliveredQuantity,
+			item.Status, item.Notes, item.WeightG, item.VolumeML, item.WeightUnit, item.VolumeUnit, item.DueAt,
+			nextPosition(TableItems, "category_id", item.CategoryID)).
 		PlaceholderFormat(sq.Dollar)
 
 	sqlStr, args, err := query.ToSql()
@@ -55,15 +196,28 @@ func (is *ItemsService) AddItem(item *Item) error {
 
 	}
 
-	_, err = is.DB.Exec(sqlStr, args...)
-	return fmt.Errorf("err scanning category name row : %w", err)
-
+	if _, err = tx.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("err inserting item row : %w", err)
+	}
+	if item.AssignedTo != nil {
+		err = setItemAssignments(ctx, tx, item.ID, []AssignmentInput{{UserID: *item.AssignedTo, Quantity: item.Quantity}})
+		if err != nil {
+			return err
+		}
+		if err := resetAssignmentProgress(ctx, tx, item.ID, item.Status); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (is *ItemsService) GetItemByCategory(ctx context.Context, catID string) (*[]Item, error) {
 	var items []Item
-	query := sq.Select("id", "name", "quantity", "assigned_to", "status", "notes", "created_at").
-		From(TableItems).Where(sq.Eq{"category_id": catID})
+	query := sq.Select("id", "name", "quantity", "packed_quantity", "delivered_quantity",
+		"returned_quantity", "missing_quantity", "damaged_quantity", "return_notes", "assigned_to", "status", "notes",
+		"weight_g", "volume_ml", "weight_unit", "volume_unit", "container_id", "gear_id", "due_at", "position", "created_at").
+		From(TableItems).Where(sq.Eq{"category_id": catID}).
+		OrderBy("position ASC", "id ASC")
 	sql, args, err := query.PlaceholderFormat(sq.Dollar).ToSql()
 	if err != nil {
 		return nil, fmt.Errorf("err generating item by cat row : %w", err)
@@ -75,102 +229,247 @@ func (is *ItemsService) GetItemByCategory(ctx context.Context, catID string) (*[
 	defer rows.Close()
 	for rows.Next() {
 		var itm Item
-		err := rows.Scan(&itm.ID, &itm.Name, &itm.Quantity, &itm.AssignedTo, &itm.Status, &itm.Notes, &itm.CreatedAt)
+		err := rows.Scan(&itm.ID, &itm.Name, &itm.Quantity, &itm.PackedQuantity, &itm.DeliveredQuantity,
+			&itm.ReturnedQuantity, &itm.MissingQuantity, &itm.DamagedQuantity, &itm.ReturnNotes, &itm.AssignedTo, &itm.Status, &itm.Notes, &itm.WeightG, &itm.VolumeML,
+			&itm.WeightUnit, &itm.VolumeUnit, &itm.ContainerID, &itm.GearID, &itm.DueAt, &itm.Position, &itm.CreatedAt)
 		if err != nil {
 			return nil, fmt.Errorf("err scanning row for get item by id : %w", err)
 		}
 		itm.CategoryID = catID
+		itm.fillMeasures()
 		items = append(items, itm)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	if err := is.attachAssignments(ctx, items); err != nil {
+		return nil, err
+	}
 	return &items, nil
 }
-func (is *ItemsService) UpdateItemStatus(itemID, newStatus, userID string) error {
-	var oldStatus string
-
-	// Get old status using squirrel
-	selectQuery := sq.Select("status").
-		From(TableItems).
-		Where(sq.Eq{"id": itemID}).
-		PlaceholderFormat(sq.Dollar)
 
-	selectSQL, selectArgs, err := selectQuery.ToSql()
-	if err != nil {
-		return fmt.Errorf("error building select item status query: %w", err)
+// attachAssignments loads the assignments of every item in one query.
+func (is *ItemsService) attachAssignments(ctx context.Context, items []Item) error {
+	if len(items) == 0 {
+		return nil
 	}
-
-	err = is.DB.QueryRow(selectSQL, selectArgs...).Scan(&oldStatus)
+	ids := make([]string, len(items))
+	index := make(map[string]int, len(items))
+	for i, itm := range items {
+		ids[i] = itm.ID
+		index[itm.ID] = i
+	}
+	assignments, err := queryAssignments(ctx, is.DB, sq.Eq{"item_id": ids})
 	if err != nil {
-		return fmt.Errorf("error fetching old status: %w", err)
+		return err
 	}
+	for _, a := range assignments {
+		i := index[a.ItemID]
+		items[i].Assignments = append(items[i].Assignments, a)
+	}
+	return nil
+}
 
-	tx, err := is.DB.Begin()
+// UpdateItemStatus moves the whole quantity of an item to newStatus: packed
+// marks every unit packed, delivered marks every unit delivered and to_pack
+// resets both counts.
+func (is *ItemsService) UpdateItemStatus(itemID, newStatus, userID string) error {
+	return is.UpdateItemsStatus(context.Background(), []string{itemID}, newStatus, userID)
+}
+
+// UpdateItemsStatus is UpdateItemStatus for several items in one
+// transaction: either every item moves to newStatus or none does.
+func (is *ItemsService) UpdateItemsStatus(ctx context.Context, itemIDs []string, newStatus, userID string) error {
+	tx, err := is.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("error beginning tx: %w", err)
 	}
 	defer tx.Rollback()
 
-	// Update item status
-	updateQuery := sq.Update(TableItems).
-		Set("status", newStatus).
-		Where(sq.Eq{"id": itemID}).
-		PlaceholderFormat(sq.Dollar)
+	// Locking in id order keeps concurrent batches from deadlocking.
+	ids := append([]string(nil), itemIDs...)
+	sort.Strings(ids)
+	for _, itemID := range ids {
+		if err := updateItemStatus(ctx, tx, itemID, newStatus, userID); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	for _, itemID := range ids {
+		is.publish(ctx, activity.TypeStatusChange, itemID, userID, nil, "%s is now %s", newStatus)
+	}
+	return nil
+}
 
-	updateSQL, updateArgs, err := updateQuery.ToSql()
+func updateItemStatus(ctx context.Context, tx *sql.Tx, itemID, newStatus, userID string) error {
+	current, err := lockItemProgress(ctx, tx, itemID)
 	if err != nil {
-		return fmt.Errorf("error building update item status query: %w", err)
+		return err
 	}
 
-	_, err = tx.Exec(updateSQL, updateArgs...)
-	if err != nil {
-		return fmt.Errorf("error updating item status: %w", err)
+	packed, delivered := 0, 0
+	switch newStatus {
+	case StatusToPack:
+	case StatusPacked:
+		packed = current.Quantity
+	case StatusDelivered:
+		packed, delivered = current.Quantity, current.Quantity
+	default:
+		return fmt.Errorf("invalid status: %s", newStatus)
 	}
 
-	// Insert status change history
-	historyID := uuid.NewString()
-	insertQuery := sq.Insert(TableItemStatusHistory).
-		Columns("id", "item_id", "user_id", "old_status", "new_status").
-		Values(historyID, itemID, userID, oldStatus, newStatus).
-		PlaceholderFormat(sq.Dollar)
+	if _, err := setItemProgress(ctx, tx, current, userID, packed, delivered, newStatus); err != nil {
+		return err
+	}
+	return resetAssignmentProgress(ctx, tx, itemID, newStatus)
+}
 
-	insertSQL, insertArgs, err := insertQuery.ToSql()
+// AssignItem makes userID the only assignee of the whole item.
+func (is *ItemsService) AssignItem(itemID, userID string) error {
+	ctx := context.Background()
+	tx, err := is.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("error building insert history query: %w", err)
+		return fmt.Errorf("error beginning tx: %w", err)
 	}
+	defer tx.Rollback()
 
-	_, err = tx.Exec(insertSQL, insertArgs...)
+	current, err := lockItemProgress(ctx, tx, itemID)
 	if err != nil {
-		return fmt.Errorf("error inserting item status history: %w", err)
+		return err
 	}
-	return tx.Commit()
-}
-
-func (is *ItemsService) AssignItem(itemID, userID string) error {
-	query := sq.Update(TableItems).Set("assigned_to", userID).Where(sq.Eq{"id": itemID})
-	sql, args, err := query.PlaceholderFormat(sq.Dollar).ToSql()
+	previous := is.assigneeIDs(ctx, itemID)
+	assignments, err := wholeItem(current, userID)
 	if err != nil {
-		return fmt.Errorf("error assigning item to user query generation: %w", err)
+		return err
 	}
-	_, err = is.DB.Exec(sql, args...)
-	return err
+	if err := setItemAssignments(ctx, tx, itemID, assignments); err != nil {
+		return fmt.Errorf("error assigning item to user: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	is.publish(ctx, activity.TypeAssignment, itemID, "", is.withAssignees(ctx, itemID, previous), "Assignees of %s changed")
+	return nil
 }
 
+// UnassignItem removes every assignee of the item.
 func (is *ItemsService) UnassignItem(itemID string) error {
-	query := sq.Update(TableItems).Set("assigned_to", nil).Where(sq.Eq{"id": itemID})
-	sql, args, err := query.PlaceholderFormat(sq.Dollar).ToSql()
+	ctx := context.Background()
+	tx, err := is.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("error unassigning item  generation: %w", err)
+		return fmt.Errorf("error beginning tx: %w", err)
 	}
-	_, err = is.DB.Exec(sql, args...)
-	return err
+	defer tx.Rollback()
+
+	previous := is.assigneeIDs(ctx, itemID)
+	if err := setItemAssignments(ctx, tx, itemID, nil); err != nil {
+		return fmt.Errorf("error unassigning item: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	is.publish(ctx, activity.TypeAssignment, itemID, "", previous, "%s was unassigned")
+	return nil
 }
 
+// EditItem changes the details of an item; see editItem for what may be
+// edited. A quantity change that moves the item to another status is logged
+// in its status history.
 func (is *ItemsService) EditItem(ctx context.Context, itemID string, updates map[string]interface{}) error {
+	tx, err := is.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := lockItemProgress(ctx, tx, itemID)
+	if err != nil {
+		return err
+	}
+	if err := editItem(ctx, tx, itemID, updates); err != nil {
+		return err
+	}
+	after, err := lockItemProgress(ctx, tx, itemID)
+	if err != nil {
+		return err
+	}
+	if after.Status != before.Status {
+		err := insertStatusHistory(ctx, tx, ItemStatusHistory{ItemID: itemID, OldStatus: before.Status, NewStatus: after.Status})
+		if err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if after.Status != before.Status {
+		is.publish(ctx, activity.TypeStatusChange, itemID, "", nil, "%s is now %s", after.Status)
+	}
+	return nil
+}
+
+// editItem applies client updates to the name, notes, quantity, due_at,
+// weight and volume of an item. Every other field has its own operation that
+// keeps it consistent, so any other key is rejected with ErrInvalidEdit.
+func editItem(ctx context.Context, tx *sql.Tx, itemID string, updates map[string]interface{}) error {
 	if len(updates) == 0 {
-		return fmt.Errorf("no fields to update")
+		return fmt.Errorf("%w: no fields to update", ErrInvalidEdit)
+	}
+	set := map[string]interface{}{}
+	for key, value := range updates {
+		switch key {
+		case "name":
+			name, ok := value.(string)
+			if !ok || strings.TrimSpace(name) == "" {
+				return fmt.Errorf("%w: name must be a non-empty string", ErrInvalidEdit)
+			}
+			set[key] = strings.TrimSpace(name)
+		case "notes":
+			if _, ok := value.(string); !ok && value != nil {
+				return fmt.Errorf("%w: notes must be a string or null", ErrInvalidEdit)
+			}
+			set[key] = value
+		case "due_at":
+			if value == nil {
+				set[key] = nil
+				break
+			}
+			raw, _ := value.(string)
+			dueAt, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				return fmt.Errorf("%w: due_at must be an RFC 3339 time or null", ErrInvalidEdit)
+			}
+			set[key] = dueAt
+		case "weight", "volume":
+			set[key] = value
+		case "quantity":
+		case "category_id", "position":
+			return fmt.Errorf("%w: %s is changed by moving the item", ErrInvalidEdit, key)
+		default:
+			return fmt.Errorf("%w: %s cannot be edited", ErrInvalidEdit, key)
+		}
+	}
+	if err := measureUpdates(set); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidEdit, err)
+	}
+
+	if raw, ok := updates["quantity"]; ok {
+		quantity, ok := raw.(float64)
+		if !ok || quantity < 0 || quantity != math.Trunc(quantity) {
+			return fmt.Errorf("%w: quantity must be a non-negative whole number", ErrInvalidEdit)
+		}
+		if err := setItemQuantity(ctx, tx, itemID, int(quantity)); err != nil {
+			return err
+		}
+	}
+	if len(set) == 0 {
+		return nil
 	}
 
 	query := sq.Update(TableItems).
-		SetMap(updates).
+		SetMap(set).
 		Where(sq.Eq{"id": itemID}).
 		PlaceholderFormat(sq.Dollar)
 
@@ -179,7 +478,7 @@ func (is *ItemsService) EditItem(ctx context.Context, itemID string, updates map
 		return fmt.Errorf("error building update query: %w", err)
 	}
 
-	_, err = is.DB.ExecContext(ctx, sqlStr, args...)
+	_, err = tx.ExecContext(ctx, sqlStr, args...)
 	if err != nil {
 		return fmt.Errorf("error executing item update: %w", err)
 	}
@@ -187,6 +486,43 @@ func (is *ItemsService) EditItem(ctx context.Context, itemID string, updates map
 	return nil
 }
 
+// setItemQuantity changes the quantity of an item, which cannot drop below
+// what is already packed or assigned, and re-derives its status.
+func setItemQuantity(ctx context.Context, tx *sql.Tx, itemID string, quantity int) error {
+	current, err := lockItemProgress(ctx, tx, itemID)
+	if err != nil {
+		return err
+	}
+	assignments, err := queryAssignments(ctx, tx, sq.Eq{"item_id": itemID})
+	if err != nil {
+		return err
+	}
+	assigned := 0
+	for _, a := range assignments {
+		assigned += a.Quantity
+	}
+	if quantity < current.PackedQuantity || quantity < assigned {
+		return ErrQuantityBelowProgress
+	}
+
+	query := sq.Update(TableItems).
+		Set("quantity", quantity).
+		Where(sq.Eq{"id": itemID}).
+		PlaceholderFormat(sq.Dollar)
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building update quantity query: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("error updating item quantity: %w", err)
+	}
+
+	current.Quantity = quantity
+	status := DeriveStatus(quantity, current.PackedQuantity, current.DeliveredQuantity)
+	_, err = writeItemProgress(ctx, tx, current, current.PackedQuantity, current.DeliveredQuantity, status)
+	return err
+}
+
 func (is *ItemsService) DeleteItem(id string) error {
 	query := sq.Delete(TableItems).Where(sq.Eq{"id": id})
 	sql, args, err := query.PlaceholderFormat(sq.Dollar).ToSql()
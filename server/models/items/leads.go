@@ -0,0 +1,330 @@
+package items
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/sunnymotiani/PackTrack/server/models/events"
+	"github.com/sunnymotiani/PackTrack/server/models/users"
+)
+
+// CATEGORY LEADS IMPLEMENTATION
+
+const TableCategoryLeads = "category_leads"
+
+var (
+	ErrNotEventAdmin = errors.New("only event owners and admins can do that")
+	ErrCannotManage  = errors.New("only event admins and leads of the category can manage it")
+	ErrCannotLead    = errors.New("viewers cannot lead a category")
+)
+
+// CategoryLead is a member who manages a category and its subcategories.
+type CategoryLead struct {
+	CategoryID string `json:"category_id"`
+	UserID     string `json:"user_id"`
+	Name       string `json:"name"`
+	Email      string `json:"email"`
+}
+
+// AddCategoryLead makes userID a lead of a category. Only event owners and
+// admins, given by actorID, may do so, and viewers cannot lead.
+func (is *ItemsService) AddCategoryLead(ctx context.Context, categoryID, actorID, userID string) error {
+	eventID, err := categoryEventID(ctx, is.DB, categoryID)
+	if err != nil {
+		return err
+	}
+	if err := checkEventAdmin(ctx, is.DB, eventID, actorID); err != nil {
+		return err
+	}
+	role, err := eventRole(ctx, is.DB, eventID, userID)
+	if err != nil {
+		return err
+	}
+	if role == "viewer" {
+		return ErrCannotLead
+	}
+
+	query := sq.Insert(TableCategoryLeads).
+		Columns("category_id", "user_id").
+		Values(categoryID, userID).
+		Suffix("ON CONFLICT DO NOTHING").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building add lead query: %w", err)
+	}
+	if _, err := is.DB.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("error adding category lead: %w", err)
+	}
+	return nil
+}
+
+// RemoveCategoryLead takes userID off the leads of a category.
+func (is *ItemsService) RemoveCategoryLead(ctx context.Context, categoryID, actorID, userID string) error {
+	eventID, err := categoryEventID(ctx, is.DB, categoryID)
+	if err != nil {
+		return err
+	}
+	if err := checkEventAdmin(ctx, is.DB, eventID, actorID); err != nil {
+		return err
+	}
+
+	query := sq.Delete(TableCategoryLeads).
+		Where(sq.Eq{"category_id": categoryID, "user_id": userID}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building remove lead query: %w", err)
+	}
+	if _, err := is.DB.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("error removing category lead: %w", err)
+	}
+	return nil
+}
+
+// GetCategoryLeads lists the leads of the categories of an event.
+func (is *ItemsService) GetCategoryLeads(ctx context.Context, eventID string) ([]CategoryLead, error) {
+	return queryCategoryLeads(ctx, is.DB, eventID)
+}
+
+// GetMyCategories returns the categories of an event userID leads, each with
+// its subcategories and progress.
+func (is *ItemsService) GetMyCategories(ctx context.Context, eventID, userID string) ([]*CategoryNode, error) {
+	tree, err := LoadCategoryTree(ctx, is.DB, eventID)
+	if err != nil {
+		return nil, err
+	}
+	mine := []*CategoryNode{}
+	var walk func([]*CategoryNode)
+	walk = func(nodes []*CategoryNode) {
+		for _, n := range nodes {
+			if contains(n.Leads, userID) {
+				mine = append(mine, n)
+				continue
+			}
+			walk(n.Children)
+		}
+	}
+	walk(tree)
+	return mine, nil
+}
+
+// CanManageItem returns nil when userID may manage the item: event owners
+// and admins always can, leads only within their categories.
+func (is *ItemsService) CanManageItem(ctx context.Context, itemID, userID string) error {
+	return checkCanManageItem(ctx, is.DB, itemID, userID)
+}
+
+// CanActOnItem returns nil when userID may work on the item as one of its
+// holders: releasing it, packing and delivering it or recording its return.
+// Members assigned to the item can, viewers aside; everyone else needs
+// CanManageItem.
+func (is *ItemsService) CanActOnItem(ctx context.Context, itemID, userID string) error {
+	return checkCanActOnItem(ctx, is.DB, itemID, userID, false)
+}
+
+// CanClaimItem is CanActOnItem for claims, which members may also make on
+// items nobody holds yet.
+func (is *ItemsService) CanClaimItem(ctx context.Context, itemID, userID string) error {
+	return checkCanActOnItem(ctx, is.DB, itemID, userID, true)
+}
+
+// CanManageCategory is CanManageItem for a category.
+func (is *ItemsService) CanManageCategory(ctx context.Context, categoryID, userID string) error {
+	return checkCanManageCategory(ctx, is.DB, categoryID, userID)
+}
+
+// CanEditEvent returns nil when userID is a member of the event who is not a
+// viewer.
+func (is *ItemsService) CanEditEvent(ctx context.Context, eventID, userID string) error {
+	return checkCanEditEvent(ctx, is.DB, eventID, userID)
+}
+
+// CanAddToCategoryPath returns nil when userID may add items at a category
+// path of the event, creating the levels that are missing: the deepest
+// existing level must be one they manage, and only owners and admins may
+// create a new top-level category.
+func (is *ItemsService) CanAddToCategoryPath(ctx context.Context, eventID, path, userID string) error {
+	var parentID *string
+	for _, name := range SplitCategoryPath(path) {
+		query := sq.Select("id").From(TableCategories).
+			Where(sq.Eq{"event_id": eventID, "parent_id": parentID, "name": name}).
+			PlaceholderFormat(sq.Dollar)
+		sqlStr, args, err := query.ToSql()
+		if err != nil {
+			return fmt.Errorf("error building category path query: %w", err)
+		}
+		var id string
+		err = is.DB.QueryRowContext(ctx, sqlStr, args...).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error fetching category on path: %w", err)
+		}
+		parentID = &id
+	}
+	if parentID == nil {
+		return checkEventAdmin(ctx, is.DB, eventID, userID)
+	}
+	return checkCanManageCategory(ctx, is.DB, *parentID, userID)
+}
+
+func checkCanActOnItem(ctx context.Context, q queryer, itemID, userID string, unclaimed bool) error {
+	role, err := memberRole(ctx, q, itemID, userID)
+	if err == nil && role != "viewer" {
+		assignments, err := queryAssignments(ctx, q, sq.Eq{"item_id": itemID})
+		if err != nil {
+			return err
+		}
+		if unclaimed && len(assignments) == 0 {
+			return nil
+		}
+		for _, a := range assignments {
+			if a.UserID == userID {
+				return nil
+			}
+		}
+	}
+	return checkCanManageItem(ctx, q, itemID, userID)
+}
+
+func checkCanManageItem(ctx context.Context, q queryer, itemID, userID string) error {
+	query := sq.Select("category_id").From(TableItems).Where(sq.Eq{"id": itemID}).PlaceholderFormat(sq.Dollar)
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building item category query: %w", err)
+	}
+	var categoryID string
+	if err := q.QueryRowContext(ctx, sqlStr, args...).Scan(&categoryID); err != nil {
+		return fmt.Errorf("error fetching item category: %w", err)
+	}
+	return checkCanManageCategory(ctx, q, categoryID, userID)
+}
+
+func checkCanManageCategory(ctx context.Context, q queryer, categoryID, userID string) error {
+	eventID, err := categoryEventID(ctx, q, categoryID)
+	if err != nil {
+		return err
+	}
+	role, err := eventRole(ctx, q, eventID, userID)
+	if err != nil {
+		return err
+	}
+	switch role {
+	case "owner", "admin":
+		return nil
+	case "viewer":
+		return ErrCannotManage
+	}
+
+	leads := fmt.Sprintf(`WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id FROM %[1]s WHERE id = $1
+			UNION
+			SELECT c.id, c.parent_id FROM %[1]s c JOIN ancestors a ON c.id = a.parent_id
+		)
+		SELECT EXISTS (SELECT 1 FROM %[2]s l JOIN ancestors a ON a.id = l.category_id WHERE l.user_id = $2)`,
+		TableCategories, TableCategoryLeads)
+	var lead bool
+	if err := q.QueryRowContext(ctx, leads, categoryID, userID).Scan(&lead); err != nil {
+		return fmt.Errorf("error checking category leads: %w", err)
+	}
+	if !lead {
+		return ErrCannotManage
+	}
+	return nil
+}
+
+func checkEventAdmin(ctx context.Context, q queryer, eventID, userID string) error {
+	role, err := eventRole(ctx, q, eventID, userID)
+	if errors.Is(err, ErrNotEventMember) || err == nil && role != "owner" && role != "admin" {
+		return ErrNotEventAdmin
+	}
+	return err
+}
+
+// eventRole returns the role userID has in an event, or ErrNotEventMember.
+func eventRole(ctx context.Context, q queryer, eventID, userID string) (string, error) {
+	query := sq.Select("role").
+		From(events.TableEventMemberships).
+		Where(sq.Eq{"event_id": eventID, "user_id": userID}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return "", fmt.Errorf("error building member role query: %w", err)
+	}
+	var role string
+	err = q.QueryRowContext(ctx, sqlStr, args...).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotEventMember
+	}
+	if err != nil {
+		return "", fmt.Errorf("error fetching member role: %w", err)
+	}
+	return role, nil
+}
+
+func categoryEventID(ctx context.Context, q queryer, categoryID string) (string, error) {
+	query := sq.Select("event_id").From(TableCategories).Where(sq.Eq{"id": categoryID}).PlaceholderFormat(sq.Dollar)
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return "", fmt.Errorf("error building select category query: %w", err)
+	}
+	var eventID string
+	err = q.QueryRowContext(ctx, sqlStr, args...).Scan(&eventID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrCategoryNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("error fetching category: %w", err)
+	}
+	return eventID, nil
+}
+
+func queryCategoryLeads(ctx context.Context, q queryer, eventID string) ([]CategoryLead, error) {
+	query := sq.Select("l.category_id", "u.id", "u.name", "u.email").
+		From(TableCategoryLeads+" l").
+		Join(TableCategories+" c ON c.id = l.category_id").
+		Join(users.TableUsers+" u ON u.id = l.user_id").
+		Where(sq.Eq{"c.event_id": eventID}).
+		OrderBy("c.position ASC", "l.created_at ASC", "u.id ASC").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building select leads query: %w", err)
+	}
+	rows, err := q.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying category leads: %w", err)
+	}
+	defer rows.Close()
+
+	leads := []CategoryLead{}
+	for rows.Next() {
+		var l CategoryLead
+		if err := rows.Scan(&l.CategoryID, &l.UserID, &l.Name, &l.Email); err != nil {
+			return nil, fmt.Errorf("error scanning lead row: %w", err)
+		}
+		leads = append(leads, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return leads, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,191 @@
+package items
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/sunnymotiani/PackTrack/server/models/activity"
+)
+
+// PARTIAL PACKING IMPLEMENTATION
+
+var ErrInvalidProgress = errors.New("packed and delivered quantities must satisfy 0 <= delivered <= packed <= quantity")
+
+// ItemProgress is the packing state of an item. Status is derived from the
+// packed and delivered counts.
+type ItemProgress struct {
+	ItemID            string `json:"item_id"`
+	Quantity          int    `json:"quantity"`
+	PackedQuantity    int    `json:"packed_quantity"`
+	DeliveredQuantity int    `json:"delivered_quantity"`
+	ReturnedQuantity  int    `json:"returned_quantity"`
+	MissingQuantity   int    `json:"missing_quantity"`
+	DamagedQuantity   int    `json:"damaged_quantity"`
+	Status            string `json:"status"`
+}
+
+// DeriveStatus returns the status implied by the packed and delivered counts
+// of an item: delivered once every unit is delivered, packed once every unit
+// is packed and to_pack otherwise.
+func DeriveStatus(quantity, packed, delivered int) string {
+	switch {
+	case quantity > 0 && delivered >= quantity:
+		return StatusDelivered
+	case quantity > 0 && packed >= quantity:
+		return StatusPacked
+	default:
+		return StatusToPack
+	}
+}
+
+// AdjustItemProgress increments (or decrements with negative deltas) the
+// packed and delivered counts of an item and records the deltas in its
+// status history.
+func (is *ItemsService) AdjustItemProgress(ctx context.Context, itemID, userID string, packedDelta, deliveredDelta int) (*ItemProgress, error) {
+	if packedDelta == 0 && deliveredDelta == 0 {
+		return nil, fmt.Errorf("no quantity change requested")
+	}
+
+	tx, err := is.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	progress, err := adjustItemProgress(ctx, tx, itemID, userID, packedDelta, deliveredDelta)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	is.publish(ctx, activity.TypeStatusChange, itemID, userID, nil, "%s is now %s", progress.Status)
+	return progress, nil
+}
+
+func adjustItemProgress(ctx context.Context, tx *sql.Tx, itemID, userID string, packedDelta, deliveredDelta int) (*ItemProgress, error) {
+	current, err := lockItemProgress(ctx, tx, itemID)
+	if err != nil {
+		return nil, err
+	}
+	packed := current.PackedQuantity + packedDelta
+	delivered := current.DeliveredQuantity + deliveredDelta
+	status := DeriveStatus(current.Quantity, packed, delivered)
+	return setItemProgress(ctx, tx, current, userID, packed, delivered, status)
+}
+
+// lockItemProgress reads the packing state of an item and locks its row until
+// the transaction ends.
+func lockItemProgress(ctx context.Context, tx *sql.Tx, itemID string) (*ItemProgress, error) {
+	query := sq.Select("COALESCE(quantity, 0)", "packed_quantity", "delivered_quantity",
+		"returned_quantity", "missing_quantity", "damaged_quantity", "status").
+		From(TableItems).
+		Where(sq.Eq{"id": itemID}).
+		Suffix("FOR UPDATE").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building select item progress query: %w", err)
+	}
+
+	progress := &ItemProgress{ItemID: itemID}
+	err = tx.QueryRowContext(ctx, sqlStr, args...).
+		Scan(&progress.Quantity, &progress.PackedQuantity, &progress.DeliveredQuantity,
+			&progress.ReturnedQuantity, &progress.MissingQuantity, &progress.DamagedQuantity, &progress.Status)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching item progress: %w", err)
+	}
+	return progress, nil
+}
+
+// setItemProgress stores the new counts and status of a locked item and logs
+// the change with its quantity deltas. Delivered units already reconciled
+// after the event cannot be taken back, and a delivered item whose units are
+// all reconciled keeps its return status.
+func setItemProgress(ctx context.Context, tx *sql.Tx, current *ItemProgress, userID string, packed, delivered int, status string) (*ItemProgress, error) {
+	next, err := writeItemProgress(ctx, tx, current, packed, delivered, status)
+	if err != nil {
+		return nil, err
+	}
+	err = insertStatusHistory(ctx, tx, ItemStatusHistory{
+		ItemID:         current.ItemID,
+		UserID:         &userID,
+		OldStatus:      current.Status,
+		NewStatus:      next.Status,
+		PackedDelta:    packed - current.PackedQuantity,
+		DeliveredDelta: delivered - current.DeliveredQuantity,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+// writeItemProgress is setItemProgress without the history entry.
+func writeItemProgress(ctx context.Context, tx *sql.Tx, current *ItemProgress, packed, delivered int, status string) (*ItemProgress, error) {
+	if delivered < current.reconciled() || delivered > packed || packed > current.Quantity {
+		return nil, ErrInvalidProgress
+	}
+	if status == StatusDelivered {
+		if s := ReturnStatus(delivered, current.ReturnedQuantity, current.MissingQuantity, current.DamagedQuantity); s != "" {
+			status = s
+		}
+	}
+
+	updateQuery := sq.Update(TableItems).
+		Set("packed_quantity", packed).
+		Set("delivered_quantity", delivered).
+		Set("status", status).
+		Where(sq.Eq{"id": current.ItemID}).
+		PlaceholderFormat(sq.Dollar)
+
+	updateSQL, updateArgs, err := updateQuery.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building update item progress query: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, updateSQL, updateArgs...); err != nil {
+		return nil, fmt.Errorf("error updating item progress: %w", err)
+	}
+
+	return &ItemProgress{
+		ItemID:            current.ItemID,
+		Quantity:          current.Quantity,
+		PackedQuantity:    packed,
+		DeliveredQuantity: delivered,
+		ReturnedQuantity:  current.ReturnedQuantity,
+		MissingQuantity:   current.MissingQuantity,
+		DamagedQuantity:   current.DamagedQuantity,
+		Status:            status,
+	}, nil
+}
+
+func insertStatusHistory(ctx context.Context, tx *sql.Tx, record ItemStatusHistory) error {
+	var userID interface{}
+	if record.UserID != nil && *record.UserID != "" {
+		userID = *record.UserID
+	}
+
+	insertQuery := sq.Insert(TableItemStatusHistory).
+		Columns("id", "item_id", "user_id", "old_status", "new_status", "packed_delta", "delivered_delta",
+			"returned_delta", "missing_delta", "damaged_delta", "notes").
+		Values(uuid.NewString(), record.ItemID, userID, record.OldStatus, record.NewStatus,
+			record.PackedDelta, record.DeliveredDelta,
+			record.ReturnedDelta, record.MissingDelta, record.DamagedDelta, record.Notes).
+		PlaceholderFormat(sq.Dollar)
+
+	insertSQL, insertArgs, err := insertQuery.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building insert history query: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, insertSQL, insertArgs...); err != nil {
+		return fmt.Errorf("error inserting item status history: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+package items
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// ORDERING IMPLEMENTATION
+
+// PositionGap is the space left between neighbouring positions, so that a
+// row can be moved between two others by taking the midpoint.
+const PositionGap = 1024
+
+// minPositionGap is how close two positions may get before the whole list is
+// spread out again.
+const minPositionGap = 1e-6
+
+var (
+	ErrInvalidPlacement   = errors.New("give either before_id or after_id, naming another row of the same list")
+	ErrCategoryNotInEvent = errors.New("category does not belong to the item's event")
+)
+
+// Placement says where a moved item or category goes: right before BeforeID,
+// right after AfterID, or at the end of the list when neither is set.
+// CategoryID moves an item to another category of the same event.
+type Placement struct {
+	CategoryID string `json:"category_id,omitempty"`
+	BeforeID   string `json:"before_id,omitempty"`
+	AfterID    string `json:"after_id,omitempty"`
+}
+
+// MoveItem moves an item within its category or to another category of the
+// same event.
+func (is *ItemsService) MoveItem(ctx context.Context, itemID string, p Placement) error {
+	tx, err := is.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := sq.Select("i.category_id", "c.event_id").
+		From(TableItems + " i").
+		Join(TableCategories + " c ON c.id = i.category_id").
+		Where(sq.Eq{"i.id": itemID}).
+		Suffix("FOR UPDATE OF i").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building select item query: %w", err)
+	}
+	var categoryID, eventID string
+	if err := tx.QueryRowContext(ctx, sqlStr, args...).Scan(&categoryID, &eventID); err != nil {
+		return fmt.Errorf("error fetching item: %w", err)
+	}
+	if p.CategoryID != "" && p.CategoryID != categoryID {
+		if err := checkCategoryInEvent(ctx, tx, p.CategoryID, eventID); err != nil {
+			return err
+		}
+		categoryID = p.CategoryID
+	}
+
+	position, err := place(ctx, tx, TableItems, "category_id", categoryID, itemID, p)
+	if err != nil {
+		return err
+	}
+	update := sq.Update(TableItems).
+		Set("category_id", categoryID).
+		Set("position", position).
+		Where(sq.Eq{"id": itemID}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err = update.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building move item query: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("error moving item: %w", err)
+	}
+	return tx.Commit()
+}
+
+// MoveCategory moves a category within its event.
+func (is *ItemsService) MoveCategory(ctx context.Context, categoryID string, p Placement) error {
+	tx, err := is.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	category, err := lockCategory(ctx, tx, categoryID)
+	if err != nil {
+		return err
+	}
+
+	position, err := place(ctx, tx, TableCategories, "event_id", category.EventID, categoryID, p)
+	if err != nil {
+		return err
+	}
+	update := sq.Update(TableCategories).
+		Set("position", position).
+		Where(sq.Eq{"id": categoryID}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := update.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building move category query: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("error moving category: %w", err)
+	}
+	return tx.Commit()
+}
+
+// place works out the position for row movingID in the list of table rows
+// whose scope column equals scope. The other rows of the list stay locked
+// until the transaction ends; they are renumbered when there is no room left
+// between the two neighbours.
+func place(ctx context.Context, tx *sql.Tx, table, scope, scopeID, movingID string, p Placement) (float64, error) {
+	if p.BeforeID != "" && p.AfterID != "" || p.BeforeID == movingID || p.AfterID == movingID {
+		return 0, ErrInvalidPlacement
+	}
+
+	query := sq.Select("id", "position").
+		From(table).
+		Where(sq.Eq{scope: scopeID}).
+		Where(sq.NotEq{"id": movingID}).
+		OrderBy("position ASC", "id ASC").
+		Suffix("FOR UPDATE").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("error building select positions query: %w", err)
+	}
+	rows, err := tx.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return 0, fmt.Errorf("error fetching positions: %w", err)
+	}
+	defer rows.Close()
+
+	type row struct {
+		id       string
+		position float64
+	}
+	var list []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.position); err != nil {
+			return 0, fmt.Errorf("error scanning position row: %w", err)
+		}
+		list = append(list, r)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("rows iteration error: %w", err)
+	}
+	rows.Close()
+
+	at := len(list)
+	if p.BeforeID != "" || p.AfterID != "" {
+		at = -1
+		for i, r := range list {
+			switch r.id {
+			case p.BeforeID:
+				at = i
+			case p.AfterID:
+				at = i + 1
+			}
+		}
+		if at < 0 {
+			return 0, ErrInvalidPlacement
+		}
+	}
+
+	switch {
+	case len(list) == 0:
+		return PositionGap, nil
+	case at == 0:
+		return list[0].position - PositionGap, nil
+	case at == len(list):
+		return list[at-1].position + PositionGap, nil
+	}
+	prev, next := list[at-1].position, list[at].position
+	if next-prev > minPositionGap {
+		return prev + (next-prev)/2, nil
+	}
+
+	// No room left: spread the list out again, keeping a slot free at "at".
+	for i, r := range list {
+		slot := i + 1
+		if i >= at {
+			slot++
+		}
+		update := sq.Update(table).
+			Set("position", float64(slot*PositionGap)).
+			Where(sq.Eq{"id": r.id}).
+			PlaceholderFormat(sq.Dollar)
+		sqlStr, args, err := update.ToSql()
+		if err != nil {
+			return 0, fmt.Errorf("error building renumber query: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, sqlStr, args...); err != nil {
+			return 0, fmt.Errorf("error renumbering positions: %w", err)
+		}
+	}
+	return float64((at + 1) * PositionGap), nil
+}
+
+// nextPosition is the position after the last row of a list, for inserts.
+func nextPosition(table, scope, scopeID string) sq.Sqlizer {
+	return sq.Expr("(SELECT COALESCE(MAX(position), 0) + ? FROM "+table+" WHERE "+scope+" = ?)", PositionGap, scopeID)
+}
+
+func checkCategoryInEvent(ctx context.Context, q queryer, categoryID, eventID string) error {
+	query := sq.Select("event_id").
+		From(TableCategories).
+		Where(sq.Eq{"id": categoryID}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building select category query: %w", err)
+	}
+	var categoryEventID string
+	err = q.QueryRowContext(ctx, sqlStr, args...).Scan(&categoryEventID)
+	if errors.Is(err, sql.ErrNoRows) || err == nil && categoryEventID != eventID {
+		return ErrCategoryNotInEvent
+	}
+	if err != nil {
+		return fmt.Errorf("error fetching category: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package items
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/sunnymotiani/PackTrack/server/models/activity"
+)
+
+// RETURN RECONCILIATION IMPLEMENTATION
+
+var ErrInvalidReturn = errors.New("returned, missing and damaged quantities must not be negative or add up to more than the delivered quantity")
+
+// ReturnInput records what happened to delivered units of an item once the
+// event is over. Counts are deltas, so a negative value corrects an earlier
+// entry. Notes, when set, replace the item's return notes.
+type ReturnInput struct {
+	Returned int     `json:"returned"`
+	Missing  int     `json:"missing"`
+	Damaged  int     `json:"damaged"`
+	Notes    *string `json:"notes,omitempty"`
+}
+
+// ReturnStatus returns the status of an item whose delivered units have all
+// been reconciled: missing if any unit is missing, damaged if any came back
+// damaged and returned otherwise. It returns "" while some delivered units
+// are still outstanding.
+func ReturnStatus(delivered, returned, missing, damaged int) string {
+	switch {
+	case delivered == 0 || returned+missing+damaged < delivered:
+		return ""
+	case missing > 0:
+		return StatusMissing
+	case damaged > 0:
+		return StatusDamaged
+	default:
+		return StatusReturned
+	}
+}
+
+func (p *ItemProgress) reconciled() int {
+	return p.ReturnedQuantity + p.MissingQuantity + p.DamagedQuantity
+}
+
+// RecordReturn marks delivered units of an item as returned, missing or
+// damaged and logs the change in the item's status history.
+func (is *ItemsService) RecordReturn(ctx context.Context, itemID, userID string, input ReturnInput) (*ItemProgress, error) {
+	if input.Returned == 0 && input.Missing == 0 && input.Damaged == 0 && input.Notes == nil {
+		return nil, fmt.Errorf("no return change requested")
+	}
+
+	tx, err := is.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	current, err := lockItemProgress(ctx, tx, itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	next := *current
+	next.ReturnedQuantity += input.Returned
+	next.MissingQuantity += input.Missing
+	next.DamagedQuantity += input.Damaged
+	if next.ReturnedQuantity < 0 || next.MissingQuantity < 0 || next.DamagedQuantity < 0 ||
+		next.reconciled() > next.DeliveredQuantity {
+		return nil, ErrInvalidReturn
+	}
+	next.Status = ReturnStatus(next.DeliveredQuantity, next.ReturnedQuantity, next.MissingQuantity, next.DamagedQuantity)
+	if next.Status == "" {
+		next.Status = DeriveStatus(next.Quantity, next.PackedQuantity, next.DeliveredQuantity)
+	}
+
+	update := sq.Update(TableItems).
+		Set("returned_quantity", next.ReturnedQuantity).
+		Set("missing_quantity", next.MissingQuantity).
+		Set("damaged_quantity", next.DamagedQuantity).
+		Set("status", next.Status).
+		Where(sq.Eq{"id": itemID}).
+		PlaceholderFormat(sq.Dollar)
+	if input.Notes != nil {
+		update = update.Set("return_notes", *input.Notes)
+	}
+
+	updateSQL, updateArgs, err := update.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building update item return query: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, updateSQL, updateArgs...); err != nil {
+		return nil, fmt.Errorf("error updating item return: %w", err)
+	}
+
+	err = insertStatusHistory(ctx, tx, ItemStatusHistory{
+		ItemID:        itemID,
+		UserID:        &userID,
+		OldStatus:     current.Status,
+		NewStatus:     next.Status,
+		ReturnedDelta: input.Returned,
+		MissingDelta:  input.Missing,
+		DamagedDelta:  input.Damaged,
+		Notes:         input.Notes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	is.publish(ctx, activity.TypeStatusChange, itemID, userID, nil, "%s is now %s", next.Status)
+	return &next, nil
+}
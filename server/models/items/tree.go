@@ -0,0 +1,258 @@
+package items
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// CATEGORY TREE IMPLEMENTATION
+
+// CategoryPathSeparator joins the names of nested categories in a path.
+const CategoryPathSeparator = " > "
+
+var (
+	ErrCategoryCycle  = errors.New("a category cannot be nested under itself or one of its subcategories")
+	ErrCategoryExists = errors.New("a category with that name already exists at that level")
+)
+
+// CategoryProgress sums the items of a category.
+type CategoryProgress struct {
+	Items             int `json:"items"`
+	Quantity          int `json:"quantity"`
+	PackedQuantity    int `json:"packed_quantity"`
+	DeliveredQuantity int `json:"delivered_quantity"`
+}
+
+func (p *CategoryProgress) add(o CategoryProgress) {
+	p.Items += o.Items
+	p.Quantity += o.Quantity
+	p.PackedQuantity += o.PackedQuantity
+	p.DeliveredQuantity += o.DeliveredQuantity
+}
+
+// CategoryNode is a category with its subcategories. Own counts the items
+// directly in the category and Total adds those of every subcategory. Leads
+// lists the users who lead this category itself.
+type CategoryNode struct {
+	Category
+	Path     string           `json:"path"`
+	Leads    []string         `json:"leads"`
+	Depth    int              `json:"depth"`
+	Own      CategoryProgress `json:"own"`
+	Total    CategoryProgress `json:"total"`
+	Children []*CategoryNode  `json:"children"`
+}
+
+// SplitCategoryPath returns the trimmed, non-empty names of a path like
+// "Kitchen > Cooking > Utensils".
+func SplitCategoryPath(path string) []string {
+	var names []string
+	for _, name := range strings.Split(path, ">") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// GetCategoryTree returns the top-level categories of an event with their
+// subcategories, each level in position order.
+func (is *ItemsService) GetCategoryTree(ctx context.Context, eventID string) ([]*CategoryNode, error) {
+	return LoadCategoryTree(ctx, is.DB, eventID)
+}
+
+// LoadCategoryTree is GetCategoryTree for callers outside ItemsService.
+func LoadCategoryTree(ctx context.Context, db *sql.DB, eventID string) ([]*CategoryNode, error) {
+	query := sq.Select("c.id", "c.parent_id", "c.name", "c.position", "COUNT(i.id)", "COALESCE(SUM(i.quantity), 0)",
+		"COALESCE(SUM(i.packed_quantity), 0)", "COALESCE(SUM(i.delivered_quantity), 0)").
+		From(TableCategories+" c").
+		LeftJoin(TableItems+" i ON i.category_id = c.id").
+		Where(sq.Eq{"c.event_id": eventID}).
+		GroupBy("c.id").
+		OrderBy("c.position ASC", "c.id ASC").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building category tree query: %w", err)
+	}
+	rows, err := db.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying category tree: %w", err)
+	}
+	defer rows.Close()
+
+	var all []*CategoryNode
+	byID := map[string]*CategoryNode{}
+	for rows.Next() {
+		n := &CategoryNode{Category: Category{EventID: eventID}, Leads: []string{}, Children: []*CategoryNode{}}
+		if err := rows.Scan(&n.ID, &n.ParentID, &n.Name, &n.Position, &n.Own.Items, &n.Own.Quantity,
+			&n.Own.PackedQuantity, &n.Own.DeliveredQuantity); err != nil {
+			return nil, fmt.Errorf("error scanning category row: %w", err)
+		}
+		all = append(all, n)
+		byID[n.ID] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	rows.Close()
+
+	leads, err := queryCategoryLeads(ctx, db, eventID)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range leads {
+		if n, ok := byID[l.CategoryID]; ok {
+			n.Leads = append(n.Leads, l.UserID)
+		}
+	}
+
+	roots := []*CategoryNode{}
+	for _, n := range all {
+		if parent, ok := byID[deref(n.ParentID)]; ok {
+			parent.Children = append(parent.Children, n)
+		} else {
+			roots = append(roots, n)
+		}
+	}
+	for _, n := range roots {
+		rollUp(n, "", 0)
+	}
+	return roots, nil
+}
+
+// rollUp fills in the path, depth and totals of n and its subcategories,
+// which are already in position order.
+func rollUp(n *CategoryNode, parentPath string, depth int) {
+	n.Path, n.Depth, n.Total = n.Name, depth, n.Own
+	if parentPath != "" {
+		n.Path = parentPath + CategoryPathSeparator + n.Name
+	}
+	for _, c := range n.Children {
+		rollUp(c, n.Path, depth+1)
+		n.Total.add(c.Total)
+	}
+}
+
+// FlattenCategoryTree lists the nodes of a tree depth first, each parent
+// before its subcategories.
+func FlattenCategoryTree(roots []*CategoryNode) []*CategoryNode {
+	var flat []*CategoryNode
+	var walk func([]*CategoryNode)
+	walk = func(nodes []*CategoryNode) {
+		for _, n := range nodes {
+			flat = append(flat, n)
+			walk(n.Children)
+		}
+	}
+	walk(roots)
+	return flat
+}
+
+// SetCategoryParent nests a category under parentID, another category of the
+// same event, or makes it top-level when parentID is nil.
+func (is *ItemsService) SetCategoryParent(ctx context.Context, categoryID string, parentID *string) error {
+	tx, err := is.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Two reparents checked side by side could each pass the cycle check and
+	// together close a loop, so the event's whole tree is locked first.
+	if err := lockEventCategories(ctx, tx, categoryID); err != nil {
+		return err
+	}
+	category, err := lockCategory(ctx, tx, categoryID)
+	if err != nil {
+		return err
+	}
+	eventID, name := category.EventID, category.Name
+
+	if parentID != nil {
+		if err := checkCategoryInEvent(ctx, tx, *parentID, eventID); err != nil {
+			return err
+		}
+		if err := checkNotDescendant(ctx, tx, categoryID, *parentID); err != nil {
+			return err
+		}
+	}
+
+	if err := checkSiblingName(ctx, tx, eventID, parentID, name, categoryID); err != nil {
+		return err
+	}
+
+	update := sq.Update(TableCategories).
+		Set("parent_id", parentID).
+		Where(sq.Eq{"id": categoryID}).
+		PlaceholderFormat(sq.Dollar)
+	sqlStr, args, err := update.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building set parent query: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, sqlStr, args...); err != nil {
+		if siblingNameTaken(err) {
+			return ErrCategoryExists
+		}
+		return fmt.Errorf("error setting category parent: %w", err)
+	}
+	return tx.Commit()
+}
+
+// lockEventCategories locks every category of the event categoryID belongs
+// to, in id order, until the transaction ends.
+func lockEventCategories(ctx context.Context, tx *sql.Tx, categoryID string) error {
+	query := sq.Select("id").
+		From(TableCategories).
+		Where(sq.Expr("event_id = (SELECT event_id FROM "+TableCategories+" WHERE id = ?)", categoryID)).
+		OrderBy("id ASC").
+		Suffix("FOR UPDATE").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building lock categories query: %w", err)
+	}
+	rows, err := tx.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return fmt.Errorf("error locking event categories: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+	}
+	return rows.Err()
+}
+
+// checkNotDescendant returns ErrCategoryCycle when parentID is categoryID or
+// one of its subcategories.
+func checkNotDescendant(ctx context.Context, q queryer, categoryID, parentID string) error {
+	query := fmt.Sprintf(`WITH RECURSIVE subtree AS (
+			SELECT id FROM %[1]s WHERE id = $1
+			UNION
+			SELECT c.id FROM %[1]s c JOIN subtree s ON c.parent_id = s.id
+		)
+		SELECT EXISTS (SELECT 1 FROM subtree WHERE id = $2)`, TableCategories)
+
+	var cycle bool
+	if err := q.QueryRowContext(ctx, query, categoryID, parentID).Scan(&cycle); err != nil {
+		return fmt.Errorf("error checking category nesting: %w", err)
+	}
+	if cycle {
+		return ErrCategoryCycle
+	}
+	return nil
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
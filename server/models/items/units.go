@@ -0,0 +1,191 @@
+package items
+
+import (
+	"errors"
+	"fmt"
+)
+
+// UNITS OF MEASURE IMPLEMENTATION
+
+const (
+	UnitGram       = "g"
+	UnitKilogram   = "kg"
+	UnitPound      = "lb"
+	UnitMillilitre = "ml"
+	UnitLitre      = "l"
+)
+
+var ErrUnknownUnit = errors.New("unknown unit, weights use g, kg or lb and volumes ml or l")
+
+var gramsPerUnit = map[string]float64{
+	UnitGram:     1,
+	UnitKilogram: 1000,
+	UnitPound:    453.59237,
+}
+
+var millilitresPerUnit = map[string]float64{
+	UnitMillilitre: 1,
+	UnitLitre:      1000,
+}
+
+// Measure is an amount in a given unit, e.g. {"value": 2.5, "unit": "kg"}.
+type Measure struct {
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit"`
+}
+
+func ConvertWeight(value float64, from, to string) (float64, error) {
+	return convert(gramsPerUnit, value, from, to)
+}
+
+func ConvertVolume(value float64, from, to string) (float64, error) {
+	return convert(millilitresPerUnit, value, from, to)
+}
+
+func convert(factors map[string]float64, value float64, from, to string) (float64, error) {
+	f, ok := factors[from]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownUnit, from)
+	}
+	t, ok := factors[to]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownUnit, to)
+	}
+	return value * f / t, nil
+}
+
+// ValidWeightUnit and ValidVolumeUnit report whether unit can be used for
+// the given kind of measure.
+func ValidWeightUnit(unit string) bool {
+	_, ok := gramsPerUnit[unit]
+	return ok
+}
+
+func ValidVolumeUnit(unit string) bool {
+	_, ok := millilitresPerUnit[unit]
+	return ok
+}
+
+// Grams and Millilitres convert a measure to the units weights and volumes
+// are stored in.
+func (m Measure) Grams() (float64, error) {
+	return ConvertWeight(m.Value, m.Unit, UnitGram)
+}
+
+func (m Measure) Millilitres() (float64, error) {
+	return ConvertVolume(m.Value, m.Unit, UnitMillilitre)
+}
+
+// WeightIn and VolumeIn express a stored value in unit, returning nil when
+// there is no value or the unit is unknown.
+func WeightIn(grams *float64, unit string) *Measure {
+	if grams == nil {
+		return nil
+	}
+	v, err := ConvertWeight(*grams, UnitGram, unit)
+	if err != nil {
+		return nil
+	}
+	return &Measure{Value: v, Unit: unit}
+}
+
+func VolumeIn(ml *float64, unit string) *Measure {
+	if ml == nil {
+		return nil
+	}
+	v, err := ConvertVolume(*ml, UnitMillilitre, unit)
+	if err != nil {
+		return nil
+	}
+	return &Measure{Value: v, Unit: unit}
+}
+
+// applyMeasures converts the Weight and Volume given by a client into the
+// stored grams and millilitres, remembering the unit they were given in.
+func (itm *Item) applyMeasures() error {
+	if itm.Weight != nil {
+		grams, err := itm.Weight.Grams()
+		if err != nil {
+			return err
+		}
+		itm.WeightG, itm.WeightUnit = &grams, itm.Weight.Unit
+	}
+	if itm.Volume != nil {
+		ml, err := itm.Volume.Millilitres()
+		if err != nil {
+			return err
+		}
+		itm.VolumeML, itm.VolumeUnit = &ml, itm.Volume.Unit
+	}
+	if itm.WeightUnit == "" {
+		itm.WeightUnit = UnitGram
+	}
+	if itm.VolumeUnit == "" {
+		itm.VolumeUnit = UnitMillilitre
+	}
+	if !ValidWeightUnit(itm.WeightUnit) || !ValidVolumeUnit(itm.VolumeUnit) {
+		return ErrUnknownUnit
+	}
+	return nil
+}
+
+// fillMeasures sets Weight and Volume from the stored values, expressed in
+// the item's preferred units.
+func (itm *Item) fillMeasures() {
+	itm.Weight = WeightIn(itm.WeightG, itm.WeightUnit)
+	itm.Volume = VolumeIn(itm.VolumeML, itm.VolumeUnit)
+}
+
+// measureUpdates rewrites "weight" and "volume" measures in an EditItem
+// update map into the stored columns; a null measure clears the value.
+func measureUpdates(updates map[string]interface{}) error {
+	if raw, ok := updates["weight"]; ok {
+		delete(updates, "weight")
+		m, err := measureFromJSON("weight", raw)
+		if err != nil {
+			return err
+		}
+		if m == nil {
+			updates["weight_g"] = nil
+		} else {
+			grams, err := ConvertWeight(m.Value, m.Unit, UnitGram)
+			if err != nil {
+				return err
+			}
+			updates["weight_g"], updates["weight_unit"] = grams, m.Unit
+		}
+	}
+	if raw, ok := updates["volume"]; ok {
+		delete(updates, "volume")
+		m, err := measureFromJSON("volume", raw)
+		if err != nil {
+			return err
+		}
+		if m == nil {
+			updates["volume_ml"] = nil
+		} else {
+			ml, err := ConvertVolume(m.Value, m.Unit, UnitMillilitre)
+			if err != nil {
+				return err
+			}
+			updates["volume_ml"], updates["volume_unit"] = ml, m.Unit
+		}
+	}
+	return nil
+}
+
+func measureFromJSON(field string, raw interface{}) (*Measure, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	obj, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be an object with value and unit", field)
+	}
+	value, ok := obj["value"].(float64)
+	if !ok || value < 0 {
+		return nil, fmt.Errorf("%s value must be a non-negative number", field)
+	}
+	unit, _ := obj["unit"].(string)
+	return &Measure{Value: value, Unit: unit}, nil
+}
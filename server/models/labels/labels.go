@@ -0,0 +1,139 @@
+package labels
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/sunnymotiani/PackTrack/server/models/containers"
+	"github.com/sunnymotiani/PackTrack/server/models/items"
+)
+
+const (
+	KindItem      = "item"
+	KindContainer = "container"
+)
+
+var (
+	ErrInvalidKind = errors.New("kind must be item or container")
+	ErrInvalidCode = errors.New("code is not a PackTrack item or container link")
+	ErrNotFound    = errors.New("item or container not found")
+)
+
+// LabelsService prints QR labels linking to items and containers and
+// resolves the links when they are scanned.
+type LabelsService struct {
+	DB    *sql.DB
+	Items *items.ItemsService
+	// BaseURL is where the app is served, e.g. "https://packtrack.example";
+	// label links point below it.
+	BaseURL string
+}
+
+// Label is what is printed on one sticker.
+type Label struct {
+	Kind     string `json:"kind"`
+	ID       string `json:"id"`
+	EventID  string `json:"event_id"`
+	Title    string `json:"title"`
+	Subtitle string `json:"subtitle"`
+	Link     string `json:"link"`
+}
+
+// Link returns the deep link a label for the given item or container encodes.
+func (ls *LabelsService) Link(kind, eventID, id string) string {
+	return fmt.Sprintf("%s/events/%s/%ss/%s", strings.TrimRight(ls.BaseURL, "/"),
+		url.PathEscape(eventID), kind, url.PathEscape(id))
+}
+
+// ParseLink extracts the kind, event and id from a scanned deep link. Only
+// the path is looked at, so labels keep working if the app moves.
+func ParseLink(code string) (kind, eventID, id string, err error) {
+	u, err := url.Parse(strings.TrimSpace(code))
+	if err != nil {
+		return "", "", "", ErrInvalidCode
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i := 0; i+3 < len(parts); i++ {
+		if parts[i] != "events" {
+			continue
+		}
+		switch parts[i+2] {
+		case KindItem + "s":
+			return KindItem, parts[i+1], parts[i+3], nil
+		case KindContainer + "s":
+			return KindContainer, parts[i+1], parts[i+3], nil
+		}
+	}
+	return "", "", "", ErrInvalidCode
+}
+
+// GetLabels returns the labels of an event's items or containers, or of
+// both when kind is empty. When ids is not empty only those are labelled.
+func (ls *LabelsService) GetLabels(ctx context.Context, eventID, kind string, ids []string) ([]Label, error) {
+	if kind != "" && kind != KindItem && kind != KindContainer {
+		return nil, ErrInvalidKind
+	}
+	labels := []Label{}
+	if kind == "" || kind == KindContainer {
+		query := sq.Select("id", "name", "type").
+			From(containers.TableContainers).
+			Where(sq.Eq{"event_id": eventID}).
+			OrderBy("name ASC", "id ASC")
+		if len(ids) > 0 {
+			query = query.Where(sq.Eq{"id": ids})
+		}
+		err := ls.scanLabels(ctx, query, func(id, title, subtitle string) {
+			labels = append(labels, Label{Kind: KindContainer, ID: id, EventID: eventID, Title: title,
+				Subtitle: subtitle, Link: ls.Link(KindContainer, eventID, id)})
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if kind == "" || kind == KindItem {
+		query := sq.Select("i.id", "i.name", "c.name").
+			From(items.TableItems+" i").
+			Join(items.TableCategories+" c ON c.id = i.category_id").
+			Where(sq.Eq{"c.event_id": eventID}).
+			OrderBy("c.position ASC", "c.id ASC", "i.position ASC", "i.id ASC")
+		if len(ids) > 0 {
+			query = query.Where(sq.Eq{"i.id": ids})
+		}
+		err := ls.scanLabels(ctx, query, func(id, title, subtitle string) {
+			labels = append(labels, Label{Kind: KindItem, ID: id, EventID: eventID, Title: title,
+				Subtitle: subtitle, Link: ls.Link(KindItem, eventID, id)})
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return labels, nil
+}
+
+func (ls *LabelsService) scanLabels(ctx context.Context, query sq.SelectBuilder, add func(id, title, subtitle string)) error {
+	sqlStr, args, err := query.PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return fmt.Errorf("error building labels query: %w", err)
+	}
+	rows, err := ls.DB.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return fmt.Errorf("error querying labels: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, title, subtitle string
+		if err := rows.Scan(&id, &title, &subtitle); err != nil {
+			return fmt.Errorf("error scanning label row: %w", err)
+		}
+		add(id, title, subtitle)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows iteration error: %w", err)
+	}
+	return nil
+}
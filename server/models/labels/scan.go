@@ -0,0 +1,183 @@
+package labels
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/sunnymotiani/PackTrack/server/models/containers"
+	"github.com/sunnymotiani/PackTrack/server/models/events"
+	"github.com/sunnymotiani/PackTrack/server/models/items"
+)
+
+// SCAN IMPLEMENTATION
+
+var (
+	ErrInvalidStatus = errors.New("status must be to_pack, packed or delivered")
+	ErrCannotScan    = errors.New("viewers cannot change items by scanning")
+)
+
+type ScannedItem struct {
+	ItemID    string `json:"item_id"`
+	Name      string `json:"name"`
+	OldStatus string `json:"old_status"`
+	Status    string `json:"status"`
+}
+
+// ScanResult tells the scanner what the code was and what changed. Items
+// lists the scanned item or the items in the scanned container.
+type ScanResult struct {
+	Kind    string        `json:"kind"`
+	ID      string        `json:"id"`
+	EventID string        `json:"event_id"`
+	Name    string        `json:"name"`
+	Items   []ScannedItem `json:"items"`
+}
+
+// Scan resolves a scanned code for a member of its event. Scanning an item
+// advances it one step, from to_pack to packed and from packed to delivered,
+// unless status says where to move it. Scanning a container lists its items
+// and, when status is given, moves every one of them there. The scanner must
+// hold or be allowed to manage every item that changes.
+func (ls *LabelsService) Scan(ctx context.Context, code, userID, status string) (*ScanResult, error) {
+	if status != "" && status != items.StatusToPack && status != items.StatusPacked && status != items.StatusDelivered {
+		return nil, ErrInvalidStatus
+	}
+	kind, eventID, id, err := ParseLink(code)
+	if err != nil {
+		return nil, err
+	}
+	if err := ls.checkScanner(ctx, eventID, userID); err != nil {
+		return nil, err
+	}
+
+	result := &ScanResult{Kind: kind, ID: id, EventID: eventID, Items: []ScannedItem{}}
+	if kind == KindItem {
+		if err := ls.scannedItems(ctx, sq.Eq{"i.id": id, "c.event_id": eventID}, &result.Items); err != nil {
+			return nil, err
+		}
+		if len(result.Items) == 0 {
+			return nil, ErrNotFound
+		}
+		result.Name = result.Items[0].Name
+		if status == "" {
+			status = nextStatus(result.Items[0].Status)
+		}
+	} else {
+		query := sq.Select("name").
+			From(containers.TableContainers).
+			Where(sq.Eq{"id": id, "event_id": eventID}).
+			PlaceholderFormat(sq.Dollar)
+		sqlStr, args, err := query.ToSql()
+		if err != nil {
+			return nil, fmt.Errorf("error building container query: %w", err)
+		}
+		err = ls.DB.QueryRowContext(ctx, sqlStr, args...).Scan(&result.Name)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error fetching container: %w", err)
+		}
+		if err := ls.scannedItems(ctx, sq.Eq{"i.container_id": id}, &result.Items); err != nil {
+			return nil, err
+		}
+	}
+
+	if status == "" {
+		return result, nil
+	}
+	var changed []string
+	for _, item := range result.Items {
+		if item.Status != status {
+			changed = append(changed, item.ItemID)
+		}
+	}
+	if len(changed) == 0 {
+		return result, nil
+	}
+	for _, itemID := range changed {
+		if err := ls.Items.CanActOnItem(ctx, itemID, userID); err != nil {
+			return nil, err
+		}
+	}
+	// A container moves as a whole, so a failure leaves none of its items
+	// changed.
+	if err := ls.Items.UpdateItemsStatus(ctx, changed, status, userID); err != nil {
+		return nil, err
+	}
+	for i := range result.Items {
+		result.Items[i].Status = status
+	}
+	return result, nil
+}
+
+// nextStatus is the status an item moves to when scanned, or "" when it has
+// already been delivered.
+func nextStatus(status string) string {
+	switch status {
+	case items.StatusToPack:
+		return items.StatusPacked
+	case items.StatusPacked:
+		return items.StatusDelivered
+	}
+	return ""
+}
+
+func (ls *LabelsService) scannedItems(ctx context.Context, where sq.Eq, scanned *[]ScannedItem) error {
+	query := sq.Select("i.id", "i.name", "i.status").
+		From(items.TableItems+" i").
+		Join(items.TableCategories+" c ON c.id = i.category_id").
+		Where(where).
+		OrderBy("i.name ASC", "i.id ASC").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building scanned items query: %w", err)
+	}
+	rows, err := ls.DB.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return fmt.Errorf("error querying scanned items: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s ScannedItem
+		if err := rows.Scan(&s.ItemID, &s.Name, &s.Status); err != nil {
+			return fmt.Errorf("error scanning item row: %w", err)
+		}
+		s.OldStatus = s.Status
+		*scanned = append(*scanned, s)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows iteration error: %w", err)
+	}
+	return nil
+}
+
+// checkScanner makes sure the scanning user may change the event's items.
+func (ls *LabelsService) checkScanner(ctx context.Context, eventID, userID string) error {
+	query := sq.Select("role").
+		From(events.TableEventMemberships).
+		Where(sq.Eq{"event_id": eventID, "user_id": userID}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building member role query: %w", err)
+	}
+	var role string
+	err = ls.DB.QueryRowContext(ctx, sqlStr, args...).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return items.ErrNotEventMember
+	}
+	if err != nil {
+		return fmt.Errorf("error fetching member role: %w", err)
+	}
+	if role == "viewer" {
+		return ErrCannotScan
+	}
+	return nil
+}
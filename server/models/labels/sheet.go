@@ -0,0 +1,78 @@
+package labels
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/skip2/go-qrcode"
+)
+
+// LABEL SHEET IMPLEMENTATION
+
+// Sheet layout in millimetres: A4 with 3 x 8 labels of 70 x 37, the common
+// adhesive sheet size, with no gaps between labels.
+const (
+	sheetColumns = 3
+	sheetRows    = 8
+	labelWidth   = 70.0
+	labelHeight  = 37.0
+	sheetTop     = (297 - sheetRows*labelHeight) / 2
+	labelPadding = 3.5
+	qrSize       = labelHeight - 2*labelPadding
+)
+
+// WriteLabelSheet renders the labels as a printable PDF, filling pages of
+// label sheets in order. Each label carries a QR code of its link, its title
+// and its subtitle.
+func WriteLabelSheet(w io.Writer, labels []Label) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.SetMargins(0, 0, 0)
+	pdf.SetAutoPageBreak(false, 0)
+	textX := labelPadding + qrSize + 2
+	textWidth := labelWidth - textX - labelPadding
+
+	if len(labels) == 0 {
+		pdf.AddPage()
+	}
+	for i, l := range labels {
+		slot := i % (sheetColumns * sheetRows)
+		if slot == 0 {
+			pdf.AddPage()
+		}
+		x := float64(slot%sheetColumns) * labelWidth
+		y := sheetTop + float64(slot/sheetColumns)*labelHeight
+
+		png, err := qrcode.Encode(l.Link, qrcode.Medium, 256)
+		if err != nil {
+			return fmt.Errorf("error encoding qr code for %s: %w", l.ID, err)
+		}
+		name := fmt.Sprintf("qr-%d", i)
+		pdf.RegisterImageOptionsReader(name, gofpdf.ImageOptions{ImageType: "PNG"}, bytes.NewReader(png))
+		pdf.ImageOptions(name, x+labelPadding, y+labelPadding, qrSize, qrSize, false, gofpdf.ImageOptions{}, 0, "")
+
+		pdf.SetFont("Helvetica", "B", 11)
+		titleLines := pdf.SplitLines([]byte(tr(l.Title)), textWidth)
+		if len(titleLines) > 3 {
+			titleLines = titleLines[:3]
+		}
+		pdf.SetXY(x+textX, y+labelPadding+1)
+		for _, line := range titleLines {
+			pdf.SetX(x + textX)
+			pdf.CellFormat(textWidth, 5, string(line), "", 2, "L", false, 0, "")
+		}
+		pdf.SetFont("Helvetica", "", 8)
+		pdf.SetX(x + textX)
+		pdf.CellFormat(textWidth, 4, tr(l.Subtitle), "", 2, "L", false, 0, "")
+		pdf.SetXY(x+textX, y+labelHeight-labelPadding-4)
+		pdf.SetTextColor(120, 120, 120)
+		pdf.CellFormat(textWidth, 4, l.Kind, "", 0, "L", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+	}
+	if err := pdf.Error(); err != nil {
+		return fmt.Errorf("error rendering label sheet: %w", err)
+	}
+	return pdf.Output(w)
+}
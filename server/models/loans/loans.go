@@ -0,0 +1,350 @@
+package loans
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/sunnymotiani/PackTrack/server/models/events"
+	"github.com/sunnymotiani/PackTrack/server/models/gear"
+	"github.com/sunnymotiani/PackTrack/server/models/items"
+	"github.com/sunnymotiani/PackTrack/server/models/users"
+)
+
+const TableLoans = "loans"
+
+const (
+	StateLent      = "lent"
+	StateReturned  = "returned"
+	StateLost      = "lost"
+	StateCancelled = "cancelled"
+)
+
+var (
+	ErrInvalidState   = errors.New("state must be returned, lost or cancelled")
+	ErrLoanNotFound   = errors.New("loan not found")
+	ErrLoanClosed     = errors.New("loan is already closed")
+	ErrSelfLoan       = errors.New("lender and borrower must be different users")
+	ErrNotGearOwner   = errors.New("only the owner of the gear can lend it")
+	ErrGearOnLoan     = errors.New("gear is already out on another loan")
+	ErrNotEventMember = errors.New("lender and borrower must both be members of the event")
+	ErrNoSuchItem     = errors.New("item or gear not found")
+	ErrNoDescription  = errors.New("a loan without item or gear needs a description")
+	ErrItemNotInEvent = errors.New("the lent item does not belong to the loan's event")
+)
+
+var closingStates = map[string]bool{
+	StateReturned:  true,
+	StateLost:      true,
+	StateCancelled: true,
+}
+
+type LoansService struct {
+	DB *sql.DB
+}
+
+// Loan is something a lender handed to a borrower. It may point at the gear
+// being lent, the event item it covers and the event it is lent for. A loan
+// is overdue while it is still lent after its due date.
+type Loan struct {
+	ID           string     `json:"id"`
+	LenderID     string     `json:"lender_id"`
+	LenderName   string     `json:"lender_name,omitempty"`
+	BorrowerID   string     `json:"borrower_id"`
+	BorrowerName string     `json:"borrower_name,omitempty"`
+	EventID      *string    `json:"event_id,omitempty"`
+	ItemID       *string    `json:"item_id,omitempty"`
+	GearID       *string    `json:"gear_id,omitempty"`
+	Description  string     `json:"description"`
+	State        string     `json:"state"`
+	LentAt       time.Time  `json:"lent_at"`
+	DueAt        *time.Time `json:"due_at,omitempty"`
+	ClosedAt     *time.Time `json:"closed_at,omitempty"`
+	Notes        *string    `json:"notes,omitempty"`
+	Overdue      bool       `json:"overdue"`
+}
+
+// UserLoans is the ledger of one user: what they lent and what they borrowed.
+type UserLoans struct {
+	Lent     []Loan `json:"lent"`
+	Borrowed []Loan `json:"borrowed"`
+}
+
+// CreateLoan records a new loan. An item loan picks up the item's event and
+// the gear linked to it; gear can only be lent by its owner and only while it
+// is not out on another loan. When an event is known both parties must be
+// members of it. The description defaults to the gear or item name.
+func (ls *LoansService) CreateLoan(ctx context.Context, l *Loan) error {
+	if l.LenderID == l.BorrowerID {
+		return ErrSelfLoan
+	}
+
+	tx, err := ls.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if l.ItemID != nil {
+		if err := fillFromItem(ctx, tx, l); err != nil {
+			return err
+		}
+	}
+	if l.GearID != nil {
+		if err := checkGearLendable(ctx, tx, l); err != nil {
+			return err
+		}
+	}
+	if l.Description == "" {
+		return ErrNoDescription
+	}
+	if l.EventID != nil {
+		if err := checkEventMembers(ctx, tx, *l.EventID, l.LenderID, l.BorrowerID); err != nil {
+			return err
+		}
+	}
+
+	l.ID = uuid.NewString()
+	l.State = StateLent
+	l.LentAt = time.Now()
+	l.ClosedAt = nil
+	query := sq.Insert(TableLoans).
+		Columns("id", "lender_id", "borrower_id", "event_id", "item_id", "gear_id", "description",
+			"state", "lent_at", "due_at", "notes").
+		Values(l.ID, l.LenderID, l.BorrowerID, l.EventID, l.ItemID, l.GearID, l.Description,
+			l.State, l.LentAt, l.DueAt, l.Notes).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building create loan query: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("error inserting loan: %w", err)
+	}
+	l.Overdue = l.DueAt != nil && l.DueAt.Before(l.LentAt)
+	return tx.Commit()
+}
+
+// CloseLoan ends an open loan as returned, lost or cancelled.
+func (ls *LoansService) CloseLoan(ctx context.Context, loanID, state string, notes *string) error {
+	if !closingStates[state] {
+		return ErrInvalidState
+	}
+	query := sq.Update(TableLoans).
+		Set("state", state).
+		Set("closed_at", time.Now()).
+		Where(sq.Eq{"id": loanID}).
+		PlaceholderFormat(sq.Dollar)
+	if notes != nil {
+		query = query.Set("notes", *notes)
+	}
+	return ls.updateOpenLoan(ctx, loanID, query)
+}
+
+// SetDueDate changes when an open loan is due back; nil removes the due date.
+func (ls *LoansService) SetDueDate(ctx context.Context, loanID string, dueAt *time.Time) error {
+	query := sq.Update(TableLoans).
+		Set("due_at", dueAt).
+		Where(sq.Eq{"id": loanID}).
+		PlaceholderFormat(sq.Dollar)
+	return ls.updateOpenLoan(ctx, loanID, query)
+}
+
+func (ls *LoansService) updateOpenLoan(ctx context.Context, loanID string, query sq.UpdateBuilder) error {
+	sqlStr, args, err := query.Where(sq.Eq{"state": StateLent}).ToSql()
+	if err != nil {
+		return fmt.Errorf("error building update loan query: %w", err)
+	}
+	res, err := ls.DB.ExecContext(ctx, sqlStr, args...)
+	if err != nil {
+		return fmt.Errorf("error updating loan: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		return nil
+	}
+
+	stateQuery := sq.Select("state").From(TableLoans).Where(sq.Eq{"id": loanID}).PlaceholderFormat(sq.Dollar)
+	stateSQL, stateArgs, err := stateQuery.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building loan state query: %w", err)
+	}
+	var state string
+	err = ls.DB.QueryRowContext(ctx, stateSQL, stateArgs...).Scan(&state)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrLoanNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("error fetching loan: %w", err)
+	}
+	return ErrLoanClosed
+}
+
+// GetUserLoans returns the loans a user is lender or borrower of, newest
+// first. Closed loans are left out unless includeClosed is set.
+func (ls *LoansService) GetUserLoans(ctx context.Context, userID string, includeClosed bool) (*UserLoans, error) {
+	where := sq.And{sq.Or{sq.Eq{"l.lender_id": userID}, sq.Eq{"l.borrower_id": userID}}}
+	if !includeClosed {
+		where = append(where, sq.Eq{"l.state": StateLent})
+	}
+	list, err := ls.queryLoans(ctx, where)
+	if err != nil {
+		return nil, err
+	}
+
+	ledger := &UserLoans{Lent: []Loan{}, Borrowed: []Loan{}}
+	for _, l := range list {
+		if l.LenderID == userID {
+			ledger.Lent = append(ledger.Lent, l)
+		} else {
+			ledger.Borrowed = append(ledger.Borrowed, l)
+		}
+	}
+	return ledger, nil
+}
+
+// GetOverdueLoans returns open loans past their due date, for one event when
+// eventID is set or across all events otherwise.
+func (ls *LoansService) GetOverdueLoans(ctx context.Context, eventID string) ([]Loan, error) {
+	where := sq.And{sq.Eq{"l.state": StateLent}, sq.Expr("l.due_at < now()")}
+	if eventID != "" {
+		where = append(where, sq.Eq{"l.event_id": eventID})
+	}
+	return ls.queryLoans(ctx, where)
+}
+
+func (ls *LoansService) queryLoans(ctx context.Context, where sq.Sqlizer) ([]Loan, error) {
+	query := sq.Select("l.id", "l.lender_id", "lu.name", "l.borrower_id", "bu.name", "l.event_id", "l.item_id",
+		"l.gear_id", "l.description", "l.state", "l.lent_at", "l.due_at", "l.closed_at", "l.notes",
+		fmt.Sprintf("(l.state = '%s' AND l.due_at < now())", StateLent)).
+		From(TableLoans+" l").
+		Join(users.TableUsers+" lu ON lu.id = l.lender_id").
+		Join(users.TableUsers+" bu ON bu.id = l.borrower_id").
+		Where(where).
+		OrderBy("l.lent_at DESC", "l.id ASC").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building loans query: %w", err)
+	}
+	rows, err := ls.DB.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying loans: %w", err)
+	}
+	defer rows.Close()
+
+	list := []Loan{}
+	for rows.Next() {
+		var l Loan
+		var overdue sql.NullBool
+		if err := rows.Scan(&l.ID, &l.LenderID, &l.LenderName, &l.BorrowerID, &l.BorrowerName, &l.EventID,
+			&l.ItemID, &l.GearID, &l.Description, &l.State, &l.LentAt, &l.DueAt, &l.ClosedAt, &l.Notes,
+			&overdue); err != nil {
+			return nil, fmt.Errorf("error scanning loan row: %w", err)
+		}
+		l.Overdue = overdue.Bool
+		list = append(list, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return list, nil
+}
+
+// fillFromItem takes the event, linked gear and name of the item being lent.
+// An event given with the item must be the item's own.
+func fillFromItem(ctx context.Context, tx *sql.Tx, l *Loan) error {
+	query := sq.Select("c.event_id", "i.gear_id", "i.name").
+		From(items.TableItems + " i").
+		Join(items.TableCategories + " c ON c.id = i.category_id").
+		Where(sq.Eq{"i.id": *l.ItemID}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building loan item query: %w", err)
+	}
+	var eventID string
+	var gearID *string
+	var name string
+	err = tx.QueryRowContext(ctx, sqlStr, args...).Scan(&eventID, &gearID, &name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNoSuchItem
+	}
+	if err != nil {
+		return fmt.Errorf("error fetching loan item: %w", err)
+	}
+	if l.EventID == nil {
+		l.EventID = &eventID
+	} else if *l.EventID != eventID {
+		return ErrItemNotInEvent
+	}
+	if l.GearID == nil {
+		l.GearID = gearID
+	}
+	if l.Description == "" {
+		l.Description = name
+	}
+	return nil
+}
+
+// checkGearLendable locks the gear being lent so two loans cannot be opened
+// for it at once.
+func checkGearLendable(ctx context.Context, tx *sql.Tx, l *Loan) error {
+	query := sq.Select("g.owner_id", "g.name").
+		Column(sq.Expr(fmt.Sprintf("EXISTS (SELECT 1 FROM %s ol WHERE ol.gear_id = g.id AND ol.state = ?)", TableLoans),
+			StateLent)).
+		From(gear.TableGear + " g").
+		Where(sq.Eq{"g.id": *l.GearID}).
+		Suffix("FOR UPDATE OF g").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building loan gear query: %w", err)
+	}
+	var ownerID, name string
+	var onLoan bool
+	err = tx.QueryRowContext(ctx, sqlStr, args...).Scan(&ownerID, &name, &onLoan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNoSuchItem
+	}
+	if err != nil {
+		return fmt.Errorf("error fetching loan gear: %w", err)
+	}
+	if ownerID != l.LenderID {
+		return ErrNotGearOwner
+	}
+	if onLoan {
+		return ErrGearOnLoan
+	}
+	if l.Description == "" {
+		l.Description = name
+	}
+	return nil
+}
+
+func checkEventMembers(ctx context.Context, tx *sql.Tx, eventID string, userIDs ...string) error {
+	query := sq.Select("COUNT(DISTINCT user_id)").
+		From(events.TableEventMemberships).
+		Where(sq.Eq{"event_id": eventID, "user_id": userIDs}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building membership check query: %w", err)
+	}
+	var count int
+	if err := tx.QueryRowContext(ctx, sqlStr, args...).Scan(&count); err != nil {
+		return fmt.Errorf("error checking event membership: %w", err)
+	}
+	if count != len(userIDs) {
+		return ErrNotEventMember
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE items
+    ADD COLUMN IF NOT EXISTS packed_quantity INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS delivered_quantity INTEGER NOT NULL DEFAULT 0;
+
+UPDATE items SET packed_quantity = COALESCE(quantity, 0) WHERE status IN ('packed', 'delivered');
+UPDATE items SET delivered_quantity = COALESCE(quantity, 0) WHERE status = 'delivered';
+
+ALTER TABLE items ADD CONSTRAINT items_progress_check
+    CHECK (delivered_quantity >= 0 AND delivered_quantity <= packed_quantity AND packed_quantity <= quantity);
+
+-- Quantity deltas recorded alongside each status change
+ALTER TABLE item_status_history
+    ADD COLUMN IF NOT EXISTS packed_delta INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS delivered_delta INTEGER NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE item_status_history
+    DROP COLUMN IF EXISTS delivered_delta,
+    DROP COLUMN IF EXISTS packed_delta;
+ALTER TABLE items DROP CONSTRAINT IF EXISTS items_progress_check;
+ALTER TABLE items
+    DROP COLUMN IF EXISTS delivered_quantity,
+    DROP COLUMN IF EXISTS packed_quantity;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Per-assignee share of an item; items.assigned_to mirrors the assignee when there is exactly one
+CREATE TABLE IF NOT EXISTS item_assignments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    item_id UUID REFERENCES items(id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    packed_quantity INTEGER NOT NULL DEFAULT 0,
+    delivered_quantity INTEGER NOT NULL DEFAULT 0,
+    status TEXT CHECK (status IN ('to_pack', 'packed', 'delivered')) DEFAULT 'to_pack',
+    created_at TIMESTAMP DEFAULT now(),
+    UNIQUE(item_id, user_id),
+    CHECK (delivered_quantity >= 0 AND delivered_quantity <= packed_quantity AND packed_quantity <= quantity)
+);
+
+INSERT INTO item_assignments (item_id, user_id, quantity, packed_quantity, delivered_quantity, status)
+SELECT id, assigned_to, quantity, packed_quantity, delivered_quantity, status
+FROM items
+WHERE assigned_to IS NOT NULL AND quantity > 0;
+
+-- An item of quantity 0 has nothing to hold, so it has no assignee either
+UPDATE items SET assigned_to = NULL WHERE assigned_to IS NOT NULL AND COALESCE(quantity, 0) = 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS item_assignments;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+-- A member asking another member to take over an item, optionally in exchange for one of theirs
+CREATE TABLE IF NOT EXISTS item_swap_requests (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    item_id UUID REFERENCES items(id) ON DELETE CASCADE,
+    requester_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    target_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    counter_item_id UUID REFERENCES items(id) ON DELETE CASCADE,
+    status TEXT CHECK (status IN ('pending', 'accepted', 'declined', 'cancelled')) DEFAULT 'pending',
+    created_at TIMESTAMP DEFAULT now(),
+    resolved_at TIMESTAMP
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS item_swap_requests;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Per-unit weight of an item, used to balance auto-assignment by weight
+ALTER TABLE items
+    ADD COLUMN IF NOT EXISTS weight_g DOUBLE PRECISION CHECK (weight_g >= 0);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE items
+    DROP COLUMN IF EXISTS weight_g;
+-- +goose StatementEnd
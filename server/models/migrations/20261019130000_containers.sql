@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Bags, boxes and vehicles items are loaded into
+CREATE TABLE IF NOT EXISTS containers (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    event_id UUID REFERENCES events(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    type TEXT CHECK (type IN ('bag', 'box', 'vehicle', 'other')) NOT NULL DEFAULT 'other',
+    max_weight_g DOUBLE PRECISION CHECK (max_weight_g >= 0),
+    max_volume_ml DOUBLE PRECISION CHECK (max_volume_ml >= 0),
+    owner_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT now(),
+    UNIQUE(event_id, name)
+);
+
+-- Per-unit volume of an item and the container it is loaded into
+ALTER TABLE items
+    ADD COLUMN IF NOT EXISTS volume_ml DOUBLE PRECISION CHECK (volume_ml >= 0),
+    ADD COLUMN IF NOT EXISTS container_id UUID REFERENCES containers(id) ON DELETE SET NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE items
+    DROP COLUMN IF EXISTS container_id,
+    DROP COLUMN IF EXISTS volume_ml;
+DROP TABLE IF EXISTS containers;
+-- +goose StatementEnd
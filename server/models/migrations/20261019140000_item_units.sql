@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Units weights and volumes are entered and shown in; values are stored in grams and millilitres
+ALTER TABLE items
+    ADD COLUMN IF NOT EXISTS weight_unit TEXT CHECK (weight_unit IN ('g', 'kg', 'lb')) DEFAULT 'g',
+    ADD COLUMN IF NOT EXISTS volume_unit TEXT CHECK (volume_unit IN ('ml', 'l')) DEFAULT 'ml';
+
+ALTER TABLE template_items
+    ADD COLUMN IF NOT EXISTS weight_g DOUBLE PRECISION CHECK (weight_g >= 0),
+    ADD COLUMN IF NOT EXISTS volume_ml DOUBLE PRECISION CHECK (volume_ml >= 0),
+    ADD COLUMN IF NOT EXISTS weight_unit TEXT CHECK (weight_unit IN ('g', 'kg', 'lb')) DEFAULT 'g',
+    ADD COLUMN IF NOT EXISTS volume_unit TEXT CHECK (volume_unit IN ('ml', 'l')) DEFAULT 'ml';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE template_items
+    DROP COLUMN IF EXISTS volume_unit,
+    DROP COLUMN IF EXISTS weight_unit,
+    DROP COLUMN IF EXISTS volume_ml,
+    DROP COLUMN IF EXISTS weight_g;
+ALTER TABLE items
+    DROP COLUMN IF EXISTS volume_unit,
+    DROP COLUMN IF EXISTS weight_unit;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Money spent for an event, optionally the purchase of one item. Amounts are in cents.
+CREATE TABLE IF NOT EXISTS expenses (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    event_id UUID REFERENCES events(id) ON DELETE CASCADE,
+    item_id UUID UNIQUE REFERENCES items(id) ON DELETE SET NULL,
+    description TEXT NOT NULL,
+    amount_cents BIGINT NOT NULL CHECK (amount_cents >= 0),
+    paid_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    split_type TEXT CHECK (split_type IN ('equal', 'shares')) NOT NULL DEFAULT 'equal',
+    created_at TIMESTAMP DEFAULT now()
+);
+
+-- With an equal split a row with 0 shares excludes a member; with a shares
+-- split only the listed members pay, in proportion to their shares.
+CREATE TABLE IF NOT EXISTS expense_splits (
+    expense_id UUID REFERENCES expenses(id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    shares INTEGER NOT NULL CHECK (shares >= 0),
+    PRIMARY KEY (expense_id, user_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS expense_splits;
+DROP TABLE IF EXISTS expenses;
+-- +goose StatementEnd
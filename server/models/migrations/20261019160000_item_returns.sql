@@ -0,0 +1,42 @@
+-- +goose Up
+-- +goose StatementBegin
+-- What came back of the delivered quantity of an item after the event
+ALTER TABLE items
+    ADD COLUMN IF NOT EXISTS returned_quantity INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS missing_quantity INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS damaged_quantity INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS return_notes TEXT;
+
+ALTER TABLE items ADD CONSTRAINT items_return_check
+    CHECK (returned_quantity >= 0 AND missing_quantity >= 0 AND damaged_quantity >= 0
+        AND returned_quantity + missing_quantity + damaged_quantity <= delivered_quantity);
+
+ALTER TABLE items DROP CONSTRAINT IF EXISTS items_status_check;
+ALTER TABLE items ADD CONSTRAINT items_status_check
+    CHECK (status IN ('to_pack', 'packed', 'delivered', 'returned', 'missing', 'damaged'));
+
+ALTER TABLE item_status_history
+    ADD COLUMN IF NOT EXISTS returned_delta INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS missing_delta INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS damaged_delta INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS notes TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE item_status_history
+    DROP COLUMN IF EXISTS notes,
+    DROP COLUMN IF EXISTS damaged_delta,
+    DROP COLUMN IF EXISTS missing_delta,
+    DROP COLUMN IF EXISTS returned_delta;
+UPDATE items SET status = 'delivered' WHERE status IN ('returned', 'missing', 'damaged');
+ALTER TABLE items DROP CONSTRAINT IF EXISTS items_status_check;
+ALTER TABLE items ADD CONSTRAINT items_status_check
+    CHECK (status IN ('to_pack', 'packed', 'delivered'));
+ALTER TABLE items DROP CONSTRAINT IF EXISTS items_return_check;
+ALTER TABLE items
+    DROP COLUMN IF EXISTS return_notes,
+    DROP COLUMN IF EXISTS damaged_quantity,
+    DROP COLUMN IF EXISTS missing_quantity,
+    DROP COLUMN IF EXISTS returned_quantity;
+-- +goose StatementEnd
@@ -0,0 +1,43 @@
+-- +goose Up
+-- +goose StatementBegin
+-- When an event takes place; gear can only be committed to one of several overlapping events
+ALTER TABLE events
+    ADD COLUMN IF NOT EXISTS starts_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS ends_at TIMESTAMP;
+ALTER TABLE events ADD CONSTRAINT events_dates_check CHECK (ends_at >= starts_at);
+
+-- Gear a member owns and brings to events year after year
+CREATE TABLE IF NOT EXISTS gear (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    owner_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    description TEXT,
+    weight_g DOUBLE PRECISION CHECK (weight_g >= 0),
+    weight_unit TEXT CHECK (weight_unit IN ('g', 'kg', 'lb')) DEFAULT 'g',
+    condition TEXT CHECK (condition IN ('new', 'good', 'worn', 'needs_repair', 'retired')) NOT NULL DEFAULT 'good',
+    notes TEXT,
+    created_at TIMESTAMP DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS gear_photos (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    gear_id UUID REFERENCES gear(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    caption TEXT,
+    created_at TIMESTAMP DEFAULT now()
+);
+
+-- The piece of gear covering an item
+ALTER TABLE items ADD COLUMN IF NOT EXISTS gear_id UUID REFERENCES gear(id) ON DELETE SET NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE items DROP COLUMN IF EXISTS gear_id;
+DROP TABLE IF EXISTS gear_photos;
+DROP TABLE IF EXISTS gear;
+ALTER TABLE events DROP CONSTRAINT IF EXISTS events_dates_check;
+ALTER TABLE events
+    DROP COLUMN IF EXISTS ends_at,
+    DROP COLUMN IF EXISTS starts_at;
+-- +goose StatementEnd
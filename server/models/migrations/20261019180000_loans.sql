@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Gear or items one member lends to another, usually for an event
+CREATE TABLE IF NOT EXISTS loans (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    lender_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    borrower_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    event_id UUID REFERENCES events(id) ON DELETE SET NULL,
+    item_id UUID REFERENCES items(id) ON DELETE SET NULL,
+    gear_id UUID REFERENCES gear(id) ON DELETE SET NULL,
+    description TEXT NOT NULL,
+    state TEXT CHECK (state IN ('lent', 'returned', 'lost', 'cancelled')) NOT NULL DEFAULT 'lent',
+    lent_at TIMESTAMP DEFAULT now(),
+    due_at TIMESTAMP,
+    closed_at TIMESTAMP,
+    notes TEXT,
+    CHECK (lender_id <> borrower_id)
+);
+
+-- A piece of gear can only be out on one loan at a time
+CREATE UNIQUE INDEX IF NOT EXISTS loans_active_gear_idx ON loans (gear_id) WHERE state = 'lent';
+CREATE INDEX IF NOT EXISTS loans_due_idx ON loans (due_at) WHERE state = 'lent';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS loans;
+-- +goose StatementEnd
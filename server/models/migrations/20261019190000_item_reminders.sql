@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE items ADD COLUMN IF NOT EXISTS due_at TIMESTAMP;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS packing_deadline TIMESTAMP;
+
+-- Reminders already delivered, so a retried or rescheduled job is not sent twice
+CREATE TABLE IF NOT EXISTS reminder_deliveries (
+    item_id UUID REFERENCES items(id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    due_at TIMESTAMP NOT NULL,
+    delivered_at TIMESTAMP DEFAULT now(),
+    PRIMARY KEY (item_id, user_id, due_at)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS reminder_deliveries;
+ALTER TABLE events DROP COLUMN IF EXISTS packing_deadline;
+ALTER TABLE items DROP COLUMN IF EXISTS due_at;
+-- +goose StatementEnd
@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS notifications (
+    id UUID PRIMARY KEY,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    event_id UUID REFERENCES events(id) ON DELETE CASCADE,
+    item_id UUID REFERENCES items(id) ON DELETE SET NULL,
+    actor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    type TEXT NOT NULL,
+    message TEXT NOT NULL,
+    data JSONB,
+    -- Set for notifications that may be delivered more than once, e.g. reminders
+    dedupe_key TEXT UNIQUE,
+    read_at TIMESTAMP,
+    emailed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS notifications_user_idx ON notifications (user_id, created_at DESC);
+
+-- Members without a row get every notification and the daily digest
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    assignments BOOLEAN NOT NULL DEFAULT true,
+    status_changes BOOLEAN NOT NULL DEFAULT true,
+    membership BOOLEAN NOT NULL DEFAULT true,
+    invitations BOOLEAN NOT NULL DEFAULT true,
+    reminders BOOLEAN NOT NULL DEFAULT true,
+    email_digest BOOLEAN NOT NULL DEFAULT true
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS notification_preferences;
+DROP TABLE IF EXISTS notifications;
+-- +goose StatementEnd
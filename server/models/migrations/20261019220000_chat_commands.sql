@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+-- A chat channel answers commands for one event
+CREATE TABLE IF NOT EXISTS chat_channels (
+    channel_id TEXT PRIMARY KEY,
+    event_id UUID REFERENCES events(id) ON DELETE CASCADE,
+    linked_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT now()
+);
+
+-- Chat accounts acting as PackTrack users
+CREATE TABLE IF NOT EXISTS chat_users (
+    chat_user_id TEXT PRIMARY KEY,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT now()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS chat_users;
+DROP TABLE IF EXISTS chat_channels;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Templates applied to each event, so event archives can carry them
+CREATE TABLE IF NOT EXISTS template_applications (
+    event_id UUID REFERENCES events(id) ON DELETE CASCADE,
+    template_id UUID REFERENCES templates(id) ON DELETE CASCADE,
+    applied_at TIMESTAMP DEFAULT now(),
+    PRIMARY KEY (event_id, template_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS template_applications;
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Manual order of categories within an event and of items within a category.
+-- Positions are spaced apart so a move only rewrites the moved row.
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS position DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE items ADD COLUMN IF NOT EXISTS position DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+UPDATE categories c SET position = o.n * 1024
+FROM (SELECT id, ROW_NUMBER() OVER (PARTITION BY event_id ORDER BY name, id) AS n FROM categories) o
+WHERE o.id = c.id;
+
+UPDATE items i SET position = o.n * 1024
+FROM (SELECT id, ROW_NUMBER() OVER (PARTITION BY category_id ORDER BY created_at, id) AS n FROM items) o
+WHERE o.id = i.id;
+
+CREATE INDEX IF NOT EXISTS categories_position_idx ON categories (event_id, position);
+CREATE INDEX IF NOT EXISTS items_position_idx ON items (category_id, position);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS items_position_idx;
+DROP INDEX IF EXISTS categories_position_idx;
+ALTER TABLE items DROP COLUMN IF EXISTS position;
+ALTER TABLE categories DROP COLUMN IF EXISTS position;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Categories nest: a category may sit under another category of its event.
+-- Names only need to be unique among siblings.
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS parent_id UUID REFERENCES categories(id) ON DELETE CASCADE;
+ALTER TABLE categories ADD CONSTRAINT categories_parent_check CHECK (parent_id <> id);
+ALTER TABLE categories DROP CONSTRAINT IF EXISTS categories_event_id_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS categories_sibling_name_idx
+    ON categories (event_id, COALESCE(parent_id, '00000000-0000-0000-0000-000000000000'), name);
+CREATE INDEX IF NOT EXISTS categories_parent_idx ON categories (parent_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Fails if two categories of an event share a name under different parents.
+DROP INDEX IF EXISTS categories_parent_idx;
+DROP INDEX IF EXISTS categories_sibling_name_idx;
+ALTER TABLE categories ADD CONSTRAINT categories_event_id_name_key UNIQUE (event_id, name);
+ALTER TABLE categories DROP CONSTRAINT IF EXISTS categories_parent_check;
+ALTER TABLE categories DROP COLUMN IF EXISTS parent_id;
+-- +goose StatementEnd
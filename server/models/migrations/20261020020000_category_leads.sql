@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Members who manage a category, and every category nested under it
+CREATE TABLE IF NOT EXISTS category_leads (
+    category_id UUID REFERENCES categories(id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT now(),
+    PRIMARY KEY (category_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS category_leads_user_idx ON category_leads (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS category_leads;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS items_name_trgm_idx ON items USING gin (LOWER(name) gin_trgm_ops);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- The extension is left installed; other database objects may rely on it.
+DROP INDEX IF EXISTS items_name_trgm_idx;
+-- +goose StatementEnd
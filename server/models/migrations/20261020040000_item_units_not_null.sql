@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Items are always shown in some unit, so fall back to the defaults instead of NULL
+UPDATE items SET weight_unit = 'g' WHERE weight_unit IS NULL;
+UPDATE items SET volume_unit = 'ml' WHERE volume_unit IS NULL;
+ALTER TABLE items
+    ALTER COLUMN weight_unit SET NOT NULL,
+    ALTER COLUMN volume_unit SET NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE items
+    ALTER COLUMN volume_unit DROP NOT NULL,
+    ALTER COLUMN weight_unit DROP NOT NULL;
+-- +goose StatementEnd
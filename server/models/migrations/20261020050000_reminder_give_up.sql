@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Reminders abandoned after too many failed attempts are recorded too, so they are not queued again
+ALTER TABLE reminder_deliveries ADD COLUMN IF NOT EXISTS gave_up BOOLEAN NOT NULL DEFAULT false;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE reminder_deliveries DROP COLUMN IF EXISTS gave_up;
+-- +goose StatementEnd
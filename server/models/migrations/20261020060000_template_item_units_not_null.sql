@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Template items carry units like items do, so give them the same defaults instead of NULL
+UPDATE template_items SET weight_unit = 'g' WHERE weight_unit IS NULL;
+UPDATE template_items SET volume_unit = 'ml' WHERE volume_unit IS NULL;
+ALTER TABLE template_items
+    ALTER COLUMN weight_unit SET NOT NULL,
+    ALTER COLUMN volume_unit SET NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE template_items
+    ALTER COLUMN volume_unit DROP NOT NULL,
+    ALTER COLUMN weight_unit DROP NOT NULL;
+-- +goose StatementEnd
@@ -0,0 +1,145 @@
+package notifications
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/sunnymotiani/PackTrack/server/models/users"
+)
+
+// EMAIL DIGEST IMPLEMENTATION
+
+type Email struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends email. Deployments plug in their provider; LogMailer stands in
+// locally.
+type Mailer interface {
+	Send(ctx context.Context, e Email) error
+}
+
+// LogMailer writes emails to the standard logger instead of sending them.
+type LogMailer struct{}
+
+func (LogMailer) Send(ctx context.Context, e Email) error {
+	log.Printf("mail to %s: %s\n%s", e.To, e.Subject, e.Body)
+	return nil
+}
+
+// DigestJob emails each member who wants a digest the notifications they
+// have not read and have not been emailed about yet.
+type DigestJob struct {
+	DB     *sql.DB
+	Mailer Mailer
+	// Interval is how often digests go out, normally a day.
+	Interval time.Duration
+}
+
+// Run sends digests every Interval until ctx is cancelled.
+func (j *DigestJob) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := j.RunOnce(ctx); err != nil {
+				log.Printf("notifications digest: %v", err)
+			}
+		}
+	}
+}
+
+type digest struct {
+	email   string
+	name    string
+	ids     []string
+	entries []string
+}
+
+// RunOnce sends one digest per member. A member whose email fails keeps their
+// notifications for the next run.
+func (j *DigestJob) RunOnce(ctx context.Context) error {
+	query := sq.Select("n.id", "n.user_id", "u.email", "u.name", "n.message", "n.created_at").
+		From(TableNotifications+" n").
+		Join(users.TableUsers+" u ON u.id = n.user_id").
+		LeftJoin(TableNotificationPreferences+" p ON p.user_id = n.user_id").
+		Where(sq.Eq{"n.read_at": nil, "n.emailed_at": nil}).
+		Where("COALESCE(p.email_digest, true)").
+		OrderBy("n.user_id", "n.created_at ASC").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building digest query: %w", err)
+	}
+	rows, err := j.DB.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return fmt.Errorf("error querying digest notifications: %w", err)
+	}
+	defer rows.Close()
+
+	var order []string
+	digests := map[string]*digest{}
+	for rows.Next() {
+		var id, userID, email, name, message string
+		var createdAt time.Time
+		if err := rows.Scan(&id, &userID, &email, &name, &message, &createdAt); err != nil {
+			return fmt.Errorf("error scanning digest row: %w", err)
+		}
+		d, ok := digests[userID]
+		if !ok {
+			d = &digest{email: email, name: name}
+			digests[userID] = d
+			order = append(order, userID)
+		}
+		d.ids = append(d.ids, id)
+		d.entries = append(d.entries, fmt.Sprintf("- %s (%s)", message, createdAt.Format("Mon 2 Jan 15:04")))
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows iteration error: %w", err)
+	}
+	rows.Close()
+
+	for _, userID := range order {
+		d := digests[userID]
+		err := j.Mailer.Send(ctx, Email{
+			To:      d.email,
+			Subject: fmt.Sprintf("PackTrack: %d unread notifications", len(d.ids)),
+			Body:    fmt.Sprintf("Hi %s,\n\nHere is what you missed:\n\n%s\n", d.name, strings.Join(d.entries, "\n")),
+		})
+		if err != nil {
+			log.Printf("notifications digest: emailing %s: %v", userID, err)
+			continue
+		}
+		if err := j.markEmailed(ctx, d.ids); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (j *DigestJob) markEmailed(ctx context.Context, ids []string) error {
+	query := sq.Update(TableNotifications).
+		Set("emailed_at", sq.Expr("now()")).
+		Where(sq.Eq{"id": ids}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building mark emailed query: %w", err)
+	}
+	if _, err := j.DB.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("error marking notifications emailed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,327 @@
+package notifications
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/sunnymotiani/PackTrack/server/models/activity"
+	"github.com/sunnymotiani/PackTrack/server/models/reminders"
+)
+
+const (
+	TableNotifications           = "notifications"
+	TableNotificationPreferences = "notification_preferences"
+)
+
+var ErrNoNotifications = errors.New("no notification ids given")
+
+// preferenceColumns maps each activity type to the preference that turns it
+// off.
+var preferenceColumns = map[string]string{
+	activity.TypeAssignment:    "assignments",
+	activity.TypeStatusChange:  "status_changes",
+	activity.TypeMembership:    "membership",
+	activity.TypeMemberRemoved: "membership",
+	activity.TypeInvitation:    "invitations",
+	activity.TypeReminder:      "reminders",
+}
+
+// NotificationsService keeps each member's inbox. It is an activity
+// publisher, so it can be handed to the items and events services, and a
+// reminder notifier.
+type NotificationsService struct {
+	DB *sql.DB
+}
+
+type Notification struct {
+	ID        string                 `json:"id"`
+	UserID    string                 `json:"user_id"`
+	EventID   *string                `json:"event_id,omitempty"`
+	ItemID    *string                `json:"item_id,omitempty"`
+	ActorID   *string                `json:"actor_id,omitempty"`
+	Type      string                 `json:"type"`
+	Message   string                 `json:"message"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+	ReadAt    *time.Time             `json:"read_at,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+type Inbox struct {
+	Notifications []Notification `json:"notifications"`
+	Unread        int            `json:"unread"`
+}
+
+// Preferences says which notifications a member wants and whether unread
+// ones are emailed in a daily digest.
+type Preferences struct {
+	UserID        string `json:"user_id"`
+	Assignments   bool   `json:"assignments"`
+	StatusChanges bool   `json:"status_changes"`
+	Membership    bool   `json:"membership"`
+	Invitations   bool   `json:"invitations"`
+	Reminders     bool   `json:"reminders"`
+	EmailDigest   bool   `json:"email_digest"`
+}
+
+// Publish adds a notification to the inbox of every recipient other than the
+// actor who wants that type of activity. Failures are logged; the change the
+// activity describes has already happened.
+func (ns *NotificationsService) Publish(ctx context.Context, a activity.Activity) {
+	if err := ns.deliver(ctx, a, nil); err != nil {
+		log.Printf("notifications: %v", err)
+	}
+}
+
+// Notify delivers a packing reminder to the assignee's inbox. The reminder
+// key makes repeated deliveries of the same reminder a no-op.
+func (ns *NotificationsService) Notify(ctx context.Context, r reminders.Reminder) error {
+	key := "reminder:" + r.Key()
+	return ns.deliver(ctx, activity.Activity{
+		Type:       activity.TypeReminder,
+		EventID:    r.EventID,
+		ItemID:     r.ItemID,
+		Recipients: []string{r.UserID},
+		Message:    fmt.Sprintf("%s in %s is due %s", r.ItemName, r.EventName, r.DueAt.Format("Mon 2 Jan 15:04")),
+		Data:       map[string]interface{}{"due_at": r.DueAt},
+		OccurredAt: time.Now(),
+	}, &key)
+}
+
+func (ns *NotificationsService) deliver(ctx context.Context, a activity.Activity, dedupeKey *string) error {
+	recipients := []string{}
+	seen := map[string]bool{a.ActorID: true}
+	for _, id := range a.Recipients {
+		if !seen[id] {
+			seen[id] = true
+			recipients = append(recipients, id)
+		}
+	}
+	if len(recipients) == 0 {
+		return nil
+	}
+	muted, err := ns.mutedRecipients(ctx, a.Type, recipients)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	if a.Data != nil {
+		if data, err = json.Marshal(a.Data); err != nil {
+			return fmt.Errorf("error encoding notification data: %w", err)
+		}
+	}
+	insert := sq.Insert(TableNotifications).
+		Columns("id", "user_id", "event_id", "item_id", "actor_id", "type", "message", "data", "dedupe_key", "created_at").
+		Suffix("ON CONFLICT (dedupe_key) DO NOTHING").
+		PlaceholderFormat(sq.Dollar)
+	rows := 0
+	for _, userID := range recipients {
+		if muted[userID] {
+			continue
+		}
+		insert = insert.Values(uuid.NewString(), userID, nullable(a.EventID), nullable(a.ItemID), nullable(a.ActorID),
+			a.Type, a.Message, data, dedupeKey, a.OccurredAt)
+		rows++
+	}
+	if rows == 0 {
+		return nil
+	}
+
+	sqlStr, args, err := insert.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building insert notifications query: %w", err)
+	}
+	if _, err := ns.DB.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("error inserting notifications: %w", err)
+	}
+	return nil
+}
+
+// mutedRecipients returns the recipients who turned off activity of the given
+// type.
+func (ns *NotificationsService) mutedRecipients(ctx context.Context, kind string, userIDs []string) (map[string]bool, error) {
+	muted := map[string]bool{}
+	column, ok := preferenceColumns[kind]
+	if !ok {
+		return muted, nil
+	}
+	query := sq.Select("user_id").
+		From(TableNotificationPreferences).
+		Where(sq.Eq{"user_id": userIDs, column: false}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building notification preferences query: %w", err)
+	}
+	rows, err := ns.DB.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying notification preferences: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, fmt.Errorf("error scanning notification preference row: %w", err)
+		}
+		muted[userID] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return muted, nil
+}
+
+// GetInbox returns a member's most recent notifications, newest first, and
+// how many of all their notifications are unread.
+func (ns *NotificationsService) GetInbox(ctx context.Context, userID string, unreadOnly bool, limit int) (*Inbox, error) {
+	query := sq.Select("id", "user_id", "event_id", "item_id", "actor_id", "type", "message", "data", "read_at", "created_at").
+		From(TableNotifications).
+		Where(sq.Eq{"user_id": userID}).
+		OrderBy("created_at DESC", "id ASC").
+		Limit(uint64(limit)).
+		PlaceholderFormat(sq.Dollar)
+	if unreadOnly {
+		query = query.Where(sq.Eq{"read_at": nil})
+	}
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building inbox query: %w", err)
+	}
+	rows, err := ns.DB.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying inbox: %w", err)
+	}
+	defer rows.Close()
+
+	inbox := &Inbox{Notifications: []Notification{}}
+	for rows.Next() {
+		var n Notification
+		var data []byte
+		if err := rows.Scan(&n.ID, &n.UserID, &n.EventID, &n.ItemID, &n.ActorID, &n.Type, &n.Message, &data,
+			&n.ReadAt, &n.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning notification row: %w", err)
+		}
+		if data != nil {
+			if err := json.Unmarshal(data, &n.Data); err != nil {
+				return nil, fmt.Errorf("error decoding notification data: %w", err)
+			}
+		}
+		inbox.Notifications = append(inbox.Notifications, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	countQuery := sq.Select("COUNT(*)").
+		From(TableNotifications).
+		Where(sq.Eq{"user_id": userID, "read_at": nil}).
+		PlaceholderFormat(sq.Dollar)
+
+	countSQL, countArgs, err := countQuery.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building unread count query: %w", err)
+	}
+	if err := ns.DB.QueryRowContext(ctx, countSQL, countArgs...).Scan(&inbox.Unread); err != nil {
+		return nil, fmt.Errorf("error counting unread notifications: %w", err)
+	}
+	return inbox, nil
+}
+
+// MarkRead marks the given notifications of a member as read.
+func (ns *NotificationsService) MarkRead(ctx context.Context, userID string, ids []string) error {
+	if len(ids) == 0 {
+		return ErrNoNotifications
+	}
+	return ns.setReadAt(ctx, sq.Eq{"user_id": userID, "id": ids}, sq.Expr("COALESCE(read_at, now())"))
+}
+
+// MarkUnread puts the given notifications of a member back in their unread
+// list.
+func (ns *NotificationsService) MarkUnread(ctx context.Context, userID string, ids []string) error {
+	if len(ids) == 0 {
+		return ErrNoNotifications
+	}
+	return ns.setReadAt(ctx, sq.Eq{"user_id": userID, "id": ids}, nil)
+}
+
+// MarkAllRead clears a member's unread list.
+func (ns *NotificationsService) MarkAllRead(ctx context.Context, userID string) error {
+	return ns.setReadAt(ctx, sq.Eq{"user_id": userID, "read_at": nil}, sq.Expr("now()"))
+}
+
+func (ns *NotificationsService) setReadAt(ctx context.Context, where sq.Eq, readAt interface{}) error {
+	query := sq.Update(TableNotifications).
+		Set("read_at", readAt).
+		Where(where).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building read state query: %w", err)
+	}
+	if _, err := ns.DB.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("error updating read state: %w", err)
+	}
+	return nil
+}
+
+// GetPreferences returns a member's notification preferences; members who
+// never set any get everything.
+func (ns *NotificationsService) GetPreferences(ctx context.Context, userID string) (*Preferences, error) {
+	query := sq.Select("assignments", "status_changes", "membership", "invitations", "reminders", "email_digest").
+		From(TableNotificationPreferences).
+		Where(sq.Eq{"user_id": userID}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building preferences query: %w", err)
+	}
+	p := &Preferences{UserID: userID}
+	err = ns.DB.QueryRowContext(ctx, sqlStr, args...).
+		Scan(&p.Assignments, &p.StatusChanges, &p.Membership, &p.Invitations, &p.Reminders, &p.EmailDigest)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &Preferences{UserID: userID, Assignments: true, StatusChanges: true, Membership: true,
+			Invitations: true, Reminders: true, EmailDigest: true}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching preferences: %w", err)
+	}
+	return p, nil
+}
+
+func (ns *NotificationsService) SetPreferences(ctx context.Context, p Preferences) error {
+	query := sq.Insert(TableNotificationPreferences).
+		Columns("user_id", "assignments", "status_changes", "membership", "invitations", "reminders", "email_digest").
+		Values(p.UserID, p.Assignments, p.StatusChanges, p.Membership, p.Invitations, p.Reminders, p.EmailDigest).
+		Suffix(`ON CONFLICT (user_id) DO UPDATE SET assignments = EXCLUDED.assignments,
+			status_changes = EXCLUDED.status_changes, membership = EXCLUDED.membership,
+			invitations = EXCLUDED.invitations, reminders = EXCLUDED.reminders,
+			email_digest = EXCLUDED.email_digest`).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building set preferences query: %w", err)
+	}
+	if _, err := ns.DB.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("error saving preferences: %w", err)
+	}
+	return nil
+}
+
+func nullable(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
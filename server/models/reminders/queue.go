@@ -0,0 +1,90 @@
+package reminders
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DELAYED QUEUE IMPLEMENTATION
+
+// Queue is a delayed job queue kept in Redis: a sorted set of job keys scored
+// by when they are due, and a hash holding each job's payload.
+type Queue struct {
+	Client *redis.Client
+	// Name prefixes the Redis keys, e.g. "packtrack:reminders".
+	Name string
+}
+
+func (q *Queue) scheduleKey() string { return q.Name + ":schedule" }
+func (q *Queue) jobsKey() string     { return q.Name + ":jobs" }
+
+// Schedule queues a reminder to run at the given time. A reminder already
+// queued under the same key is moved to the new time and payload.
+func (q *Queue) Schedule(ctx context.Context, r Reminder, at time.Time) error {
+	payload, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("error encoding reminder: %w", err)
+	}
+	key := r.Key()
+	_, err = q.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, q.jobsKey(), key, payload)
+		pipe.ZAdd(ctx, q.scheduleKey(), redis.Z{Score: float64(at.Unix()), Member: key})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error scheduling reminder: %w", err)
+	}
+	return nil
+}
+
+// ScheduleOnce queues a reminder unless one with the same key is already
+// queued, keeping its time and attempt count.
+func (q *Queue) ScheduleOnce(ctx context.Context, r Reminder, at time.Time) error {
+	queued, err := q.Client.ZScore(ctx, q.scheduleKey(), r.Key()).Result()
+	if err == nil && queued > 0 {
+		return nil
+	}
+	if err != nil && err != redis.Nil {
+		return fmt.Errorf("error checking reminder schedule: %w", err)
+	}
+	return q.Schedule(ctx, r, at)
+}
+
+// popDue atomically takes up to limit jobs due by now off the schedule so
+// that several workers never run the same job.
+var popDue = redis.NewScript(`
+local keys = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+local jobs = {}
+for _, key in ipairs(keys) do
+	redis.call('ZREM', KEYS[1], key)
+	local payload = redis.call('HGET', KEYS[2], key)
+	redis.call('HDEL', KEYS[2], key)
+	if payload then
+		table.insert(jobs, payload)
+	end
+end
+return jobs
+`)
+
+// Due removes and returns up to limit reminders whose time has come.
+func (q *Queue) Due(ctx context.Context, now time.Time, limit int) ([]Reminder, error) {
+	res, err := popDue.Run(ctx, q.Client, []string{q.scheduleKey(), q.jobsKey()},
+		strconv.FormatInt(now.Unix(), 10), limit).StringSlice()
+	if err != nil {
+		return nil, fmt.Errorf("error fetching due reminders: %w", err)
+	}
+	due := make([]Reminder, 0, len(res))
+	for _, payload := range res {
+		var r Reminder
+		if err := json.Unmarshal([]byte(payload), &r); err != nil {
+			return nil, fmt.Errorf("error decoding reminder: %w", err)
+		}
+		due = append(due, r)
+	}
+	return due, nil
+}
@@ -0,0 +1,236 @@
+package reminders
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/sunnymotiani/PackTrack/server/models/events"
+	"github.com/sunnymotiani/PackTrack/server/models/items"
+)
+
+const TableReminderDeliveries = "reminder_deliveries"
+
+// Reminder tells an assignee that an item they still have to pack is due.
+// DueAt is the item's own due date or, failing that, the event's packing
+// deadline.
+type Reminder struct {
+	ItemID    string    `json:"item_id"`
+	ItemName  string    `json:"item_name"`
+	EventID   string    `json:"event_id"`
+	EventName string    `json:"event_name"`
+	UserID    string    `json:"user_id"`
+	DueAt     time.Time `json:"due_at"`
+	Attempt   int       `json:"attempt"`
+}
+
+// Key identifies a reminder across retries. A new due date makes a new
+// reminder.
+func (r Reminder) Key() string {
+	return fmt.Sprintf("%s:%s:%d", r.ItemID, r.UserID, r.DueAt.Unix())
+}
+
+// Notifier delivers reminders. Notify may be called more than once for the
+// same Key if the worker stops between delivering and recording a reminder,
+// so implementations should treat Key as an idempotency key.
+type Notifier interface {
+	Notify(ctx context.Context, r Reminder) error
+}
+
+// LogNotifier writes reminders to the standard logger.
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(ctx context.Context, r Reminder) error {
+	log.Printf("reminder: %s in %s is due %s for user %s", r.ItemName, r.EventName, r.DueAt.Format(time.RFC3339), r.UserID)
+	return nil
+}
+
+// Worker finds items that are due soon and still to pack, queues a reminder
+// for each of their assignees and delivers the reminders once due.
+type Worker struct {
+	DB       *sql.DB
+	Queue    *Queue
+	Notifier Notifier
+
+	// Lead is how long before the due date the reminder goes out.
+	Lead time.Duration
+	// Interval is how often the worker looks for due items and reminders.
+	Interval time.Duration
+	// MaxAttempts bounds delivery attempts; a failed attempt is retried after
+	// Backoff, doubled for each attempt.
+	MaxAttempts int
+	Backoff     time.Duration
+}
+
+// Run polls until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		if err := w.RunOnce(ctx, time.Now()); err != nil {
+			log.Printf("reminders: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce schedules reminders for items due within the lead time and
+// delivers the reminders that are due at now.
+func (w *Worker) RunOnce(ctx context.Context, now time.Time) error {
+	if err := w.scheduleDueItems(ctx, now); err != nil {
+		return err
+	}
+	for {
+		due, err := w.Queue.Due(ctx, now, 100)
+		if err != nil {
+			return err
+		}
+		for _, r := range due {
+			w.deliver(ctx, now, r)
+		}
+		if len(due) < 100 {
+			return nil
+		}
+	}
+}
+
+// deliver sends a due reminder unless it is no longer pending, retrying
+// failed attempts with exponential backoff.
+func (w *Worker) deliver(ctx context.Context, now time.Time, r Reminder) {
+	pending, err := w.stillPending(ctx, r)
+	if err == nil && !pending {
+		return
+	}
+	if err == nil {
+		err = w.Notifier.Notify(ctx, r)
+	}
+	if err == nil {
+		// Once delivered the reminder is not retried, even if recording the
+		// delivery fails.
+		if err := w.recordDelivery(ctx, r, false); err != nil {
+			log.Printf("reminders: recording %s: %v", r.Key(), err)
+		}
+		return
+	}
+
+	r.Attempt++
+	if r.Attempt >= w.MaxAttempts {
+		log.Printf("reminders: giving up on %s after %d attempts: %v", r.Key(), r.Attempt, err)
+		// Recording the give-up keeps scheduleDueItems from queueing the
+		// reminder again with a fresh attempt count.
+		if err := w.recordDelivery(ctx, r, true); err != nil {
+			log.Printf("reminders: recording give-up of %s: %v", r.Key(), err)
+		}
+		return
+	}
+	retryAt := now.Add(w.Backoff << (r.Attempt - 1))
+	if err := w.Queue.Schedule(ctx, r, retryAt); err != nil {
+		log.Printf("reminders: rescheduling %s: %v", r.Key(), err)
+	}
+}
+
+// scheduleDueItems queues a reminder for every assignee who still has to
+// pack an item due before now + Lead and has not been reminded of that due
+// date yet. Items more than a day overdue are left alone so that turning the
+// worker on does not remind everyone of long-gone deadlines.
+func (w *Worker) scheduleDueItems(ctx context.Context, now time.Time) error {
+	dueAt := "COALESCE(i.due_at, e.packing_deadline)"
+	query := sq.Select("i.id", "i.name", "e.id", "e.name", "ia.user_id", dueAt).
+		From(items.TableItemAssignments + " ia").
+		Join(items.TableItems + " i ON i.id = ia.item_id").
+		Join(items.TableCategories + " c ON c.id = i.category_id").
+		Join(events.TableEvents + " e ON e.id = c.event_id").
+		Where(sq.Eq{"ia.status": items.StatusToPack}).
+		Where(sq.Expr(dueAt+" <= ?", now.Add(w.Lead))).
+		Where(sq.Expr(dueAt+" > ?", now.Add(-24*time.Hour))).
+		Where(fmt.Sprintf(`NOT EXISTS (SELECT 1 FROM %s rd
+			WHERE rd.item_id = i.id AND rd.user_id = ia.user_id AND rd.due_at = %s)`,
+			TableReminderDeliveries, dueAt)).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building due items query: %w", err)
+	}
+	rows, err := w.DB.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return fmt.Errorf("error querying due items: %w", err)
+	}
+	defer rows.Close()
+
+	var pending []Reminder
+	for rows.Next() {
+		var r Reminder
+		if err := rows.Scan(&r.ItemID, &r.ItemName, &r.EventID, &r.EventName, &r.UserID, &r.DueAt); err != nil {
+			return fmt.Errorf("error scanning due item row: %w", err)
+		}
+		pending = append(pending, r)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	for _, r := range pending {
+		at := r.DueAt.Add(-w.Lead)
+		if at.Before(now) {
+			at = now
+		}
+		if err := w.Queue.ScheduleOnce(ctx, r, at); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stillPending reports whether the reminder is still worth sending: the user
+// is still assigned, has not packed their share, the due date is unchanged
+// and the reminder has not been delivered already.
+func (w *Worker) stillPending(ctx context.Context, r Reminder) (bool, error) {
+	query := sq.Select("COUNT(*)").
+		From(items.TableItemAssignments + " ia").
+		Join(items.TableItems + " i ON i.id = ia.item_id").
+		Join(items.TableCategories + " c ON c.id = i.category_id").
+		Join(events.TableEvents + " e ON e.id = c.event_id").
+		Where(sq.Eq{"ia.item_id": r.ItemID, "ia.user_id": r.UserID, "ia.status": items.StatusToPack}).
+		Where(sq.Expr("COALESCE(i.due_at, e.packing_deadline) = ?", r.DueAt)).
+		Where(sq.Expr(fmt.Sprintf(`NOT EXISTS (SELECT 1 FROM %s rd
+			WHERE rd.item_id = ia.item_id AND rd.user_id = ia.user_id AND rd.due_at = ?)`,
+			TableReminderDeliveries), r.DueAt)).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return false, fmt.Errorf("error building pending reminder query: %w", err)
+	}
+	var count int
+	if err := w.DB.QueryRowContext(ctx, sqlStr, args...).Scan(&count); err != nil {
+		return false, fmt.Errorf("error checking pending reminder: %w", err)
+	}
+	return count > 0, nil
+}
+
+// recordDelivery marks a reminder as done, either delivered or given up on
+// after MaxAttempts failed attempts.
+func (w *Worker) recordDelivery(ctx context.Context, r Reminder, gaveUp bool) error {
+	query := sq.Insert(TableReminderDeliveries).
+		Columns("item_id", "user_id", "due_at", "gave_up").
+		Values(r.ItemID, r.UserID, r.DueAt, gaveUp).
+		Suffix("ON CONFLICT DO NOTHING").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building reminder delivery query: %w", err)
+	}
+	if _, err := w.DB.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("error recording reminder delivery: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package reports
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/sunnymotiani/PackTrack/server/models/events"
+	"github.com/sunnymotiani/PackTrack/server/models/items"
+	"github.com/sunnymotiani/PackTrack/server/models/users"
+)
+
+type ReportsService struct {
+	DB *sql.DB
+}
+
+// MemberContribution summarises the workload carried by one member of an event.
+type MemberContribution struct {
+	UserID            string            `json:"user_id"`
+	Name              string            `json:"name"`
+	Email             string            `json:"email"`
+	Role              string            `json:"role"`
+	AssignedItems     int               `json:"assigned_items"`
+	TotalQuantity     int               `json:"total_quantity"`
+	PackedQuantity    int               `json:"packed_quantity"`
+	DeliveredQuantity int               `json:"delivered_quantity"`
+	PackedRatio       float64           `json:"packed_ratio"`
+	DeliveredRatio    float64           `json:"delivered_ratio"`
+	StatusChanges     int               `json:"status_changes"`
+	Items             []ContributedItem `json:"items"`
+}
+
+// ContributedItem is an item assigned to a member: Quantity is their share
+// and Status the progress of that share.
+type ContributedItem struct {
+	ItemID   string `json:"item_id"`
+	Name     string `json:"name"`
+	Quantity int    `json:"quantity"`
+	Status   string `json:"status"`
+}
+
+// GetMemberContributions returns one row per event member with their share
+// of the items assigned to them and the number of status changes they
+// performed, listing the items they hold. Ratios are computed over quantities, so partially packed items
+// count towards them; packed quantities include the ones already delivered.
+func (rs *ReportsService) GetMemberContributions(ctx context.Context, eventID string) ([]MemberContribution, error) {
+	assigned := fmt.Sprintf(`(SELECT ia.user_id,
+			COUNT(*) AS item_count,
+			COALESCE(SUM(ia.quantity), 0) AS total_quantity,
+			COALESCE(SUM(ia.packed_quantity), 0) AS packed_quantity,
+			COALESCE(SUM(ia.delivered_quantity), 0) AS delivered_quantity
+		FROM %s ia
+		JOIN %s i ON i.id = ia.item_id
+		JOIN %s c ON c.id = i.category_id
+		WHERE c.event_id = ?
+		GROUP BY ia.user_id) a ON a.user_id = em.user_id`,
+		items.TableItemAssignments, items.TableItems, items.TableCategories)
+	changes := fmt.Sprintf(`(SELECT h.user_id, COUNT(*) AS change_count
+		FROM %s h
+		JOIN %s i ON i.id = h.item_id
+		JOIN %s c ON c.id = i.category_id
+		WHERE c.event_id = ? AND h.user_id IS NOT NULL
+		GROUP BY h.user_id) h ON h.user_id = em.user_id`,
+		items.TableItemStatusHistory, items.TableItems, items.TableCategories)
+
+	query := sq.
+		Select("em.user_id", "u.name", "u.email", "em.role",
+			"COALESCE(a.item_count, 0)", "COALESCE(a.total_quantity, 0)",
+			"COALESCE(a.packed_quantity, 0)", "COALESCE(a.delivered_quantity, 0)",
+			"COALESCE(h.change_count, 0)").
+		From(events.TableEventMemberships+" em").
+		Join(users.TableUsers+" u ON u.id = em.user_id").
+		LeftJoin(assigned, eventID).
+		LeftJoin(changes, eventID).
+		Where(sq.Eq{"em.event_id": eventID}).
+		OrderBy("u.name ASC").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building contributions query: %w", err)
+	}
+
+	rows, err := rs.DB.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying contributions: %w", err)
+	}
+	defer rows.Close()
+
+	var report []MemberContribution
+	for rows.Next() {
+		var c MemberContribution
+		if err := rows.Scan(&c.UserID, &c.Name, &c.Email, &c.Role,
+			&c.AssignedItems, &c.TotalQuantity, &c.PackedQuantity, &c.DeliveredQuantity,
+			&c.StatusChanges); err != nil {
+			return nil, fmt.Errorf("error scanning contribution row: %w", err)
+		}
+		c.Items = []ContributedItem{}
+		if c.TotalQuantity > 0 {
+			c.PackedRatio = float64(c.PackedQuantity) / float64(c.TotalQuantity)
+			c.DeliveredRatio = float64(c.DeliveredQuantity) / float64(c.TotalQuantity)
+		}
+		report = append(report, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	if err := rs.attachContributedItems(ctx, eventID, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// attachContributedItems fills in the items assigned to each member of the
+// report.
+func (rs *ReportsService) attachContributedItems(ctx context.Context, eventID string, report []MemberContribution) error {
+	byUser := map[string]*MemberContribution{}
+	for i := range report {
+		byUser[report[i].UserID] = &report[i]
+	}
+	query := sq.Select("ia.user_id", "i.id", "i.name", "ia.quantity", "ia.status").
+		From(items.TableItemAssignments+" ia").
+		Join(items.TableItems+" i ON i.id = ia.item_id").
+		Join(items.TableCategories+" c ON c.id = i.category_id").
+		Where(sq.Eq{"c.event_id": eventID}).
+		OrderBy("c.position ASC", "c.id ASC", "i.position ASC", "i.id ASC").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building contributed items query: %w", err)
+	}
+	rows, err := rs.DB.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return fmt.Errorf("error querying contributed items: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var userID string
+		var it ContributedItem
+		if err := rows.Scan(&userID, &it.ItemID, &it.Name, &it.Quantity, &it.Status); err != nil {
+			return fmt.Errorf("error scanning contributed item row: %w", err)
+		}
+		if c, ok := byUser[userID]; ok {
+			c.Items = append(c.Items, it)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows iteration error: %w", err)
+	}
+	return nil
+}
+
+// WriteContributionsCSV writes the report with a header row. A member's
+// items share one cell as "name x quantity (status)" entries separated by
+// semicolons.
+func WriteContributionsCSV(w io.Writer, report []MemberContribution) error {
+	cw := csv.NewWriter(w)
+	header := []string{"user_id", "name", "email", "role", "assigned_items", "total_quantity",
+		"packed_quantity", "delivered_quantity", "packed_ratio", "delivered_ratio", "status_changes", "items"}
+	if err := cw.Write(header); err != nil {
+		return fmt.Errorf("error writing contributions csv header: %w", err)
+	}
+	for _, c := range report {
+		held := make([]string, 0, len(c.Items))
+		for _, it := range c.Items {
+			held = append(held, fmt.Sprintf("%s x%d (%s)", it.Name, it.Quantity, it.Status))
+		}
+		record := []string{
+			c.UserID,
+			c.Name,
+			c.Email,
+			c.Role,
+			strconv.Itoa(c.AssignedItems),
+			strconv.Itoa(c.TotalQuantity),
+			strconv.Itoa(c.PackedQuantity),
+			strconv.Itoa(c.DeliveredQuantity),
+			strconv.FormatFloat(c.PackedRatio, 'f', 2, 64),
+			strconv.FormatFloat(c.DeliveredRatio, 'f', 2, 64),
+			strconv.Itoa(c.StatusChanges),
+			strings.Join(held, "; "),
+		}
+		if err := cw.Write(record); err != nil {
+			return fmt.Errorf("error writing contributions csv row: %w", err)
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
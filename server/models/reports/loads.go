@@ -0,0 +1,133 @@
+package reports
+
+import (
+	"context"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/sunnymotiani/PackTrack/server/models/events"
+	"github.com/sunnymotiani/PackTrack/server/models/items"
+	"github.com/sunnymotiani/PackTrack/server/models/users"
+)
+
+// LoadTotal is the combined weight and volume of a group of items. Items
+// without a weight or volume are left out of the sums and counted in
+// MissingWeight and MissingVolume instead.
+type LoadTotal struct {
+	ID            string        `json:"id,omitempty"`
+	Name          string        `json:"name"`
+	Weight        items.Measure `json:"weight"`
+	Volume        items.Measure `json:"volume"`
+	MissingWeight int           `json:"missing_weight"`
+	MissingVolume int           `json:"missing_volume"`
+}
+
+type LoadTotals struct {
+	Event      LoadTotal   `json:"event"`
+	Categories []LoadTotal `json:"categories"`
+	Members    []LoadTotal `json:"members"`
+}
+
+// GetLoadTotals adds up item weights and volumes per category, per member
+// (their assigned share) and for the whole event, in the requested units.
+func (rs *ReportsService) GetLoadTotals(ctx context.Context, eventID, weightUnit, volumeUnit string) (*LoadTotals, error) {
+	if weightUnit == "" {
+		weightUnit = items.UnitKilogram
+	}
+	if volumeUnit == "" {
+		volumeUnit = items.UnitLitre
+	}
+	if !items.ValidWeightUnit(weightUnit) || !items.ValidVolumeUnit(volumeUnit) {
+		return nil, items.ErrUnknownUnit
+	}
+
+	sums := []string{
+		"COALESCE(SUM(i.weight_g * %[1]s), 0)",
+		"COALESCE(SUM(i.volume_ml * %[1]s), 0)",
+		"COUNT(i.id) FILTER (WHERE i.weight_g IS NULL)",
+		"COUNT(i.id) FILTER (WHERE i.volume_ml IS NULL)",
+	}
+	columns := func(quantity string, leading ...string) []string {
+		cols := append([]string{}, leading...)
+		for _, s := range sums {
+			cols = append(cols, fmt.Sprintf(s, quantity))
+		}
+		return cols
+	}
+
+	categoriesQuery := sq.Select(columns("i.quantity", "c.id", "c.name")...).
+		From(items.TableCategories+" c").
+		LeftJoin(items.TableItems+" i ON i.category_id = c.id").
+		Where(sq.Eq{"c.event_id": eventID}).
+		GroupBy("c.id", "c.name").
+		OrderBy("c.position ASC", "c.id ASC")
+
+	membersQuery := sq.Select(columns("ia.quantity", "u.id", "u.name")...).
+		From(events.TableEventMemberships+" em").
+		Join(users.TableUsers+" u ON u.id = em.user_id").
+		LeftJoin(fmt.Sprintf(`(%s ia
+			JOIN %s i ON i.id = ia.item_id
+			JOIN %s c ON c.id = i.category_id AND c.event_id = ?) ON ia.user_id = em.user_id`,
+			items.TableItemAssignments, items.TableItems, items.TableCategories), eventID).
+		Where(sq.Eq{"em.event_id": eventID}).
+		GroupBy("u.id", "u.name").
+		OrderBy("u.name ASC")
+
+	totals := &LoadTotals{Categories: []LoadTotal{}, Members: []LoadTotal{}}
+	if err := rs.scanLoadTotals(ctx, categoriesQuery, weightUnit, volumeUnit, func(t LoadTotal) {
+		totals.Categories = append(totals.Categories, t)
+	}); err != nil {
+		return nil, err
+	}
+	if err := rs.scanLoadTotals(ctx, membersQuery, weightUnit, volumeUnit, func(t LoadTotal) {
+		totals.Members = append(totals.Members, t)
+	}); err != nil {
+		return nil, err
+	}
+
+	totals.Event = LoadTotal{
+		ID:     eventID,
+		Name:   "event",
+		Weight: items.Measure{Unit: weightUnit},
+		Volume: items.Measure{Unit: volumeUnit},
+	}
+	for _, c := range totals.Categories {
+		totals.Event.Weight.Value += c.Weight.Value
+		totals.Event.Volume.Value += c.Volume.Value
+		totals.Event.MissingWeight += c.MissingWeight
+		totals.Event.MissingVolume += c.MissingVolume
+	}
+	return totals, nil
+}
+
+func (rs *ReportsService) scanLoadTotals(ctx context.Context, query sq.SelectBuilder, weightUnit, volumeUnit string, add func(LoadTotal)) error {
+	sqlStr, args, err := query.PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return fmt.Errorf("error building load totals query: %w", err)
+	}
+	rows, err := rs.DB.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return fmt.Errorf("error querying load totals: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t LoadTotal
+		var grams, ml float64
+		if err := rows.Scan(&t.ID, &t.Name, &grams, &ml, &t.MissingWeight, &t.MissingVolume); err != nil {
+			return fmt.Errorf("error scanning load totals row: %w", err)
+		}
+		weight, err := items.ConvertWeight(grams, items.UnitGram, weightUnit)
+		if err != nil {
+			return err
+		}
+		volume, err := items.ConvertVolume(ml, items.UnitMillilitre, volumeUnit)
+		if err != nil {
+			return err
+		}
+		t.Weight = items.Measure{Value: weight, Unit: weightUnit}
+		t.Volume = items.Measure{Value: volume, Unit: volumeUnit}
+		add(t)
+	}
+	return rows.Err()
+}
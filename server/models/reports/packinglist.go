@@ -0,0 +1,349 @@
+package reports
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jung-kurt/gofpdf"
+	"github.com/sunnymotiani/PackTrack/server/models/events"
+	"github.com/sunnymotiani/PackTrack/server/models/items"
+	"github.com/sunnymotiani/PackTrack/server/models/users"
+)
+
+const (
+	GroupByCategory = "category"
+	GroupByAssignee = "assignee"
+)
+
+// unassignedGroup heads the items nobody is assigned to when grouping by
+// assignee.
+const unassignedGroup = "Unassigned"
+
+var (
+	ErrInvalidGroupBy = errors.New("group_by must be category or assignee")
+	ErrEventNotFound  = errors.New("event not found")
+)
+
+// PackingListItem is one line of a packing list. When grouped by assignee
+// the quantities are the assignee's share of the item.
+type PackingListItem struct {
+	ItemID            string   `json:"item_id"`
+	Name              string   `json:"name"`
+	Category          string   `json:"category"`
+	Quantity          int      `json:"quantity"`
+	PackedQuantity    int      `json:"packed_quantity"`
+	DeliveredQuantity int      `json:"delivered_quantity"`
+	Status            string   `json:"status"`
+	Assignees         []string `json:"assignees"`
+	Notes             *string  `json:"notes,omitempty"`
+}
+
+// Packed reports whether the line can be ticked off.
+func (p PackingListItem) Packed() bool {
+	return p.PackedQuantity >= p.Quantity
+}
+
+type PackingListGroup struct {
+	Name  string            `json:"name"`
+	Items []PackingListItem `json:"items"`
+}
+
+type PackingList struct {
+	EventID   string             `json:"event_id"`
+	EventName string             `json:"event_name"`
+	GroupBy   string             `json:"group_by"`
+	Groups    []PackingListGroup `json:"groups"`
+}
+
+// GetPackingList returns the items of an event grouped by category, empty
+// categories included and named by their full path, or by assignee. An item split between several members
+// appears under each of them with their share; unassigned items come last.
+func (rs *ReportsService) GetPackingList(ctx context.Context, eventID, groupBy string) (*PackingList, error) {
+	if groupBy == "" {
+		groupBy = GroupByCategory
+	}
+	if groupBy != GroupByCategory && groupBy != GroupByAssignee {
+		return nil, ErrInvalidGroupBy
+	}
+	list := &PackingList{EventID: eventID, GroupBy: groupBy, Groups: []PackingListGroup{}}
+
+	eventQuery := sq.Select("name").From(events.TableEvents).Where(sq.Eq{"id": eventID}).PlaceholderFormat(sq.Dollar)
+	eventSQL, eventArgs, err := eventQuery.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building event query: %w", err)
+	}
+	err = rs.DB.QueryRowContext(ctx, eventSQL, eventArgs...).Scan(&list.EventName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEventNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching event: %w", err)
+	}
+
+	tree, err := items.LoadCategoryTree(ctx, rs.DB, eventID)
+	if err != nil {
+		return nil, err
+	}
+	paths := map[string]string{}
+	for _, n := range items.FlattenCategoryTree(tree) {
+		paths[n.ID] = n.Path
+	}
+
+	query := sq.Select("c.id", "i.id", "i.name", "i.quantity", "i.packed_quantity", "i.delivered_quantity",
+		"i.status", "i.notes", "u.id", "u.name", "ia.quantity", "ia.packed_quantity", "ia.delivered_quantity", "ia.status").
+		From(items.TableCategories+" c").
+		LeftJoin(items.TableItems+" i ON i.category_id = c.id").
+		LeftJoin(items.TableItemAssignments+" ia ON ia.item_id = i.id").
+		LeftJoin(users.TableUsers+" u ON u.id = ia.user_id").
+		Where(sq.Eq{"c.event_id": eventID}).
+		OrderBy("c.position ASC", "c.id ASC", "i.position ASC", "i.id ASC", "u.name ASC", "u.id ASC").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building packing list query: %w", err)
+	}
+	rows, err := rs.DB.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying packing list: %w", err)
+	}
+	defer rows.Close()
+
+	groups := map[string]int{}
+	lines := map[string]int{}
+	// Groups are keyed by category path or assignee id; members sharing a
+	// name still get a group each.
+	group := func(key, name string) *PackingListGroup {
+		i, ok := groups[key]
+		if !ok {
+			i = len(list.Groups)
+			groups[key] = i
+			list.Groups = append(list.Groups, PackingListGroup{Name: name, Items: []PackingListItem{}})
+		}
+		return &list.Groups[i]
+	}
+	if groupBy == GroupByCategory {
+		// Subcategories follow their parent.
+		for _, n := range items.FlattenCategoryTree(tree) {
+			group(n.Path, n.Path)
+		}
+	}
+	var unassigned []PackingListItem
+	for rows.Next() {
+		var categoryID string
+		var itemID, name, status, assigneeID, assignee, shareStatus sql.NullString
+		var quantity, packed, delivered, share, sharePacked, shareDelivered sql.NullInt64
+		var notes *string
+		if err := rows.Scan(&categoryID, &itemID, &name, &quantity, &packed, &delivered, &status, &notes,
+			&assigneeID, &assignee, &share, &sharePacked, &shareDelivered, &shareStatus); err != nil {
+			return nil, fmt.Errorf("error scanning packing list row: %w", err)
+		}
+		category := paths[categoryID]
+		if groupBy == GroupByCategory {
+			g := group(category, category)
+			if !itemID.Valid {
+				continue
+			}
+			i, ok := lines[itemID.String]
+			if !ok {
+				i = len(g.Items)
+				lines[itemID.String] = i
+				g.Items = append(g.Items, PackingListItem{
+					ItemID: itemID.String, Name: name.String, Category: category,
+					Quantity: int(quantity.Int64), PackedQuantity: int(packed.Int64),
+					DeliveredQuantity: int(delivered.Int64), Status: status.String,
+					Assignees: []string{}, Notes: notes,
+				})
+			}
+			if assignee.Valid {
+				g.Items[i].Assignees = append(g.Items[i].Assignees, assignee.String)
+			}
+			continue
+		}
+
+		if !itemID.Valid {
+			continue
+		}
+		line := PackingListItem{
+			ItemID: itemID.String, Name: name.String, Category: category,
+			Quantity: int(quantity.Int64), PackedQuantity: int(packed.Int64),
+			DeliveredQuantity: int(delivered.Int64), Status: status.String,
+			Assignees: []string{}, Notes: notes,
+		}
+		if !assigneeID.Valid {
+			unassigned = append(unassigned, line)
+			continue
+		}
+		line.Quantity, line.PackedQuantity = int(share.Int64), int(sharePacked.Int64)
+		line.DeliveredQuantity, line.Status = int(shareDelivered.Int64), shareStatus.String
+		line.Assignees = []string{assignee.String}
+		g := group(assigneeID.String, assignee.String)
+		g.Items = append(g.Items, line)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	if groupBy == GroupByAssignee {
+		// Rows come ordered by category; members are listed by name.
+		sort.SliceStable(list.Groups, func(i, j int) bool {
+			return strings.ToLower(list.Groups[i].Name) < strings.ToLower(list.Groups[j].Name)
+		})
+		if len(unassigned) > 0 {
+			list.Groups = append(list.Groups, PackingListGroup{Name: unassignedGroup, Items: unassigned})
+		}
+	}
+	return list, nil
+}
+
+// WritePackingListCSV writes one row per line with a header row. Lists
+// grouped by assignee start each row with the assignee.
+func WritePackingListCSV(w io.Writer, list *PackingList) error {
+	cw := csv.NewWriter(w)
+	header := []string{"category", "item", "quantity", "packed_quantity", "delivered_quantity",
+		"status", "assignees", "notes"}
+	if list.GroupBy == GroupByAssignee {
+		header = append([]string{GroupByAssignee}, header...)
+	}
+	if err := cw.Write(header); err != nil {
+		return fmt.Errorf("error writing packing list csv header: %w", err)
+	}
+	for _, g := range list.Groups {
+		for _, p := range g.Items {
+			notes := ""
+			if p.Notes != nil {
+				notes = *p.Notes
+			}
+			record := []string{
+				p.Category,
+				p.Name,
+				strconv.Itoa(p.Quantity),
+				strconv.Itoa(p.PackedQuantity),
+				strconv.Itoa(p.DeliveredQuantity),
+				p.Status,
+				strings.Join(p.Assignees, "; "),
+				notes,
+			}
+			if list.GroupBy == GroupByAssignee {
+				record = append([]string{g.Name}, record...)
+			}
+			if err := cw.Write(record); err != nil {
+				return fmt.Errorf("error writing packing list csv row: %w", err)
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WritePackingListMarkdown writes the list as a checklist with one section
+// per group; packed lines are ticked.
+func WritePackingListMarkdown(w io.Writer, list *PackingList) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", list.EventName)
+	for _, g := range list.Groups {
+		fmt.Fprintf(&b, "\n## %s\n\n", g.Name)
+		if len(g.Items) == 0 {
+			b.WriteString("_No items_\n")
+		}
+		for _, p := range g.Items {
+			check := " "
+			if p.Packed() {
+				check = "x"
+			}
+			fmt.Fprintf(&b, "- [%s] %s", check, markdownEscaper.Replace(p.Name))
+			if p.Quantity > 1 {
+				fmt.Fprintf(&b, " ×%d", p.Quantity)
+			}
+			if detail := lineDetail(list.GroupBy, p); detail != "" {
+				fmt.Fprintf(&b, " — %s", markdownEscaper.Replace(detail))
+			}
+			if p.Notes != nil && *p.Notes != "" {
+				fmt.Fprintf(&b, " _(%s)_", markdownEscaper.Replace(*p.Notes))
+			}
+			b.WriteString("\n")
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "`", "\\`", "\n", " ")
+
+// lineDetail is what a printed line adds to the item name: its assignees
+// when grouped by category, its category when grouped by assignee.
+func lineDetail(groupBy string, p PackingListItem) string {
+	if groupBy == GroupByAssignee {
+		return p.Category
+	}
+	return strings.Join(p.Assignees, ", ")
+}
+
+// WritePackingListPDF renders a printable A4 checklist with a tick box per
+// line; packed lines are ticked.
+func WritePackingListPDF(w io.Writer, list *PackingList) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.SetTitle(tr(list.EventName), false)
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(true, 15)
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-12)
+		pdf.SetFont("Helvetica", "", 8)
+		pdf.CellFormat(0, 5, fmt.Sprintf("%s - page %d", tr(list.EventName), pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.CellFormat(0, 10, tr(list.EventName), "", 1, "L", false, 0, "")
+
+	width, height := pdf.GetPageSize()
+	left, _, right, bottom := pdf.GetMargins()
+	textWidth := width - left - right - 8
+	for _, g := range list.Groups {
+		pdf.Ln(3)
+		pdf.SetFont("Helvetica", "B", 13)
+		pdf.CellFormat(0, 8, tr(g.Name), "B", 1, "L", false, 0, "")
+		pdf.Ln(1)
+		pdf.SetFont("Helvetica", "", 10)
+		if len(g.Items) == 0 {
+			pdf.SetFont("Helvetica", "I", 10)
+			pdf.CellFormat(0, 6, "No items", "", 1, "L", false, 0, "")
+			continue
+		}
+		for _, p := range g.Items {
+			text := p.Name
+			if p.Quantity > 1 {
+				text += fmt.Sprintf(" x%d", p.Quantity)
+			}
+			if detail := lineDetail(list.GroupBy, p); detail != "" {
+				text += " - " + detail
+			}
+			if p.Notes != nil && *p.Notes != "" {
+				text += " (" + *p.Notes + ")"
+			}
+			// Keep the tick box on the same page as the whole line.
+			lines := pdf.SplitLines([]byte(tr(text)), textWidth)
+			if pdf.GetY()+float64(len(lines))*6 > height-bottom {
+				pdf.AddPage()
+			}
+			x, y := pdf.GetXY()
+			pdf.Rect(x, y+1, 4, 4, "D")
+			if p.Packed() {
+				pdf.Line(x+0.8, y+3.2, x+1.8, y+4.3)
+				pdf.Line(x+1.8, y+4.3, x+3.4, y+1.7)
+			}
+			pdf.SetX(x + 8)
+			pdf.MultiCell(textWidth, 6, tr(text), "", "L", false)
+		}
+	}
+	return pdf.Output(w)
+}
@@ -0,0 +1,98 @@
+package reports
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/sunnymotiani/PackTrack/server/models/items"
+	"github.com/sunnymotiani/PackTrack/server/models/users"
+)
+
+// OutstandingItem is a delivered item that has not fully come back in good
+// shape. Pending counts delivered units nobody has reconciled yet.
+type OutstandingItem struct {
+	ItemID            string  `json:"item_id"`
+	Name              string  `json:"name"`
+	Category          string  `json:"category"`
+	Status            string  `json:"status"`
+	AssignedQuantity  int     `json:"assigned_quantity,omitempty"`
+	DeliveredQuantity int     `json:"delivered_quantity"`
+	ReturnedQuantity  int     `json:"returned_quantity"`
+	MissingQuantity   int     `json:"missing_quantity"`
+	DamagedQuantity   int     `json:"damaged_quantity"`
+	Pending           int     `json:"pending"`
+	ReturnNotes       *string `json:"return_notes,omitempty"`
+}
+
+type MemberReconciliation struct {
+	UserID string            `json:"user_id"`
+	Name   string            `json:"name"`
+	Items  []OutstandingItem `json:"items"`
+}
+
+// Reconciliation lists outstanding items per assignee; items without an
+// assignee are listed under Unassigned.
+type Reconciliation struct {
+	Members    []MemberReconciliation `json:"members"`
+	Unassigned []OutstandingItem      `json:"unassigned"`
+}
+
+// GetReconciliation returns the end-of-event report of delivered items that
+// are still out, missing or damaged, grouped by the members assigned to them.
+// An item split between several members appears under each of them.
+func (rs *ReportsService) GetReconciliation(ctx context.Context, eventID string) (*Reconciliation, error) {
+	query := sq.Select("i.id", "i.name", "c.name", "i.status", "i.delivered_quantity",
+		"i.returned_quantity", "i.missing_quantity", "i.damaged_quantity", "i.return_notes",
+		"ia.user_id", "u.name", "ia.quantity").
+		From(items.TableItems+" i").
+		Join(items.TableCategories+" c ON c.id = i.category_id").
+		LeftJoin(items.TableItemAssignments+" ia ON ia.item_id = i.id").
+		LeftJoin(users.TableUsers+" u ON u.id = ia.user_id").
+		Where(sq.Eq{"c.event_id": eventID}).
+		Where("i.delivered_quantity > 0 AND i.returned_quantity < i.delivered_quantity").
+		OrderBy("u.name ASC", "c.position ASC", "c.id ASC", "i.position ASC", "i.id ASC").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building reconciliation query: %w", err)
+	}
+	rows, err := rs.DB.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying reconciliation: %w", err)
+	}
+	defer rows.Close()
+
+	report := &Reconciliation{Members: []MemberReconciliation{}, Unassigned: []OutstandingItem{}}
+	index := map[string]int{}
+	for rows.Next() {
+		var it OutstandingItem
+		var userID, userName sql.NullString
+		var assigned sql.NullInt64
+		if err := rows.Scan(&it.ItemID, &it.Name, &it.Category, &it.Status, &it.DeliveredQuantity,
+			&it.ReturnedQuantity, &it.MissingQuantity, &it.DamagedQuantity, &it.ReturnNotes,
+			&userID, &userName, &assigned); err != nil {
+			return nil, fmt.Errorf("error scanning reconciliation row: %w", err)
+		}
+		it.Pending = it.DeliveredQuantity - it.ReturnedQuantity - it.MissingQuantity - it.DamagedQuantity
+
+		if !userID.Valid {
+			report.Unassigned = append(report.Unassigned, it)
+			continue
+		}
+		it.AssignedQuantity = int(assigned.Int64)
+		i, ok := index[userID.String]
+		if !ok {
+			i = len(report.Members)
+			index[userID.String] = i
+			report.Members = append(report.Members, MemberReconciliation{UserID: userID.String, Name: userName.String})
+		}
+		report.Members[i].Items = append(report.Members[i].Items, it)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return report, nil
+}
@@ -7,10 +7,12 @@ import (
 	"time"
 
 	sq "github.com/Masterminds/squirrel"
+	"github.com/sunnymotiani/PackTrack/server/models/items"
 )
 
 type TemplatesService struct {
-	DB *sql.DB
+	DB    *sql.DB
+	Items *items.ItemsService
 }
 
 type Template struct {
@@ -20,15 +22,29 @@ type Template struct {
 }
 
 type TemplateItem struct {
-	ID         string `json:"id"`
-	TemplateID string `json:"template_id"`
-	Category   string `json:"category"`
-	Name       string `json:"name"`
-	Quantity   int    `json:"quantity"`
+	ID         string         `json:"id"`
+	TemplateID string         `json:"template_id"`
+	Category   string         `json:"category"`
+	Name       string         `json:"name"`
+	Quantity   int            `json:"quantity"`
+	WeightG    *float64       `json:"weight_g,omitempty"`
+	VolumeML   *float64       `json:"volume_ml,omitempty"`
+	WeightUnit string         `json:"weight_unit,omitempty"`
+	VolumeUnit string         `json:"volume_unit,omitempty"`
+	Weight     *items.Measure `json:"weight,omitempty"`
+	Volume     *items.Measure `json:"volume,omitempty"`
+}
+
+// AppliedTemplate is a template used by an event, with its items.
+type AppliedTemplate struct {
+	Template
+	AppliedAt time.Time      `json:"applied_at"`
+	Items     []TemplateItem `json:"items"`
 }
 
 const TableTemplates = "templates"
 const TableTemplateItems = "template_items"
+const TableTemplateApplications = "template_applications"
 
 func (s *TemplatesService) CreateTemplate(ctx context.Context, name string) (*Template, error) {
 	query := sq.
@@ -49,12 +65,36 @@ func (s *TemplatesService) CreateTemplate(ctx context.Context, name string) (*Te
 	return t, err
 }
 
-func (s *TemplatesService) AddItemToTemplate(ctx context.Context, templateID, category, name string, quantity int) (*TemplateItem, error) {
+// AddItemToTemplate stores an item of a template. Weight and Volume, when
+// given, are converted to grams and millilitres like they are for items.
+func (s *TemplatesService) AddItemToTemplate(ctx context.Context, input TemplateItem) (*TemplateItem, error) {
+	if input.Weight != nil {
+		grams, err := input.Weight.Grams()
+		if err != nil {
+			return nil, err
+		}
+		input.WeightG, input.WeightUnit = &grams, input.Weight.Unit
+	}
+	if input.Volume != nil {
+		ml, err := input.Volume.Millilitres()
+		if err != nil {
+			return nil, err
+		}
+		input.VolumeML, input.VolumeUnit = &ml, input.Volume.Unit
+	}
+	if input.WeightUnit == "" {
+		input.WeightUnit = items.UnitGram
+	}
+	if input.VolumeUnit == "" {
+		input.VolumeUnit = items.UnitMillilitre
+	}
+
 	query := sq.
 		Insert(TableTemplateItems).
-		Columns("template_id", "category", "name", "quantity").
-		Values(templateID, category, name, quantity).
-		Suffix("RETURNING id, template_id, category, name, quantity").
+		Columns("template_id", "category", "name", "quantity", "weight_g", "volume_ml", "weight_unit", "volume_unit").
+		Values(input.TemplateID, input.Category, input.Name, input.Quantity,
+			input.WeightG, input.VolumeML, input.WeightUnit, input.VolumeUnit).
+		Suffix("RETURNING " + templateItemColumns).
 		PlaceholderFormat(sq.Dollar)
 
 	sqlStr, args, err := query.ToSql()
@@ -62,10 +102,125 @@ func (s *TemplatesService) AddItemToTemplate(ctx context.Context, templateID, ca
 		return nil, fmt.Errorf("add item sql error: %w", err)
 	}
 
-	item := &TemplateItem{}
-	err = s.DB.QueryRowContext(ctx, sqlStr, args...).
-		Scan(&item.ID, &item.TemplateID, &item.Category, &item.Name, &item.Quantity)
-	return item, err
+	item, err := scanTemplateItem(s.DB.QueryRowContext(ctx, sqlStr, args...))
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// ApplyTemplate adds every item of a template to an event, creating the
+// template's categories in the event when they are missing. A template
+// category may be a path like "Kitchen > Cooking > Utensils" to nest it.
+func (s *TemplatesService) ApplyTemplate(ctx context.Context, templateID, eventID string) ([]items.Item, error) {
+	_, templateItems, err := s.GetTemplateByID(ctx, templateID)
+	if err != nil {
+		return nil, err
+	}
+
+	drafts := make([]items.ItemDraft, 0, len(templateItems))
+	for _, ti := range templateItems {
+		drafts = append(drafts, items.ItemDraft{
+			CategoryName: ti.Category,
+			Item: items.Item{
+				Name:       ti.Name,
+				Quantity:   ti.Quantity,
+				WeightG:    ti.WeightG,
+				VolumeML:   ti.VolumeML,
+				WeightUnit: ti.WeightUnit,
+				VolumeUnit: ti.VolumeUnit,
+			},
+		})
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The items and the record of the template's use are saved together.
+	added, err := items.AddItemsToEventTx(ctx, tx, eventID, drafts)
+	if err != nil {
+		return nil, err
+	}
+
+	query := sq.Insert(TableTemplateApplications).
+		Columns("event_id", "template_id").
+		Values(eventID, templateID).
+		Suffix("ON CONFLICT (event_id, template_id) DO UPDATE SET applied_at = now()").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("record template use sql error: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, sqlStr, args...); err != nil {
+		return nil, fmt.Errorf("error recording template use: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return added, nil
+}
+
+// GetEventTemplates returns the templates applied to an event, oldest use
+// first.
+func (s *TemplatesService) GetEventTemplates(ctx context.Context, eventID string) ([]AppliedTemplate, error) {
+	return getEventTemplates(ctx, s.DB, eventID)
+}
+
+// GetEventTemplatesTx is GetEventTemplates inside the caller's transaction,
+// for callers that read the event alongside its templates.
+func GetEventTemplatesTx(ctx context.Context, tx *sql.Tx, eventID string) ([]AppliedTemplate, error) {
+	return getEventTemplates(ctx, tx, eventID)
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func getEventTemplates(ctx context.Context, q queryer, eventID string) ([]AppliedTemplate, error) {
+	query := sq.Select("t.id", "t.name", "t.created_at", "ta.applied_at").
+		From(TableTemplateApplications+" ta").
+		Join(TableTemplates+" t ON t.id = ta.template_id").
+		Where(sq.Eq{"ta.event_id": eventID}).
+		OrderBy("ta.applied_at ASC", "t.id ASC").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("get event templates sql error: %w", err)
+	}
+	rows, err := q.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := []AppliedTemplate{}
+	for rows.Next() {
+		var a AppliedTemplate
+		if err := rows.Scan(&a.ID, &a.Name, &a.CreatedAt, &a.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied = append(applied, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for i := range applied {
+		templateItems, err := getTemplateItems(ctx, q, applied[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		applied[i].Items = templateItems
+		if applied[i].Items == nil {
+			applied[i].Items = []TemplateItem{}
+		}
+	}
+	return applied, nil
 }
 
 func (s *TemplatesService) GetTemplateByID(ctx context.Context, id string) (*Template, []TemplateItem, error) {
@@ -88,33 +243,40 @@ func (s *TemplatesService) GetTemplateByID(ctx context.Context, id string) (*Tem
 	}
 
 	// Now fetch items
+	templateItems, err := getTemplateItems(ctx, s.DB, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return t, templateItems, nil
+}
+
+func getTemplateItems(ctx context.Context, q queryer, templateID string) ([]TemplateItem, error) {
 	itemsQuery := sq.
-		Select("id", "template_id", "category", "name", "quantity").
+		Select(templateItemColumns).
 		From(TableTemplateItems).
-		Where(sq.Eq{"template_id": id}).
+		Where(sq.Eq{"template_id": templateID}).
 		PlaceholderFormat(sq.Dollar)
 
-	sqlStr, args, err = itemsQuery.ToSql()
+	sqlStr, args, err := itemsQuery.ToSql()
 	if err != nil {
-		return nil, nil, fmt.Errorf("get template items sql error: %w", err)
+		return nil, fmt.Errorf("get template items sql error: %w", err)
 	}
 
-	rows, err := s.DB.QueryContext(ctx, sqlStr, args...)
+	rows, err := q.QueryContext(ctx, sqlStr, args...)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 	defer rows.Close()
 
-	var items []TemplateItem
+	var templateItems []TemplateItem
 	for rows.Next() {
-		var i TemplateItem
-		if err := rows.Scan(&i.ID, &i.TemplateID, &i.Category, &i.Name, &i.Quantity); err != nil {
-			return nil, nil, err
+		i, err := scanTemplateItem(rows)
+		if err != nil {
+			return nil, err
 		}
-		items = append(items, i)
+		templateItems = append(templateItems, *i)
 	}
-
-	return t, items, nil
+	return templateItems, rows.Err()
 }
 
 func (s *TemplatesService) DeleteTemplate(ctx context.Context, id string) error {
@@ -146,3 +308,19 @@ func (s *TemplatesService) DeleteItem(ctx context.Context, itemID string) error
 	_, err = s.DB.ExecContext(ctx, sqlStr, args...)
 	return err
 }
+
+const templateItemColumns = "id, template_id, category, name, quantity, weight_g, volume_ml, weight_unit, volume_unit"
+
+func scanTemplateItem(row interface{ Scan(...interface{}) error }) (*TemplateItem, error) {
+	i := &TemplateItem{}
+	var weightUnit, volumeUnit sql.NullString
+	err := row.Scan(&i.ID, &i.TemplateID, &i.Category, &i.Name, &i.Quantity, &i.WeightG, &i.VolumeML,
+		&weightUnit, &volumeUnit)
+	if err != nil {
+		return nil, err
+	}
+	i.WeightUnit, i.VolumeUnit = weightUnit.String, volumeUnit.String
+	i.Weight = items.WeightIn(i.WeightG, i.WeightUnit)
+	i.Volume = items.VolumeIn(i.VolumeML, i.VolumeUnit)
+	return i, nil
+}
@@ -0,0 +1,337 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/sunnymotiani/PackTrack/server/models/activity"
+)
+
+// DELIVERY IMPLEMENTATION
+
+// Headers sent with every delivery. The signature is
+// "sha256=" + hex(HMAC-SHA256(secret, timestamp + "." + body)).
+const (
+	HeaderEvent     = "X-PackTrack-Event"
+	HeaderDelivery  = "X-PackTrack-Delivery"
+	HeaderTimestamp = "X-PackTrack-Timestamp"
+	HeaderSignature = "X-PackTrack-Signature"
+)
+
+// Payload is the JSON body of a delivery. Data is the activity as published.
+type Payload struct {
+	ID         string            `json:"id"`
+	Type       string            `json:"type"`
+	EventID    string            `json:"event_id"`
+	OccurredAt time.Time         `json:"occurred_at"`
+	Data       activity.Activity `json:"data"`
+}
+
+// Sign returns the signature header value for a body sent at timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature header value in constant time.
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// Publish queues a delivery of the activity to every active webhook of its
+// event subscribed to it. Failures are logged; the change the activity
+// describes has already happened.
+func (ws *WebhooksService) Publish(ctx context.Context, a activity.Activity) {
+	if err := ws.enqueue(ctx, a); err != nil {
+		log.Printf("webhooks: %v", err)
+	}
+}
+
+func (ws *WebhooksService) enqueue(ctx context.Context, a activity.Activity) error {
+	eventType, ok := eventTypes[a.Type]
+	if !ok || a.EventID == "" {
+		return nil
+	}
+	query := sq.Select("w.id").
+		From(TableWebhooks + " w").
+		Join(TableWebhookSubscriptions + " s ON s.webhook_id = w.id").
+		Where(sq.Eq{"w.event_id": a.EventID, "w.active": true, "s.event_type": eventType}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building subscribed webhooks query: %w", err)
+	}
+	rows, err := ws.DB.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return fmt.Errorf("error querying subscribed webhooks: %w", err)
+	}
+	defer rows.Close()
+	var hookIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return fmt.Errorf("error scanning webhook row: %w", err)
+		}
+		hookIDs = append(hookIDs, id)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows iteration error: %w", err)
+	}
+	if len(hookIDs) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(Payload{
+		ID:         uuid.NewString(),
+		Type:       eventType,
+		EventID:    a.EventID,
+		OccurredAt: a.OccurredAt,
+		Data:       a,
+	})
+	if err != nil {
+		return fmt.Errorf("error encoding webhook payload: %w", err)
+	}
+	insert := sq.Insert(TableWebhookDeliveries).
+		Columns("id", "webhook_id", "event_type", "payload", "state", "next_attempt_at").
+		PlaceholderFormat(sq.Dollar)
+	for _, id := range hookIDs {
+		insert = insert.Values(uuid.NewString(), id, eventType, payload, DeliveryPending, sq.Expr("now()"))
+	}
+	insertSQL, insertArgs, err := insert.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building insert deliveries query: %w", err)
+	}
+	if _, err := ws.DB.ExecContext(ctx, insertSQL, insertArgs...); err != nil {
+		return fmt.Errorf("error queueing deliveries: %w", err)
+	}
+	return nil
+}
+
+// Dispatcher sends queued deliveries. Several dispatchers may run at once;
+// each claims its deliveries for Lease so they are not sent twice.
+type Dispatcher struct {
+	DB *sql.DB
+	// Client sends deliveries. It should come from NewClient so that
+	// endpoints cannot reach internal addresses; when nil a NewClient with a
+	// 10 second timeout is used.
+	Client *http.Client
+
+	// Interval is how often the dispatcher looks for due deliveries.
+	Interval time.Duration
+	// MaxAttempts bounds delivery attempts before a delivery is dead; a failed
+	// attempt is retried after Backoff, doubled for each attempt.
+	MaxAttempts int
+	Backoff     time.Duration
+	// Lease is how long a claimed delivery is hidden from other dispatchers;
+	// it should exceed the client timeout.
+	Lease time.Duration
+}
+
+type job struct {
+	id        string
+	eventType string
+	payload   []byte
+	attempts  int
+	url       string
+	secret    string
+}
+
+// Run polls until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+	for {
+		if err := d.RunOnce(ctx, time.Now()); err != nil {
+			log.Printf("webhooks: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce sends the deliveries due at now.
+func (d *Dispatcher) RunOnce(ctx context.Context, now time.Time) error {
+	if d.Client == nil {
+		d.Client = NewClient(10 * time.Second)
+	}
+	for {
+		jobs, err := d.claim(ctx, now, 50)
+		if err != nil {
+			return err
+		}
+		for _, j := range jobs {
+			if err := d.send(ctx, j); err != nil {
+				log.Printf("webhooks: recording delivery %s: %v", j.id, err)
+			}
+		}
+		if len(jobs) < 50 {
+			return nil
+		}
+	}
+}
+
+// claim takes up to limit due deliveries of active webhooks and pushes their
+// next attempt back by Lease.
+func (d *Dispatcher) claim(ctx context.Context, now time.Time, limit uint64) ([]job, error) {
+	tx, err := d.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := sq.Select("d.id", "d.event_type", "d.payload", "d.attempts", "w.url", "w.secret").
+		From(TableWebhookDeliveries + " d").
+		Join(TableWebhooks + " w ON w.id = d.webhook_id").
+		Where(sq.Eq{"d.state": DeliveryPending, "w.active": true}).
+		Where(sq.LtOrEq{"d.next_attempt_at": now}).
+		OrderBy("d.next_attempt_at ASC").
+		Limit(limit).
+		Suffix("FOR UPDATE OF d SKIP LOCKED").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building due deliveries query: %w", err)
+	}
+	rows, err := tx.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying due deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []job
+	var ids []string
+	for rows.Next() {
+		var j job
+		if err := rows.Scan(&j.id, &j.eventType, &j.payload, &j.attempts, &j.url, &j.secret); err != nil {
+			return nil, fmt.Errorf("error scanning delivery row: %w", err)
+		}
+		jobs = append(jobs, j)
+		ids = append(ids, j.id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	rows.Close()
+	if len(jobs) == 0 {
+		return nil, nil
+	}
+
+	update := sq.Update(TableWebhookDeliveries).
+		Set("next_attempt_at", now.Add(d.Lease)).
+		Where(sq.Eq{"id": ids}).
+		PlaceholderFormat(sq.Dollar)
+
+	updateSQL, updateArgs, err := update.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building claim deliveries query: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, updateSQL, updateArgs...); err != nil {
+		return nil, fmt.Errorf("error claiming deliveries: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// send posts one delivery and records the outcome. Any 2xx response counts
+// as delivered.
+func (d *Dispatcher) send(ctx context.Context, j job) error {
+	start := time.Now()
+	statusCode, sendErr := d.post(ctx, j, start)
+	duration := time.Since(start)
+
+	var errMsg *string
+	if sendErr != nil {
+		msg := sendErr.Error()
+		errMsg = &msg
+	}
+	tx, err := d.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	attempt := sq.Insert(TableWebhookAttempts).
+		Columns("id", "delivery_id", "status_code", "error", "duration_ms", "attempted_at").
+		Values(uuid.NewString(), j.id, statusCode, errMsg, duration.Milliseconds(), start).
+		PlaceholderFormat(sq.Dollar)
+
+	attemptSQL, attemptArgs, err := attempt.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building insert attempt query: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, attemptSQL, attemptArgs...); err != nil {
+		return fmt.Errorf("error logging attempt: %w", err)
+	}
+
+	j.attempts++
+	update := sq.Update(TableWebhookDeliveries).
+		Set("attempts", j.attempts).
+		Where(sq.Eq{"id": j.id}).
+		PlaceholderFormat(sq.Dollar)
+	switch {
+	case sendErr == nil:
+		update = update.Set("state", DeliveryDelivered).Set("delivered_at", time.Now()).Set("next_attempt_at", nil)
+	case j.attempts >= d.MaxAttempts:
+		log.Printf("webhooks: delivery %s is dead after %d attempts: %v", j.id, j.attempts, sendErr)
+		update = update.Set("state", DeliveryDead).Set("next_attempt_at", nil)
+	default:
+		update = update.Set("next_attempt_at", time.Now().Add(d.Backoff<<(j.attempts-1)))
+	}
+
+	updateSQL, updateArgs, err := update.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building update delivery query: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, updateSQL, updateArgs...); err != nil {
+		return fmt.Errorf("error updating delivery: %w", err)
+	}
+	return tx.Commit()
+}
+
+// post sends the signed payload and returns the response status, if any.
+func (d *Dispatcher) post(ctx context.Context, j job, now time.Time) (*int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, j.url, bytes.NewReader(j.payload))
+	if err != nil {
+		return nil, err
+	}
+	timestamp := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, j.eventType)
+	req.Header.Set(HeaderDelivery, j.id)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(j.secret, timestamp, j.payload))
+
+	res, err := d.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 1<<16))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return &res.StatusCode, fmt.Errorf("endpoint responded %s", res.Status)
+	}
+	return &res.StatusCode, nil
+}
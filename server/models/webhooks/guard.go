@@ -0,0 +1,83 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// ADDRESS GUARD IMPLEMENTATION
+
+var ErrInternalURL = errors.New("webhook url must not point at a loopback, private or link-local address")
+
+// blockedNets are internal ranges the net.IP helpers do not cover.
+var blockedNets = []*net.IPNet{
+	mustCIDR("0.0.0.0/8"),
+	mustCIDR("100.64.0.0/10"),
+}
+
+func mustCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// internalIP reports whether ip is an address webhooks may not reach, such
+// as the loopback interface, private networks or the cloud metadata service.
+func internalIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, n := range blockedNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkHost resolves host and fails with ErrInternalURL when any of its
+// addresses is internal.
+func checkHost(ctx context.Context, host string) error {
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return fmt.Errorf("%w: cannot resolve %s", ErrInvalidURL, host)
+	}
+	for _, a := range addrs {
+		if internalIP(a.IP) {
+			return ErrInternalURL
+		}
+	}
+	return nil
+}
+
+// NewClient returns an HTTP client for the Dispatcher that refuses to
+// connect to internal addresses. The check runs on the address actually
+// dialled, so it also holds for redirects and for hosts that resolved to a
+// public address when the webhook was registered.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || internalIP(ip) {
+				return ErrInternalURL
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
@@ -0,0 +1,503 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/sunnymotiani/PackTrack/server/models/activity"
+	"github.com/sunnymotiani/PackTrack/server/models/events"
+)
+
+const (
+	TableWebhooks             = "webhooks"
+	TableWebhookSubscriptions = "webhook_subscriptions"
+	TableWebhookDeliveries    = "webhook_deliveries"
+	TableWebhookAttempts      = "webhook_attempts"
+)
+
+// Event types a webhook can subscribe to.
+const (
+	EventItemAssigned      = "item.assigned"
+	EventItemStatusChanged = "item.status_changed"
+	EventMemberAdded       = "member.added"
+	EventMemberRoleChanged = "member.role_changed"
+	EventMemberRemoved     = "member.removed"
+)
+
+// eventTypes names the webhook event for each type of activity.
+var eventTypes = map[string]string{
+	activity.TypeAssignment:    EventItemAssigned,
+	activity.TypeStatusChange:  EventItemStatusChanged,
+	activity.TypeInvitation:    EventMemberAdded,
+	activity.TypeMembership:    EventMemberRoleChanged,
+	activity.TypeMemberRemoved: EventMemberRemoved,
+}
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+var (
+	ErrInvalidURL           = errors.New("webhook url must be an absolute http or https url")
+	ErrInvalidEventType     = errors.New("unknown webhook event type")
+	ErrNoEventTypes         = errors.New("webhook must subscribe to at least one event type")
+	ErrNotEventAdmin        = errors.New("only event owners and admins can manage webhooks")
+	ErrWebhookNotFound      = errors.New("webhook not found")
+	ErrDeliveryNotFound     = errors.New("webhook delivery not found")
+	ErrInvalidDeliveryState = errors.New("state must be pending, delivered or dead")
+)
+
+// WebhooksService manages an event's webhooks. It is an activity publisher:
+// Publish queues a delivery for every webhook subscribed to the activity and
+// a Dispatcher sends them.
+type WebhooksService struct {
+	DB *sql.DB
+}
+
+// Webhook is an endpoint that receives signed event payloads. Secret is only
+// returned when the webhook is created.
+type Webhook struct {
+	ID         string    `json:"id"`
+	EventID    string    `json:"event_id"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"secret,omitempty"`
+	EventTypes []string  `json:"event_types"`
+	Active     bool      `json:"active"`
+	CreatedBy  string    `json:"created_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type WebhookUpdate struct {
+	URL        *string  `json:"url"`
+	EventTypes []string `json:"event_types"`
+	Active     *bool    `json:"active"`
+}
+
+type Delivery struct {
+	ID            string     `json:"id"`
+	WebhookID     string     `json:"webhook_id"`
+	EventType     string     `json:"event_type"`
+	Payload       []byte     `json:"-"`
+	State         string     `json:"state"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	Log           []Attempt  `json:"log"`
+}
+
+// Attempt is one try at sending a delivery. StatusCode is nil when no
+// response came back.
+type Attempt struct {
+	StatusCode  *int      `json:"status_code,omitempty"`
+	Error       *string   `json:"error,omitempty"`
+	DurationMS  int       `json:"duration_ms"`
+	AttemptedAt time.Time `json:"attempted_at"`
+}
+
+// CreateWebhook registers an endpoint for an event. A secret is generated
+// unless one is given.
+func (ws *WebhooksService) CreateWebhook(ctx context.Context, actorID string, h *Webhook) error {
+	if err := validateURL(ctx, h.URL); err != nil {
+		return err
+	}
+	if err := validateEventTypes(h.EventTypes); err != nil {
+		return err
+	}
+	if err := checkEventAdmin(ctx, ws.DB, h.EventID, actorID); err != nil {
+		return err
+	}
+	if h.Secret == "" {
+		secret, err := newSecret()
+		if err != nil {
+			return err
+		}
+		h.Secret = secret
+	}
+	h.ID = uuid.NewString()
+	h.Active = true
+	h.CreatedBy = actorID
+	h.CreatedAt = time.Now()
+
+	tx, err := ws.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := sq.Insert(TableWebhooks).
+		Columns("id", "event_id", "url", "secret", "active", "created_by", "created_at").
+		Values(h.ID, h.EventID, h.URL, h.Secret, h.Active, h.CreatedBy, h.CreatedAt).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building create webhook query: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("error inserting webhook: %w", err)
+	}
+	if err := replaceSubscriptions(ctx, tx, h.ID, h.EventTypes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetWebhooks lists the webhooks of an event without their secrets.
+func (ws *WebhooksService) GetWebhooks(ctx context.Context, actorID, eventID string) ([]Webhook, error) {
+	if err := checkEventAdmin(ctx, ws.DB, eventID, actorID); err != nil {
+		return nil, err
+	}
+	query := sq.Select("w.id", "w.event_id", "w.url", "w.active", "w.created_by", "w.created_at", "s.event_type").
+		From(TableWebhooks+" w").
+		LeftJoin(TableWebhookSubscriptions+" s ON s.webhook_id = w.id").
+		Where(sq.Eq{"w.event_id": eventID}).
+		OrderBy("w.created_at ASC", "w.id ASC", "s.event_type ASC").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building webhooks query: %w", err)
+	}
+	rows, err := ws.DB.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	hooks := []Webhook{}
+	index := map[string]int{}
+	for rows.Next() {
+		var h Webhook
+		var createdBy, eventType sql.NullString
+		if err := rows.Scan(&h.ID, &h.EventID, &h.URL, &h.Active, &createdBy, &h.CreatedAt, &eventType); err != nil {
+			return nil, fmt.Errorf("error scanning webhook row: %w", err)
+		}
+		i, ok := index[h.ID]
+		if !ok {
+			i = len(hooks)
+			index[h.ID] = i
+			h.CreatedBy = createdBy.String
+			h.EventTypes = []string{}
+			hooks = append(hooks, h)
+		}
+		if eventType.Valid {
+			hooks[i].EventTypes = append(hooks[i].EventTypes, eventType.String)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return hooks, nil
+}
+
+// UpdateWebhook changes the url, subscriptions or active flag of a webhook.
+// Deliveries already queued for a paused webhook wait until it is resumed.
+func (ws *WebhooksService) UpdateWebhook(ctx context.Context, actorID, webhookID string, input WebhookUpdate) error {
+	if input.URL != nil {
+		if err := validateURL(ctx, *input.URL); err != nil {
+			return err
+		}
+	}
+	if input.EventTypes != nil {
+		if err := validateEventTypes(input.EventTypes); err != nil {
+			return err
+		}
+	}
+
+	tx, err := ws.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	eventID, err := webhookEvent(ctx, tx, webhookID)
+	if err != nil {
+		return err
+	}
+	if err := checkEventAdmin(ctx, tx, eventID, actorID); err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{}
+	if input.URL != nil {
+		updates["url"] = *input.URL
+	}
+	if input.Active != nil {
+		updates["active"] = *input.Active
+	}
+	if len(updates) > 0 {
+		query := sq.Update(TableWebhooks).
+			SetMap(updates).
+			Where(sq.Eq{"id": webhookID}).
+			PlaceholderFormat(sq.Dollar)
+
+		sqlStr, args, err := query.ToSql()
+		if err != nil {
+			return fmt.Errorf("error building update webhook query: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, sqlStr, args...); err != nil {
+			return fmt.Errorf("error updating webhook: %w", err)
+		}
+	}
+	if input.EventTypes != nil {
+		if err := replaceSubscriptions(ctx, tx, webhookID, input.EventTypes); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteWebhook removes a webhook together with its delivery log.
+func (ws *WebhooksService) DeleteWebhook(ctx context.Context, actorID, webhookID string) error {
+	eventID, err := webhookEvent(ctx, ws.DB, webhookID)
+	if err != nil {
+		return err
+	}
+	if err := checkEventAdmin(ctx, ws.DB, eventID, actorID); err != nil {
+		return err
+	}
+	query := sq.Delete(TableWebhooks).Where(sq.Eq{"id": webhookID}).PlaceholderFormat(sq.Dollar)
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building delete webhook query: %w", err)
+	}
+	_, err = ws.DB.ExecContext(ctx, sqlStr, args...)
+	return err
+}
+
+// GetDeliveries returns the deliveries of a webhook, newest first, with the
+// log of their attempts. An empty state returns every delivery.
+func (ws *WebhooksService) GetDeliveries(ctx context.Context, actorID, webhookID, state string) ([]Delivery, error) {
+	if state != "" && state != DeliveryPending && state != DeliveryDelivered && state != DeliveryDead {
+		return nil, ErrInvalidDeliveryState
+	}
+	eventID, err := webhookEvent(ctx, ws.DB, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkEventAdmin(ctx, ws.DB, eventID, actorID); err != nil {
+		return nil, err
+	}
+	where := sq.Eq{"d.webhook_id": webhookID}
+	if state != "" {
+		where["d.state"] = state
+	}
+	return ws.queryDeliveries(ctx, where)
+}
+
+// GetDeadLetters returns the deliveries of an event's webhooks that ran out
+// of attempts.
+func (ws *WebhooksService) GetDeadLetters(ctx context.Context, actorID, eventID string) ([]Delivery, error) {
+	if err := checkEventAdmin(ctx, ws.DB, eventID, actorID); err != nil {
+		return nil, err
+	}
+	return ws.queryDeliveries(ctx, sq.Eq{"w.event_id": eventID, "d.state": DeliveryDead})
+}
+
+func (ws *WebhooksService) queryDeliveries(ctx context.Context, where sq.Eq) ([]Delivery, error) {
+	query := sq.Select("d.id", "d.webhook_id", "d.event_type", "d.state", "d.attempts", "d.next_attempt_at",
+		"d.delivered_at", "d.created_at", "a.status_code", "a.error", "a.duration_ms", "a.attempted_at").
+		From(TableWebhookDeliveries+" d").
+		Join(TableWebhooks+" w ON w.id = d.webhook_id").
+		LeftJoin(TableWebhookAttempts+" a ON a.delivery_id = d.id").
+		Where(where).
+		OrderBy("d.created_at DESC", "d.id ASC", "a.attempted_at ASC").
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("error building deliveries query: %w", err)
+	}
+	rows, err := ws.DB.QueryContext(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []Delivery{}
+	index := map[string]int{}
+	for rows.Next() {
+		var d Delivery
+		var a Attempt
+		var durationMS sql.NullInt64
+		var attemptedAt sql.NullTime
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.EventType, &d.State, &d.Attempts, &d.NextAttemptAt,
+			&d.DeliveredAt, &d.CreatedAt, &a.StatusCode, &a.Error, &durationMS, &attemptedAt); err != nil {
+			return nil, fmt.Errorf("error scanning delivery row: %w", err)
+		}
+		i, ok := index[d.ID]
+		if !ok {
+			i = len(deliveries)
+			index[d.ID] = i
+			d.Log = []Attempt{}
+			deliveries = append(deliveries, d)
+		}
+		if attemptedAt.Valid {
+			a.DurationMS, a.AttemptedAt = int(durationMS.Int64), attemptedAt.Time
+			deliveries[i].Log = append(deliveries[i].Log, a)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+	return deliveries, nil
+}
+
+// Redeliver queues a delivery to be sent again right away with a fresh set
+// of attempts, whether it was delivered or dead.
+func (ws *WebhooksService) Redeliver(ctx context.Context, actorID, deliveryID string) error {
+	lookup := sq.Select("w.event_id").
+		From(TableWebhookDeliveries + " d").
+		Join(TableWebhooks + " w ON w.id = d.webhook_id").
+		Where(sq.Eq{"d.id": deliveryID}).
+		PlaceholderFormat(sq.Dollar)
+
+	lookupSQL, lookupArgs, err := lookup.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building delivery lookup query: %w", err)
+	}
+	var eventID string
+	err = ws.DB.QueryRowContext(ctx, lookupSQL, lookupArgs...).Scan(&eventID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrDeliveryNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("error fetching delivery: %w", err)
+	}
+	if err := checkEventAdmin(ctx, ws.DB, eventID, actorID); err != nil {
+		return err
+	}
+
+	query := sq.Update(TableWebhookDeliveries).
+		Set("state", DeliveryPending).
+		Set("attempts", 0).
+		Set("next_attempt_at", sq.Expr("now()")).
+		Set("delivered_at", nil).
+		Where(sq.Eq{"id": deliveryID}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building redeliver query: %w", err)
+	}
+	if _, err := ws.DB.ExecContext(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("error queueing redelivery: %w", err)
+	}
+	return nil
+}
+
+func replaceSubscriptions(ctx context.Context, tx *sql.Tx, webhookID string, eventTypes []string) error {
+	deleteQuery := sq.Delete(TableWebhookSubscriptions).
+		Where(sq.Eq{"webhook_id": webhookID}).
+		PlaceholderFormat(sq.Dollar)
+
+	deleteSQL, deleteArgs, err := deleteQuery.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building delete subscriptions query: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, deleteSQL, deleteArgs...); err != nil {
+		return fmt.Errorf("error deleting subscriptions: %w", err)
+	}
+
+	insert := sq.Insert(TableWebhookSubscriptions).
+		Columns("webhook_id", "event_type").
+		Suffix("ON CONFLICT DO NOTHING").
+		PlaceholderFormat(sq.Dollar)
+	for _, t := range eventTypes {
+		insert = insert.Values(webhookID, t)
+	}
+	insertSQL, insertArgs, err := insert.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building insert subscriptions query: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, insertSQL, insertArgs...); err != nil {
+		return fmt.Errorf("error inserting subscriptions: %w", err)
+	}
+	return nil
+}
+
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func webhookEvent(ctx context.Context, q queryer, webhookID string) (string, error) {
+	query := sq.Select("event_id").From(TableWebhooks).Where(sq.Eq{"id": webhookID}).PlaceholderFormat(sq.Dollar)
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return "", fmt.Errorf("error building webhook event query: %w", err)
+	}
+	var eventID string
+	err = q.QueryRowContext(ctx, sqlStr, args...).Scan(&eventID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrWebhookNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("error fetching webhook: %w", err)
+	}
+	return eventID, nil
+}
+
+func checkEventAdmin(ctx context.Context, q queryer, eventID, userID string) error {
+	query := sq.Select("COUNT(*)").
+		From(events.TableEventMemberships).
+		Where(sq.Eq{"event_id": eventID, "user_id": userID, "role": []string{"owner", "admin"}}).
+		PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("error building event admin query: %w", err)
+	}
+	var count int
+	if err := q.QueryRowContext(ctx, sqlStr, args...).Scan(&count); err != nil {
+		return fmt.Errorf("error checking event admin: %w", err)
+	}
+	if count == 0 {
+		return ErrNotEventAdmin
+	}
+	return nil
+}
+
+// validateURL accepts absolute http and https urls whose host resolves to
+// public addresses only.
+func validateURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return ErrInvalidURL
+	}
+	return checkHost(ctx, u.Hostname())
+}
+
+func validateEventTypes(types []string) error {
+	if len(types) == 0 {
+		return ErrNoEventTypes
+	}
+	valid := map[string]bool{}
+	for _, t := range eventTypes {
+		valid[t] = true
+	}
+	for _, t := range types {
+		if !valid[t] {
+			return fmt.Errorf("%w: %s", ErrInvalidEventType, t)
+		}
+	}
+	return nil
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating webhook secret: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}